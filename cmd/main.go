@@ -36,14 +36,7 @@ $$ |            \$$$$$$$ |      $$$$$$$$\       $$ |  $$ |      \$$$$$$$ |
 
 	log.Info("Starting http", "env", cfg.Server.Env)
 
-	application := app.New(
-		log,
-		cfg.Server.Address,
-		cfg.Database.PostgresConn,
-		cfg.JWT.Secret,
-		cfg.JWT.AccessExpirationMinutes,
-		cfg.JWT.RefreshExpirationDays,
-	)
+	application := app.New(log, cfg)
 
 	go application.HTTPServer.MustRun()
 
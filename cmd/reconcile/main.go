@@ -0,0 +1,123 @@
+// reconcile сверяет балансы пользователей с журналом проводок и с историей покупок, переводов и корректировок
+// и печатает отчет о расхождениях. С флагом -fix балансы приводятся к журналу, а расхождение истории
+// записывается корректировкой с причиной -reason.
+//
+//	go run ./cmd/reconcile -format csv -out drift.csv
+//	go run ./cmd/reconcile -fix -reason "сверка после инцидента"
+//
+// Код выхода 0 - расхождений нет или все исправлены, 2 - найдены неисправленные расхождения, 1 - ошибка.
+package main
+
+import (
+	"avito-shop/internal/config"
+	"avito-shop/internal/domain/dto"
+	"avito-shop/internal/repository/postgres"
+	"avito-shop/internal/services"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+)
+
+// defaultStartingGrant - DEFAULT users.coins, монеты, начисляемые при регистрации.
+const defaultStartingGrant = 100000
+
+func main() {
+	format := flag.String("format", "json", "формат отчета: json или csv")
+	out := flag.String("out", "", "файл отчета, по умолчанию stdout")
+	fix := flag.Bool("fix", false, "привести балансы к журналу и записать расхождения истории корректировками")
+	reason := flag.String("reason", "", "причина корректировок, обязательна с -fix")
+	grant := flag.Int("grant", defaultStartingGrant, "стартовые монеты пользователя")
+	flag.Parse()
+
+	// отчет может писаться в stdout, поэтому логи идут в stderr
+	log := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelInfo}))
+
+	code, err := run(log, *format, *out, *fix, *reason, *grant)
+	if err != nil {
+		log.Error("reconcile failed", slog.String("error", err.Error()))
+	}
+	os.Exit(code)
+}
+
+func run(log *slog.Logger, format, out string, fix bool, reason string, grant int) (int, error) {
+	if format != "json" && format != "csv" {
+		return 1, fmt.Errorf("unknown format %q", format)
+	}
+
+	cfg := config.MustLoad()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	storage, err := postgres.NewPostgres(ctx, cfg.Database.PostgresConn)
+	if err != nil {
+		return 1, err
+	}
+	defer storage.Close()
+
+	report, err := services.NewReconcileService(log, storage, grant).Reconcile(ctx, fix, reason)
+	if err != nil {
+		return 1, err
+	}
+
+	w := io.Writer(os.Stdout)
+	if out != "" {
+		file, err := os.Create(out)
+		if err != nil {
+			return 1, err
+		}
+		defer file.Close()
+		w = file
+	}
+
+	if format == "csv" {
+		err = writeCSV(w, report)
+	} else {
+		err = writeJSON(w, report)
+	}
+	if err != nil {
+		return 1, err
+	}
+
+	for _, drift := range report.Drifts {
+		if !drift.Corrected {
+			return 2, nil
+		}
+	}
+
+	return 0, nil
+}
+
+func writeJSON(w io.Writer, report dto.ReconcileReport) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+func writeCSV(w io.Writer, report dto.ReconcileReport) error {
+	writer := csv.NewWriter(w)
+
+	_ = writer.Write([]string{"user_id", "username", "coins", "expected", "ledger", "drift", "ledger_drift", "corrected"})
+	for _, drift := range report.Drifts {
+		_ = writer.Write([]string{
+			drift.UserID.String(),
+			drift.Username,
+			strconv.Itoa(drift.Coins),
+			strconv.Itoa(drift.Expected),
+			strconv.Itoa(drift.Ledger),
+			strconv.Itoa(drift.Drift),
+			strconv.Itoa(drift.LedgerDrift),
+			strconv.FormatBool(drift.Corrected),
+		})
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
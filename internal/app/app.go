@@ -2,8 +2,11 @@ package app
 
 import (
 	httpserver "avito-shop/internal/app/http-server"
+	"avito-shop/internal/config"
 	"avito-shop/internal/handlers"
+	"avito-shop/internal/lib/hasher"
 	"avito-shop/internal/lib/jwt"
+	"avito-shop/internal/lib/mailer"
 	"avito-shop/internal/middlewares"
 	"avito-shop/internal/repository/postgres"
 	"avito-shop/internal/repository/redis"
@@ -19,30 +22,91 @@ type App struct {
 	HTTPServer *httpserver.Server
 }
 
-func New(log *slog.Logger, serverPort, storagePath, secret string, accessTTL, refreshTTL int) *App {
-	storage, err := postgres.NewPostgres(context.Background(), storagePath)
+func New(log *slog.Logger, cfg *config.Config) *App {
+	storage, err := postgres.NewPostgres(context.Background(), cfg.Database.PostgresConn)
 	if err != nil {
 		panic(err)
 	}
 
-	jwtGen := jwt.NewGenerator(secret, time.Minute*time.Duration(accessTTL), time.Hour*time.Duration(refreshTTL))
+	accessTTLDuration := time.Minute * time.Duration(cfg.JWT.AccessExpirationMinutes)
+	refreshTTLDuration := 24 * time.Hour * time.Duration(cfg.JWT.RefreshExpirationDays)
 
-	redisDB, err := redis.InitRedis(os.Getenv("REDIS_STORAGE_PATH"), os.Getenv("redis_password"), os.Getenv("DB_NUMBER"), time.Duration(refreshTTL)*24)
+	jwtOpts := []jwt.Option{
+		jwt.WithIssuer(cfg.JWT.Issuer),
+		jwt.WithAudience(cfg.JWT.Audience),
+		jwt.WithLeeway(cfg.JWT.Leeway),
+	}
+
+	if cfg.JWT.KeysDir != "" {
+		// удаленные с диска ключи остаются для проверки, пока не истекут подписанные ими refresh токены
+		keys, err := jwt.LoadKeySet(cfg.JWT.KeysDir, refreshTTLDuration)
+		if err != nil {
+			panic(err)
+		}
+
+		go keys.Watch(context.Background(), cfg.JWT.KeysReloadInterval, log)
+
+		jwtOpts = append(jwtOpts, jwt.WithKeySet(keys), jwt.WithLegacySecretUntil(cfg.JWT.LegacySecretUntil))
+	}
+
+	jwtGen := jwt.NewGenerator(cfg.JWT.Secret, accessTTLDuration, refreshTTLDuration, jwtOpts...)
+
+	redisDB, err := redis.InitRedis(os.Getenv("REDIS_STORAGE_PATH"), os.Getenv("redis_password"), os.Getenv("DB_NUMBER"), accessTTLDuration, refreshTTLDuration)
 	if err != nil {
 		panic(err)
 	}
 
-	authService := services.NewAuthService(log, storage, redisDB, jwtGen)
-	userService := services.NewUserService(log, storage)
+	passwordHasher, err := hasher.New(hasher.Config{
+		Algorithm:  cfg.Auth.PasswordHash.Algorithm,
+		BcryptCost: cfg.Auth.PasswordHash.BcryptCost,
+		Argon2id: hasher.Argon2idParams{
+			Memory:      cfg.Auth.PasswordHash.Argon2Memory,
+			Iterations:  cfg.Auth.PasswordHash.Argon2Iterations,
+			Parallelism: cfg.Auth.PasswordHash.Argon2Parallelism,
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	authService := services.NewAuthService(log, storage, redisDB, jwtGen, passwordHasher, cfg.Auth.AutoRegister)
+	merchService := services.NewMerchService(log, storage, cfg.Merch.CacheTTL)
+	userService := services.NewUserService(log, storage, merchService)
+	adminService := services.NewAdminService(log, storage)
+	catalogService := services.NewCatalogService(log, storage, merchService)
+	purchaseService := services.NewPurchaseService(log, storage, cfg.Purchase.ReturnWindow, merchService)
+
+	var mail services.Mailer = mailer.NewLogMailer(log)
+	if cfg.Mailer.File != "" {
+		mail = mailer.NewFileMailer(cfg.Mailer.File)
+	}
+	accountService := services.NewAccountService(log, storage, redisDB, mail, passwordHasher,
+		cfg.Auth.PasswordResetTTL)
+
+	loginLimiter := services.NewLoginLimiter(log, redisDB, authService, services.LoginLimiterConfig{
+		MaxAttempts:      cfg.Auth.LoginLimit.MaxAttempts,
+		MaxAttemptsPerIP: cfg.Auth.LoginLimit.MaxAttemptsPerIP,
+		BaseLockout:      cfg.Auth.LoginLimit.BaseLockout,
+		MaxLockout:       cfg.Auth.LoginLimit.MaxLockout,
+		Window:           cfg.Auth.LoginLimit.Window,
+	})
 
-	authHandler := handlers.NewAuthHandler(log, authService)
+	authHandler := handlers.NewAuthHandler(log, authService, loginLimiter)
 	userHandler := handlers.NewUserHandler(log, userService)
+	accountHandler := handlers.NewAccountHandler(log, accountService)
+	adminHandler := handlers.NewAdminHandler(log, adminService)
+	merchHandler := handlers.NewMerchHandler(log, merchService)
+	catalogHandler := handlers.NewCatalogHandler(log, catalogService)
+	purchaseHandler := handlers.NewPurchaseHandler(log, purchaseService)
+	keysHandler := handlers.NewKeysHandler(jwtGen)
 
-	authMiddleware := middlewares.NewAuthMiddleware(jwtGen)
+	authMiddleware := middlewares.NewAuthMiddleware(jwtGen, redisDB)
+	idempotencyMiddleware := middlewares.NewIdempotencyMiddleware(log, storage, cfg.Idempotency.KeyTTL)
 
-	r := routes.InitRoutes(authHandler, userHandler, authMiddleware)
+	r := routes.InitRoutes(authHandler, userHandler, accountHandler, adminHandler, merchHandler, catalogHandler,
+		purchaseHandler, keysHandler, authMiddleware, idempotencyMiddleware, cfg.API)
 
-	server := httpserver.NewServer(log, serverPort, r)
+	server := httpserver.NewServer(log, cfg.Server.Address, r)
 
 	return &App{
 		HTTPServer: server,
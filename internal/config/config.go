@@ -18,9 +18,65 @@ type DatabaseConfig struct {
 }
 
 type JWTConfig struct {
-	Secret                  string `env:"JWT_SECRET,required"`
-	AccessExpirationMinutes int    `env:"ACCESS_EXPIRATION_MINUTES" envDefault:"15"`
-	RefreshExpirationDays   int    `env:"REFRESH_EXPIRATION_DAYS" envDefault:"7"`
+	Secret                  string        `env:"JWT_SECRET"` // обязателен, если не задан JWT_KEYS_DIR
+	AccessExpirationMinutes int           `env:"ACCESS_EXPIRATION_MINUTES" envDefault:"15"`
+	RefreshExpirationDays   int           `env:"REFRESH_EXPIRATION_DAYS" envDefault:"7"`
+	Issuer                  string        `env:"JWT_ISSUER" envDefault:"avito-shop"`
+	Audience                string        `env:"JWT_AUDIENCE" envDefault:"avito-shop-api"`
+	Leeway                  time.Duration `env:"JWT_LEEWAY" envDefault:"30s"`
+	KeysDir                 string        `env:"JWT_KEYS_DIR"` // пусто - подпись HS512 секретом JWT_SECRET
+	KeysReloadInterval      time.Duration `env:"JWT_KEYS_RELOAD_INTERVAL" envDefault:"1m"`
+	LegacySecretUntil       time.Time     `env:"JWT_LEGACY_SECRET_UNTIL"` // до этого момента при JWT_KEYS_DIR принимаются токены HS512
+}
+
+type AuthConfig struct {
+	AutoRegister     bool          `env:"AUTH_AUTO_REGISTER" envDefault:"true"` // создавать пользователя при первом входе через /api/auth
+	PasswordResetTTL time.Duration `env:"PASSWORD_RESET_TTL" envDefault:"30m"`
+	LoginLimit       LoginLimitConfig
+	PasswordHash     PasswordHashConfig
+}
+
+// PasswordHashConfig - алгоритм хеширования паролей. Хеши, созданные другим алгоритмом или с другими
+// параметрами, пересчитываются при следующем успешном входе пользователя.
+type PasswordHashConfig struct {
+	Algorithm         string `env:"PASSWORD_HASH_ALGORITHM" envDefault:"bcrypt"` // bcrypt, argon2id
+	BcryptCost        int    `env:"BCRYPT_COST" envDefault:"10"`
+	Argon2Memory      uint32 `env:"ARGON2_MEMORY_KIB" envDefault:"65536"`
+	Argon2Iterations  uint32 `env:"ARGON2_ITERATIONS" envDefault:"3"`
+	Argon2Parallelism uint8  `env:"ARGON2_PARALLELISM" envDefault:"2"`
+}
+
+// LoginLimitConfig - защита /api/auth от перебора паролей.
+type LoginLimitConfig struct {
+	MaxAttempts      int           `env:"LOGIN_MAX_ATTEMPTS" envDefault:"5"`
+	MaxAttemptsPerIP int           `env:"LOGIN_MAX_ATTEMPTS_PER_IP" envDefault:"50"`
+	BaseLockout      time.Duration `env:"LOGIN_BASE_LOCKOUT" envDefault:"30s"`
+	MaxLockout       time.Duration `env:"LOGIN_MAX_LOCKOUT" envDefault:"15m"`
+	Window           time.Duration `env:"LOGIN_FAILURE_WINDOW" envDefault:"1h"`
+}
+
+// IdempotencyConfig - сколько хранятся ответы на запросы с заголовком Idempotency-Key.
+type IdempotencyConfig struct {
+	KeyTTL time.Duration `env:"IDEMPOTENCY_KEY_TTL" envDefault:"24h"`
+}
+
+// MerchConfig - сколько каталог товаров кешируется в памяти процесса.
+type MerchConfig struct {
+	CacheTTL time.Duration `env:"MERCH_CACHE_TTL" envDefault:"30s"`
+}
+
+// APIConfig - совместимость со старыми клиентами API.
+type APIConfig struct {
+	AllowGetBuy bool `env:"API_ALLOW_GET_BUY" envDefault:"true"` // устаревшая покупка через GET /api/buy/:item
+}
+
+// PurchaseConfig - сколько времени после покупки ее можно вернуть.
+type PurchaseConfig struct {
+	ReturnWindow time.Duration `env:"PURCHASE_RETURN_WINDOW" envDefault:"336h"`
+}
+
+type MailerConfig struct {
+	File string `env:"MAILER_FILE"` // пусто - письма пишутся в лог
 }
 
 type RedisConfig struct {
@@ -28,9 +84,15 @@ type RedisConfig struct {
 }
 
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	JWT      JWTConfig
+	Server      ServerConfig
+	Database    DatabaseConfig
+	JWT         JWTConfig
+	Auth        AuthConfig
+	Mailer      MailerConfig
+	Idempotency IdempotencyConfig
+	Merch       MerchConfig
+	API         APIConfig
+	Purchase    PurchaseConfig
 }
 
 const (
@@ -62,6 +124,121 @@ func MustLoad() *Config {
 		panic("Invalid REFRESH_EXPIRATION_DAYS format: " + err.Error())
 	}
 
+	leeway, err := time.ParseDuration(getEnv("JWT_LEEWAY", "30s"))
+	if err != nil {
+		panic("Invalid JWT_LEEWAY format: " + err.Error())
+	}
+
+	keysReloadInterval, err := time.ParseDuration(getEnv("JWT_KEYS_RELOAD_INTERVAL", "1m"))
+	if err != nil {
+		panic("Invalid JWT_KEYS_RELOAD_INTERVAL format: " + err.Error())
+	}
+
+	jwtSecret, keysDir := os.Getenv("JWT_SECRET"), os.Getenv("JWT_KEYS_DIR")
+	if jwtSecret == "" && keysDir == "" {
+		panic("JWT_SECRET is required when JWT_KEYS_DIR is not set")
+	}
+
+	var legacySecretUntil time.Time
+	if until := os.Getenv("JWT_LEGACY_SECRET_UNTIL"); until != "" {
+		legacySecretUntil, err = time.Parse(time.RFC3339, until)
+		if err != nil {
+			panic("Invalid JWT_LEGACY_SECRET_UNTIL format: " + err.Error())
+		}
+		if jwtSecret == "" || keysDir == "" {
+			panic("JWT_LEGACY_SECRET_UNTIL requires both JWT_SECRET and JWT_KEYS_DIR")
+		}
+		// переходный период не длиннее срока жизни refresh токенов: дольше старые токены не живут
+		if legacySecretUntil.After(time.Now().Add(24 * time.Hour * time.Duration(refreshExp))) {
+			panic("JWT_LEGACY_SECRET_UNTIL must not be later than REFRESH_EXPIRATION_DAYS from now")
+		}
+	}
+
+	autoRegister, err := strconv.ParseBool(getEnv("AUTH_AUTO_REGISTER", "true"))
+	if err != nil {
+		panic("Invalid AUTH_AUTO_REGISTER format: " + err.Error())
+	}
+
+	passwordResetTTL, err := time.ParseDuration(getEnv("PASSWORD_RESET_TTL", "30m"))
+	if err != nil {
+		panic("Invalid PASSWORD_RESET_TTL format: " + err.Error())
+	}
+
+	loginMaxAttempts, err := strconv.Atoi(getEnv("LOGIN_MAX_ATTEMPTS", "5"))
+	if err != nil {
+		panic("Invalid LOGIN_MAX_ATTEMPTS format: " + err.Error())
+	}
+
+	loginMaxAttemptsPerIP, err := strconv.Atoi(getEnv("LOGIN_MAX_ATTEMPTS_PER_IP", "50"))
+	if err != nil {
+		panic("Invalid LOGIN_MAX_ATTEMPTS_PER_IP format: " + err.Error())
+	}
+
+	loginBaseLockout, err := time.ParseDuration(getEnv("LOGIN_BASE_LOCKOUT", "30s"))
+	if err != nil {
+		panic("Invalid LOGIN_BASE_LOCKOUT format: " + err.Error())
+	}
+
+	loginMaxLockout, err := time.ParseDuration(getEnv("LOGIN_MAX_LOCKOUT", "15m"))
+	if err != nil {
+		panic("Invalid LOGIN_MAX_LOCKOUT format: " + err.Error())
+	}
+
+	loginFailureWindow, err := time.ParseDuration(getEnv("LOGIN_FAILURE_WINDOW", "1h"))
+	if err != nil {
+		panic("Invalid LOGIN_FAILURE_WINDOW format: " + err.Error())
+	}
+
+	// нулевая блокировка ушла бы в Redis как ключ без срока жизни и заблокировала бы вход навсегда
+	switch {
+	case loginMaxAttempts <= 0 || loginMaxAttemptsPerIP <= 0:
+		panic("LOGIN_MAX_ATTEMPTS and LOGIN_MAX_ATTEMPTS_PER_IP must be positive")
+	case loginBaseLockout <= 0 || loginMaxLockout <= 0 || loginFailureWindow <= 0:
+		panic("LOGIN_BASE_LOCKOUT, LOGIN_MAX_LOCKOUT and LOGIN_FAILURE_WINDOW must be positive")
+	case loginMaxLockout < loginBaseLockout:
+		panic("LOGIN_MAX_LOCKOUT must not be less than LOGIN_BASE_LOCKOUT")
+	}
+
+	bcryptCost, err := strconv.Atoi(getEnv("BCRYPT_COST", "10"))
+	if err != nil {
+		panic("Invalid BCRYPT_COST format: " + err.Error())
+	}
+
+	argon2Memory, err := strconv.ParseUint(getEnv("ARGON2_MEMORY_KIB", "65536"), 10, 32)
+	if err != nil {
+		panic("Invalid ARGON2_MEMORY_KIB format: " + err.Error())
+	}
+
+	argon2Iterations, err := strconv.ParseUint(getEnv("ARGON2_ITERATIONS", "3"), 10, 32)
+	if err != nil {
+		panic("Invalid ARGON2_ITERATIONS format: " + err.Error())
+	}
+
+	argon2Parallelism, err := strconv.ParseUint(getEnv("ARGON2_PARALLELISM", "2"), 10, 8)
+	if err != nil {
+		panic("Invalid ARGON2_PARALLELISM format: " + err.Error())
+	}
+
+	idempotencyKeyTTL, err := time.ParseDuration(getEnv("IDEMPOTENCY_KEY_TTL", "24h"))
+	if err != nil {
+		panic("Invalid IDEMPOTENCY_KEY_TTL format: " + err.Error())
+	}
+
+	merchCacheTTL, err := time.ParseDuration(getEnv("MERCH_CACHE_TTL", "30s"))
+	if err != nil {
+		panic("Invalid MERCH_CACHE_TTL format: " + err.Error())
+	}
+
+	allowGetBuy, err := strconv.ParseBool(getEnv("API_ALLOW_GET_BUY", "true"))
+	if err != nil {
+		panic("Invalid API_ALLOW_GET_BUY format: " + err.Error())
+	}
+
+	returnWindow, err := time.ParseDuration(getEnv("PURCHASE_RETURN_WINDOW", "336h"))
+	if err != nil {
+		panic("Invalid PURCHASE_RETURN_WINDOW format: " + err.Error())
+	}
+
 	return &Config{
 		Server: ServerConfig{
 			Env:     os.Getenv("ENV"),
@@ -72,9 +249,55 @@ func MustLoad() *Config {
 			PostgresConn: os.Getenv("POSTGRES_CONN"),
 		},
 		JWT: JWTConfig{
-			Secret:                  os.Getenv("JWT_SECRET"),
+			Secret:                  jwtSecret,
 			AccessExpirationMinutes: accessExp,
 			RefreshExpirationDays:   refreshExp,
+			Issuer:                  getEnv("JWT_ISSUER", "avito-shop"),
+			Audience:                getEnv("JWT_AUDIENCE", "avito-shop-api"),
+			Leeway:                  leeway,
+			KeysDir:                 keysDir,
+			KeysReloadInterval:      keysReloadInterval,
+			LegacySecretUntil:       legacySecretUntil,
+		},
+		Auth: AuthConfig{
+			AutoRegister:     autoRegister,
+			PasswordResetTTL: passwordResetTTL,
+			LoginLimit: LoginLimitConfig{
+				MaxAttempts:      loginMaxAttempts,
+				MaxAttemptsPerIP: loginMaxAttemptsPerIP,
+				BaseLockout:      loginBaseLockout,
+				MaxLockout:       loginMaxLockout,
+				Window:           loginFailureWindow,
+			},
+			PasswordHash: PasswordHashConfig{
+				Algorithm:         getEnv("PASSWORD_HASH_ALGORITHM", "bcrypt"),
+				BcryptCost:        bcryptCost,
+				Argon2Memory:      uint32(argon2Memory),
+				Argon2Iterations:  uint32(argon2Iterations),
+				Argon2Parallelism: uint8(argon2Parallelism),
+			},
+		},
+		Mailer: MailerConfig{
+			File: os.Getenv("MAILER_FILE"),
+		},
+		Idempotency: IdempotencyConfig{
+			KeyTTL: idempotencyKeyTTL,
+		},
+		Merch: MerchConfig{
+			CacheTTL: merchCacheTTL,
 		},
+		API: APIConfig{
+			AllowGetBuy: allowGetBuy,
+		},
+		Purchase: PurchaseConfig{
+			ReturnWindow: returnWindow,
+		},
+	}
+}
+
+func getEnv(key, defaultValue string) string {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		return value
 	}
+	return defaultValue
 }
@@ -0,0 +1,20 @@
+package dto
+
+// swagger:model
+type AdjustBalanceRequest struct {
+	Amount int    `json:"amount" example:"500"` // положительное значение начисляет монеты, отрицательное списывает
+	Reason string `json:"reason" example:"compensation for failed purchase"`
+}
+
+// swagger:model
+type AdjustBalanceResponse struct {
+	Username string `json:"username" example:"johndoe"`
+	Coins    int    `json:"coins" example:"1500"`
+}
+
+// swagger:model
+type BalanceRecomputeDTO struct {
+	Username string `json:"username" example:"johndoe"`
+	Cached   int    `json:"cached" example:"1500"` // значение users.coins до пересчета
+	Ledger   int    `json:"ledger" example:"1500"` // баланс по журналу проводок, записан в users.coins
+}
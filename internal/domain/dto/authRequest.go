@@ -2,6 +2,6 @@ package dto
 
 // swagger:model
 type AuthRequest struct {
-	Username string `json:"username" example:"johndoe"`
+	Username string `json:"username" example:"johndoe"` // имя пользователя или email
 	Password string `json:"password" example:"secret"`
 }
@@ -0,0 +1,25 @@
+package dto
+
+// swagger:model
+type CheckoutItem struct {
+	Item     string `json:"item" example:"pen"`
+	Quantity int    `json:"quantity" example:"5"`
+}
+
+// swagger:model
+type CheckoutRequest struct {
+	Items []CheckoutItem `json:"items"`
+}
+
+// CheckoutLineDTO - купленная позиция, Price - цена за штуку на момент покупки.
+type CheckoutLineDTO struct {
+	Item     string `json:"item" example:"pen"`
+	Quantity int    `json:"quantity" example:"5"`
+	Price    int    `json:"price" example:"1000"`
+}
+
+// swagger:model
+type CheckoutResponse struct {
+	Items []CheckoutLineDTO `json:"items"`
+	Total int               `json:"total" example:"5000"`
+}
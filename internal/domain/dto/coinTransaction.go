@@ -1,6 +1,16 @@
 package dto
 
-type CoinTransactionDTO struct {
-	Username    string `json:"username" db:"username"`
-	TotalAmount int    `json:"total_amount" db:"total_amount"`
+// DeletedUsername подставляется вместо имени второй стороны перевода, если ее аккаунт удален.
+const DeletedUsername = "[deleted]"
+
+// ReceivedCoinsDTO - сколько всего монет пользователь получил от FromUser.
+type ReceivedCoinsDTO struct {
+	FromUser string `json:"fromUser" example:"alice"`
+	Amount   int    `json:"amount" example:"100"`
+}
+
+// SentCoinsDTO - сколько всего монет пользователь отправил ToUser.
+type SentCoinsDTO struct {
+	ToUser string `json:"toUser" example:"bob"`
+	Amount int    `json:"amount" example:"100"`
 }
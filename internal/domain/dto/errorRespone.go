@@ -3,4 +3,5 @@ package dto
 // swagger:model
 type ErrorResponse struct {
 	Errors string `json:"errors" example:"error description"`
+	Code   string `json:"code,omitempty" example:"insufficient_funds"` // стабильный машиночитаемый код ошибки
 }
@@ -0,0 +1,34 @@
+package dto
+
+import (
+	"time"
+)
+
+// swagger:model
+type GiftRequest struct {
+	ToUser  string `json:"toUser" example:"bob"`
+	Item    string `json:"item" example:"t-shirt"`
+	Message string `json:"message" example:"С днем рождения!"` // необязательно
+}
+
+// ReceivedGiftDTO - подарок, полученный от FromUser.
+type ReceivedGiftDTO struct {
+	FromUser  string    `json:"fromUser" example:"alice"`
+	Merch     string    `json:"merch" example:"t-shirt"`
+	Message   string    `json:"message,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// SentGiftDTO - подарок, отправленный ToUser.
+type SentGiftDTO struct {
+	ToUser    string    `json:"toUser" example:"bob"`
+	Merch     string    `json:"merch" example:"t-shirt"`
+	Message   string    `json:"message,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// GiftHistoryDTO - подарки пользователя от новых к старым.
+type GiftHistoryDTO struct {
+	Received []ReceivedGiftDTO `json:"received"`
+	Sent     []SentGiftDTO     `json:"sent"`
+}
@@ -5,4 +5,5 @@ type InfoResponse struct {
 	Coins       int            `json:"coins" example:"100000"`
 	Inventory   []PurchaseDTO  `json:"inventory"`
 	CoinHistory TransactionDTO `json:"coin_history"`
+	Gifts       GiftHistoryDTO `json:"gifts"`
 }
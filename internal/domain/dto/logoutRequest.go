@@ -0,0 +1,6 @@
+package dto
+
+// swagger:model
+type LogoutRequest struct {
+	RefreshToken string `json:"refreshToken" example:"eyJhbGciOiJIUzUxMiIsInR5cCI6IkpXVCJ9..."`
+}
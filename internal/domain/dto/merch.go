@@ -0,0 +1,48 @@
+package dto
+
+import (
+	"time"
+)
+
+// MerchDTO - товар каталога. Available показывает, можно ли купить товар прямо сейчас: он не снят с продажи,
+// не закончился и продажа открыта. ArchivedAt заполнен только у архивных товаров, которые видят лишь администраторы.
+type MerchDTO struct {
+	Name        string     `json:"name" example:"t-shirt"`
+	Price       int        `json:"price" example:"8000"`
+	Available   bool       `json:"available" example:"true"`
+	Description string     `json:"description" example:"Футболка с логотипом Авито"`
+	ArchivedAt  *time.Time `json:"archivedAt,omitempty"`
+	MerchLimits
+}
+
+// MerchLimits - ограничения продажи товара, nil означает отсутствие ограничения.
+// swagger:model
+type MerchLimits struct {
+	Stock        *int       `json:"stock" example:"100"`      // остаток на складе
+	PerUserLimit *int       `json:"perUserLimit" example:"1"` // сколько штук может купить один пользователь
+	SaleStartsAt *time.Time `json:"saleStartsAt"`
+	SaleEndsAt   *time.Time `json:"saleEndsAt"`
+}
+
+// swagger:model
+type MerchListResponse struct {
+	Items []MerchDTO `json:"items"`
+}
+
+// swagger:model
+type CreateMerchRequest struct {
+	Name        string `json:"name" example:"sticker"`
+	Price       int    `json:"price" example:"500"`
+	Description string `json:"description" example:"Набор стикеров"`
+	Available   *bool  `json:"available" example:"true"` // по умолчанию true
+	MerchLimits
+}
+
+// UpdateMerchRequest - изменение товара, поля без значения не меняются.
+// swagger:model
+type UpdateMerchRequest struct {
+	Name        *string `json:"name" example:"sticker-pack"`
+	Price       *int    `json:"price" example:"700"`
+	Description *string `json:"description"`
+	Available   *bool   `json:"available"`
+}
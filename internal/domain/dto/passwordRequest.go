@@ -0,0 +1,18 @@
+package dto
+
+// swagger:model
+type ChangePasswordRequest struct {
+	OldPassword string `json:"oldPassword" example:"secret123"`
+	NewPassword string `json:"newPassword" example:"newSecret123"`
+}
+
+// swagger:model
+type PasswordResetRequest struct {
+	Email string `json:"email" example:"johndoe@example.com"`
+}
+
+// swagger:model
+type PasswordResetConfirmRequest struct {
+	Token       string `json:"token" example:"3q2-7wEj6Yk0Zb..."`
+	NewPassword string `json:"newPassword" example:"newSecret123"`
+}
@@ -1,6 +1,27 @@
 package dto
 
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// PurchaseDTO - позиция инвентаря. Amount - сколько штук у пользователя сейчас, Returned - сколько возвращено.
 type PurchaseDTO struct {
-	Merch  string `json:"merch" db:"merch"`
-	Amount int    `json:"amount" db:"amount"`
+	Merch    string `json:"merch" db:"merch"`
+	Amount   int    `json:"amount" db:"amount"`
+	Returned int    `json:"returned,omitempty" db:"returned"`
+}
+
+// PurchaseRecordDTO - отдельная покупка, Price - цена на момент покупки.
+type PurchaseRecordDTO struct {
+	ID         uuid.UUID  `json:"id"`
+	Merch      string     `json:"merch" example:"cup"`
+	Price      int        `json:"price" example:"2000"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	ReturnedAt *time.Time `json:"returnedAt,omitempty"`
+}
+
+// swagger:model
+type PurchasesResponse struct {
+	Purchases []PurchaseRecordDTO `json:"purchases"`
 }
@@ -0,0 +1,30 @@
+package dto
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// BalanceDriftDTO - баланс пользователя в сравнении с журналом проводок и с ожидаемым по истории операций.
+type BalanceDriftDTO struct {
+	UserID      uuid.UUID `json:"userId"`
+	Username    string    `json:"username"`
+	Coins       int       `json:"coins"`       // users.coins
+	Expected    int       `json:"expected"`    // стартовые монеты - покупки - отправленные + полученные + корректировки
+	Ledger      int       `json:"ledger"`      // баланс счета по журналу проводок
+	Drift       int       `json:"drift"`       // coins - expected
+	LedgerDrift int       `json:"ledgerDrift"` // coins - ledger
+	Corrected   bool      `json:"corrected"`   // users.coins и история приведены к журналу
+}
+
+// ReconcileReport - результат сверки балансов всех пользователей.
+type ReconcileReport struct {
+	CheckedAt time.Time         `json:"checkedAt"`
+	Users     int               `json:"users"`
+	Drifts    []BalanceDriftDTO `json:"drifts"`
+}
+
+// HasDrift сообщает, что users.coins расходится с историей операций или с журналом.
+func (d BalanceDriftDTO) HasDrift() bool {
+	return d.Drift != 0 || d.LedgerDrift != 0
+}
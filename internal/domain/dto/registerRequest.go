@@ -0,0 +1,8 @@
+package dto
+
+// swagger:model
+type RegisterRequest struct {
+	Username string `json:"username" example:"johndoe"`
+	Email    string `json:"email" example:"johndoe@example.com"`
+	Password string `json:"password" example:"secret123"`
+}
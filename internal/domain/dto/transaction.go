@@ -1,6 +1,6 @@
 package dto
 
 type TransactionDTO struct {
-	Received []CoinTransactionDTO
-	Sent     []CoinTransactionDTO
+	Received []ReceivedCoinsDTO `json:"received"`
+	Sent     []SentCoinsDTO     `json:"sent"`
 }
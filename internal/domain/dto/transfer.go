@@ -0,0 +1,47 @@
+package dto
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	DirectionSent     = "sent"
+	DirectionReceived = "received"
+)
+
+// TransferDTO - один перевод монет в истории пользователя.
+type TransferDTO struct {
+	ID           uuid.UUID `json:"id"`
+	Direction    string    `json:"direction" example:"sent"`
+	Counterparty string    `json:"counterparty" example:"bob"`
+	Amount       int       `json:"amount" example:"100"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// TransactionsRequest - параметры GET /api/transactions. Даты принимаются в RFC 3339 или как YYYY-MM-DD,
+// from включается в выборку, to - нет.
+type TransactionsRequest struct {
+	Direction string `form:"direction" example:"sent"`
+	From      string `form:"from" example:"2026-10-01"`
+	To        string `form:"to" example:"2026-11-01"`
+	Cursor    string `form:"cursor"`
+	Limit     int    `form:"limit" example:"20"`
+}
+
+// swagger:model
+type TransactionsResponse struct {
+	Transactions []TransferDTO `json:"transactions"`
+	NextCursor   string        `json:"nextCursor,omitempty"`
+}
+
+// TransferFilter - выборка переводов для репозитория. Нулевые значения полей означают отсутствие ограничения.
+// Переводы отдаются от новых к старым, начиная с переводов строго старше (BeforeCreatedAt, BeforeID).
+type TransferFilter struct {
+	Direction       string
+	From            time.Time
+	To              time.Time
+	BeforeCreatedAt time.Time
+	BeforeID        uuid.UUID
+	Limit           int
+}
@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+)
+
+// IdempotencyKey - сохраненный результат запроса с заголовком Idempotency-Key.
+// StatusCode равен 0, пока первый запрос с этим ключом еще выполняется.
+type IdempotencyKey struct {
+	UserID       string    `json:"user_id" db:"user_id"`
+	Key          string    `json:"key" db:"key"`
+	RequestHash  string    `json:"request_hash" db:"request_hash"`
+	StatusCode   int       `json:"status_code" db:"status_code"`
+	ResponseBody []byte    `json:"response_body" db:"response_body"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+}
@@ -9,11 +9,17 @@ import (
 // ну я хочу чтобы у меня были кофты по 5.99 монет и буду писать код как хочу
 // будет хранить количество монет в копейках и умножать на 100 чтобы пользователю выводить приятный глазу вид
 
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
 type User struct {
 	ID        uuid.UUID `json:"id" db:"id"`
 	Username  string    `json:"username" db:"username"`
 	Email     string    `json:"email" db:"email"`
 	Password  []byte    `json:"password" db:"password"`
 	Coins     int       `json:"coins" db:"coins"` // храним в копейках если что чтбоы было проще хранить и перегонять в большую валюту
+	Role      string    `json:"role" db:"role"`
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 }
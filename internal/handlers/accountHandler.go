@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"avito-shop/internal/domain/dto"
+	"avito-shop/internal/middlewares"
+	"avito-shop/internal/services"
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+type AccountService interface {
+	ChangePassword(ctx context.Context, userID uuid.UUID, oldPassword, newPassword string) error
+	RequestPasswordReset(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, token, newPassword string) error
+}
+
+type AccountHandler struct {
+	log            *slog.Logger
+	accountService AccountService
+}
+
+func NewAccountHandler(log *slog.Logger, accountService AccountService) *AccountHandler {
+	return &AccountHandler{
+		log:            log,
+		accountService: accountService,
+	}
+}
+
+// ChangePassword
+// @Summary Смена пароля
+// @Description Меняет пароль после проверки текущего. Все сессии пользователя завершаются, нужно войти заново.
+// @Tags account
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param password body dto.ChangePasswordRequest true "Текущий и новый пароль"
+// @Success 200 {string} string "Пароль изменен"
+// @Failure 400 {object} dto.ErrorResponse "Неверный запрос"
+// @Failure 401 {object} dto.ErrorResponse "Неавторизован"
+// @Failure 500 {object} dto.ErrorResponse "Внутренняя ошибка сервера"
+// @Router /api/account/password [post]
+func (h *AccountHandler) ChangePassword(c *gin.Context) {
+	var input dto.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	err = h.accountService.ChangePassword(c.Request.Context(), userID, input.OldPassword, input.NewPassword)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidCredentials):
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Old password is incorrect"})
+		case errors.Is(err, middlewares.ErrEmptyField):
+			c.JSON(http.StatusBadRequest, gin.H{"message": middlewares.ErrEmptyField.Error()})
+		case errors.Is(err, middlewares.ErrPasswordTooShort):
+			c.JSON(http.StatusBadRequest, gin.H{"message": middlewares.ErrPasswordTooShort.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Server error"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "Password changed, please log in again",
+		"time":    time.Now().Format(time.RFC3339),
+	})
+}
+
+// RequestPasswordReset
+// @Summary Запрос на сброс пароля
+// @Description Отправляет на email одноразовый код сброса пароля. Ответ не зависит от того, существует ли аккаунт.
+// @Tags account
+// @Accept json
+// @Produce json
+// @Param reset body dto.PasswordResetRequest true "Email аккаунта"
+// @Success 202 {string} string "Запрос принят"
+// @Failure 400 {object} dto.ErrorResponse "Неверный запрос"
+// @Failure 500 {object} dto.ErrorResponse "Внутренняя ошибка сервера"
+// @Router /api/auth/password/forgot [post]
+func (h *AccountHandler) RequestPasswordReset(c *gin.Context) {
+	var input dto.PasswordResetRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.accountService.RequestPasswordReset(c.Request.Context(), input.Email); err != nil {
+		if errors.Is(err, middlewares.ErrInvalidEmail) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": middlewares.ErrInvalidEmail.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Server error"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"status":  "success",
+		"message": "If the account exists, a reset code has been sent",
+		"time":    time.Now().Format(time.RFC3339),
+	})
+}
+
+// ResetPassword
+// @Summary Сброс пароля по коду из письма
+// @Description Устанавливает новый пароль по одноразовому коду. Все сессии пользователя завершаются.
+// @Tags account
+// @Accept json
+// @Produce json
+// @Param reset body dto.PasswordResetConfirmRequest true "Код сброса и новый пароль"
+// @Success 200 {string} string "Пароль изменен"
+// @Failure 400 {object} dto.ErrorResponse "Неверный запрос"
+// @Failure 500 {object} dto.ErrorResponse "Внутренняя ошибка сервера"
+// @Router /api/auth/password/reset [post]
+func (h *AccountHandler) ResetPassword(c *gin.Context) {
+	var input dto.PasswordResetConfirmRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.accountService.ResetPassword(c.Request.Context(), input.Token, input.NewPassword); err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidResetToken):
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid or expired reset code"})
+		case errors.Is(err, middlewares.ErrEmptyField):
+			c.JSON(http.StatusBadRequest, gin.H{"message": middlewares.ErrEmptyField.Error()})
+		case errors.Is(err, middlewares.ErrPasswordTooShort):
+			c.JSON(http.StatusBadRequest, gin.H{"message": middlewares.ErrPasswordTooShort.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Server error"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "Password reset, please log in",
+		"time":    time.Now().Format(time.RFC3339),
+	})
+}
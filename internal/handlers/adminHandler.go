@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"avito-shop/internal/domain/dto"
+	"context"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"log/slog"
+	"net/http"
+)
+
+type AdminService interface {
+	AdjustBalance(ctx context.Context, adminID uuid.UUID, username string, amount int, reason string) (int, error)
+	RecomputeBalance(ctx context.Context, adminID uuid.UUID, username string) (dto.BalanceRecomputeDTO, error)
+}
+
+type AdminHandler struct {
+	log          *slog.Logger
+	adminService AdminService
+}
+
+func NewAdminHandler(log *slog.Logger, adminService AdminService) *AdminHandler {
+	return &AdminHandler{
+		log:          log,
+		adminService: adminService,
+	}
+}
+
+// AdjustBalance
+// @Summary Корректировка баланса пользователя
+// @Description Начисляет или списывает монеты пользователю. Каждая корректировка сохраняется с причиной и автором.
+// @Tags admin
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param username path string true "Имя пользователя"
+// @Param adjustment body dto.AdjustBalanceRequest true "Сумма и причина корректировки"
+// @Success 200 {object} dto.AdjustBalanceResponse "Новый баланс"
+// @Failure 400 {object} dto.ErrorResponse "Неверный запрос"
+// @Failure 401 {object} dto.ErrorResponse "Неавторизован"
+// @Failure 403 {object} dto.ErrorResponse "Требуется роль admin"
+// @Failure 404 {object} dto.ErrorResponse "Пользователь не найден"
+// @Failure 500 {object} dto.ErrorResponse "Внутренняя ошибка сервера"
+// @Router /api/admin/users/{username}/balance [post]
+func (h *AdminHandler) AdjustBalance(c *gin.Context) {
+	var input dto.AdjustBalanceRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		_ = c.Error(fmt.Errorf("%w: %s", ErrInvalidRequest, err))
+		return
+	}
+
+	adminID, err := currentUserID(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	username := c.Param("username")
+
+	coins, err := h.adminService.AdjustBalance(c.Request.Context(), adminID, username, input.Amount, input.Reason)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.AdjustBalanceResponse{
+		Username: username,
+		Coins:    coins,
+	})
+}
+
+// RecomputeBalance
+// @Summary Пересчет баланса пользователя по журналу
+// @Description Считает баланс как сумму проводок по счету пользователя и записывает его в users.coins.
+// @Description В ответе - значение кеша до пересчета и баланс по журналу.
+// @Tags admin
+// @Security BearerAuth
+// @Produce json
+// @Param username path string true "Имя пользователя"
+// @Success 200 {object} dto.BalanceRecomputeDTO "Баланс до и после пересчета"
+// @Failure 401 {object} dto.ErrorResponse "Неавторизован"
+// @Failure 403 {object} dto.ErrorResponse "Требуется роль admin"
+// @Failure 404 {object} dto.ErrorResponse "Пользователь не найден"
+// @Failure 500 {object} dto.ErrorResponse "Внутренняя ошибка сервера"
+// @Router /api/admin/users/{username}/balance/recompute [post]
+func (h *AdminHandler) RecomputeBalance(c *gin.Context) {
+	adminID, err := currentUserID(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	result, err := h.adminService.RecomputeBalance(c.Request.Context(), adminID, c.Param("username"))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
@@ -2,34 +2,51 @@ package handlers
 
 import (
 	"avito-shop/internal/domain/dto"
+	"avito-shop/internal/middlewares"
 	"avito-shop/internal/services"
 	"context"
 	"errors"
 	"github.com/gin-gonic/gin"
 	"log/slog"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 )
 
 type AuthService interface {
 	Login(ctx context.Context, username, password string) (accessToken string, refreshToken string, err error)
+	Register(ctx context.Context, username, email, password string) (accessToken string, refreshToken string, err error)
+	Refresh(ctx context.Context, refreshToken string) (accessToken string, newRefreshToken string, err error)
+	Logout(ctx context.Context, userID, accessTokenID string, accessExpiresAt time.Time, refreshToken string) error
+	LogoutAll(ctx context.Context, userID, accessTokenID string, accessExpiresAt time.Time) error
+}
+
+type LoginLimiter interface {
+	Identify(ctx context.Context, login string) (services.LoginIdentity, error)
+	Check(ctx context.Context, identity services.LoginIdentity, ip string) error
+	Fail(ctx context.Context, identity services.LoginIdentity, ip string) error
+	Succeed(ctx context.Context, identity services.LoginIdentity) error
 }
 
 type AuthHandler struct {
-	log         *slog.Logger
-	authService AuthService
+	log          *slog.Logger
+	authService  AuthService
+	loginLimiter LoginLimiter
 }
 
-func NewAuthHandler(log *slog.Logger, authService AuthService) *AuthHandler {
+func NewAuthHandler(log *slog.Logger, authService AuthService, loginLimiter LoginLimiter) *AuthHandler {
 	return &AuthHandler{
-		log:         log,
-		authService: authService,
+		log:          log,
+		authService:  authService,
+		loginLimiter: loginLimiter,
 	}
 }
 
 // Auth
 // @Summary Аутентификация и получение JWT-токена
-// @Description При первой аутентификации пользователь создается автоматически.
+// @Description В поле username можно передать имя пользователя или email.
+// @Description При первой аутентификации по имени пользователь создается автоматически, если это не отключено в конфигурации.
 // @Tags auth
 // @Accept  json
 // @Produce  json
@@ -37,17 +54,51 @@ func NewAuthHandler(log *slog.Logger, authService AuthService) *AuthHandler {
 // @Success 200 {object} dto.AuthResponse "Успешная аутентификация"
 // @Failure 400 {object} dto.ErrorResponse "Неверный запрос"
 // @Failure 401 {object} dto.ErrorResponse "Неавторизован"
+// @Failure 429 {object} dto.ErrorResponse "Слишком много неудачных попыток, повторить после Retry-After секунд"
 // @Failure 500 {object} dto.ErrorResponse "Внутренняя ошибка сервера"
 // @Router /api/auth [post]
 func (h *AuthHandler) Auth(c *gin.Context) {
+	const op = "handlers.AuthHandler.Auth"
+
+	log := h.log.With(
+		slog.String("op", op),
+	)
+
 	var input dto.AuthRequest
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	accessToken, refreshToken, err := h.authService.Login(c.Request.Context(), input.Username, input.Password)
+	ctx, ip := c.Request.Context(), c.ClientIP()
+
+	identity, err := h.loginLimiter.Identify(ctx, input.Username)
+	if err != nil {
+		log.Error("failed to identify login", slog.String("error", err.Error()))
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Server error"})
+		return
+	}
+
+	if err := h.loginLimiter.Check(ctx, identity, ip); err != nil {
+		var lockout *services.LockoutError
+		if errors.As(err, &lockout) {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(lockout.RetryAfter.Seconds()))))
+			c.JSON(http.StatusTooManyRequests, gin.H{"message": "Too many login attempts"})
+			return
+		}
+		log.Error("failed to check login lockout", slog.String("error", err.Error()))
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Server error"})
+		return
+	}
+
+	accessToken, refreshToken, err := h.authService.Login(ctx, input.Username, input.Password)
 	if err != nil {
+		if errors.Is(err, services.ErrInvalidCredentials) {
+			if err := h.loginLimiter.Fail(ctx, identity, ip); err != nil {
+				log.Error("failed to register login failure", slog.String("error", err.Error()))
+			}
+		}
+
 		if errors.Is(err, services.ErrFailedToGenerateTokens) || errors.Is(err, services.ErrFailedToStoreRefreshToken) {
 			c.JSON(http.StatusInternalServerError, gin.H{"message": "Server error"})
 		} else if errors.Is(err, services.ErrUserAlreadyExists) {
@@ -58,6 +109,10 @@ func (h *AuthHandler) Auth(c *gin.Context) {
 		return
 	}
 
+	if err := h.loginLimiter.Succeed(ctx, identity); err != nil {
+		log.Error("failed to reset login failures", slog.String("error", err.Error()))
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"status":       "success",
 		"message":      "Authorization successful",
@@ -66,3 +121,158 @@ func (h *AuthHandler) Auth(c *gin.Context) {
 		"time":         time.Now().Format(time.RFC3339),
 	})
 }
+
+// Register
+// @Summary Регистрация пользователя
+// @Description Создает пользователя с email и сразу выдает пару токенов.
+// @Tags auth
+// @Accept  json
+// @Produce  json
+// @Param   register body dto.RegisterRequest true "Данные для регистрации"
+// @Success 201 {object} dto.AuthResponse "Пользователь зарегистрирован"
+// @Failure 400 {object} dto.ErrorResponse "Неверный запрос"
+// @Failure 409 {object} dto.ErrorResponse "Имя пользователя или email заняты"
+// @Failure 500 {object} dto.ErrorResponse "Внутренняя ошибка сервера"
+// @Router /api/register [post]
+func (h *AuthHandler) Register(c *gin.Context) {
+	var input dto.RegisterRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	accessToken, refreshToken, err := h.authService.Register(c.Request.Context(), input.Username, input.Email, input.Password)
+	if err != nil {
+		switch {
+		case errors.Is(err, middlewares.ErrEmptyField):
+			c.JSON(http.StatusBadRequest, gin.H{"message": middlewares.ErrEmptyField.Error()})
+		case errors.Is(err, middlewares.ErrInvalidEmail):
+			c.JSON(http.StatusBadRequest, gin.H{"message": middlewares.ErrInvalidEmail.Error()})
+		case errors.Is(err, middlewares.ErrLoginTooShort):
+			c.JSON(http.StatusBadRequest, gin.H{"message": middlewares.ErrLoginTooShort.Error()})
+		case errors.Is(err, middlewares.ErrPasswordTooShort):
+			c.JSON(http.StatusBadRequest, gin.H{"message": middlewares.ErrPasswordTooShort.Error()})
+		case errors.Is(err, middlewares.ErrLoginIsEmail):
+			c.JSON(http.StatusBadRequest, gin.H{"message": middlewares.ErrLoginIsEmail.Error()})
+		case errors.Is(err, services.ErrUserAlreadyExists):
+			c.JSON(http.StatusConflict, gin.H{"message": "Username is already taken"})
+		case errors.Is(err, services.ErrEmailAlreadyExists):
+			c.JSON(http.StatusConflict, gin.H{"message": "Email is already registered"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Server error"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status":       "success",
+		"message":      "Registration successful",
+		"token":        accessToken,
+		"refreshToken": refreshToken,
+		"time":         time.Now().Format(time.RFC3339),
+	})
+}
+
+// Refresh
+// @Summary Обновление пары токенов по refresh токену
+// @Description Refresh токен одноразовый: в ответ выдается новая пара, старый токен перестает действовать.
+// @Description Повторное использование токена отзывает все токены, полученные из того же логина.
+// @Tags auth
+// @Accept  json
+// @Produce  json
+// @Param   refresh body dto.RefreshRequest true "Refresh токен"
+// @Success 200 {object} dto.AuthResponse "Токены обновлены"
+// @Failure 400 {object} dto.ErrorResponse "Неверный запрос"
+// @Failure 401 {object} dto.ErrorResponse "Неавторизован"
+// @Failure 500 {object} dto.ErrorResponse "Внутренняя ошибка сервера"
+// @Router /api/auth/refresh [post]
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var input dto.RefreshRequest
+	if err := c.ShouldBindJSON(&input); err != nil || input.RefreshToken == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Refresh token is required"})
+		return
+	}
+
+	accessToken, refreshToken, err := h.authService.Refresh(c.Request.Context(), input.RefreshToken)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidRefreshToken) || errors.Is(err, services.ErrRefreshTokenReused) {
+			c.JSON(http.StatusUnauthorized, gin.H{"message": "Unauthorized"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Server error"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":       "success",
+		"message":      "Tokens refreshed",
+		"token":        accessToken,
+		"refreshToken": refreshToken,
+		"time":         time.Now().Format(time.RFC3339),
+	})
+}
+
+// Logout
+// @Summary Выход из текущей сессии
+// @Description Отзывает текущий access токен и, если передан, refresh токен вместе со всей его цепочкой.
+// @Tags auth
+// @Security BearerAuth
+// @Accept  json
+// @Produce  json
+// @Param   logout body dto.LogoutRequest false "Refresh токен текущей сессии"
+// @Success 200 {string} string "Сессия завершена"
+// @Failure 400 {object} dto.ErrorResponse "Неверный запрос"
+// @Failure 401 {object} dto.ErrorResponse "Неавторизован"
+// @Failure 500 {object} dto.ErrorResponse "Внутренняя ошибка сервера"
+// @Router /api/auth/logout [post]
+func (h *AuthHandler) Logout(c *gin.Context) {
+	var input dto.LogoutRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request"})
+			return
+		}
+	}
+
+	err := h.authService.Logout(c.Request.Context(), c.GetString("user_id"), c.GetString("token_id"),
+		c.GetTime("token_expires_at"), input.RefreshToken)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidRefreshToken) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid refresh token"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Server error"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "Logged out",
+		"time":    time.Now().Format(time.RFC3339),
+	})
+}
+
+// LogoutAll
+// @Summary Выход со всех устройств
+// @Description Отзывает все refresh токены пользователя и все выданные ранее access токены.
+// @Tags auth
+// @Security BearerAuth
+// @Produce  json
+// @Success 200 {string} string "Все сессии завершены"
+// @Failure 401 {object} dto.ErrorResponse "Неавторизован"
+// @Failure 500 {object} dto.ErrorResponse "Внутренняя ошибка сервера"
+// @Router /api/auth/logout-all [post]
+func (h *AuthHandler) LogoutAll(c *gin.Context) {
+	err := h.authService.LogoutAll(c.Request.Context(), c.GetString("user_id"), c.GetString("token_id"),
+		c.GetTime("token_expires_at"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "Logged out from all sessions",
+		"time":    time.Now().Format(time.RFC3339),
+	})
+}
@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"avito-shop/internal/domain/dto"
+	"context"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"log/slog"
+	"net/http"
+)
+
+type CatalogService interface {
+	CreateItem(ctx context.Context, adminID uuid.UUID, req dto.CreateMerchRequest) (dto.MerchDTO, error)
+	UpdateItem(ctx context.Context, adminID uuid.UUID, name string, req dto.UpdateMerchRequest) (dto.MerchDTO, error)
+	ArchiveItem(ctx context.Context, adminID uuid.UUID, name string) (dto.MerchDTO, error)
+	RestoreItem(ctx context.Context, adminID uuid.UUID, name string) (dto.MerchDTO, error)
+	SetLimits(ctx context.Context, adminID uuid.UUID, name string, limits dto.MerchLimits) (dto.MerchDTO, error)
+}
+
+type CatalogHandler struct {
+	log            *slog.Logger
+	catalogService CatalogService
+}
+
+func NewCatalogHandler(log *slog.Logger, catalogService CatalogService) *CatalogHandler {
+	return &CatalogHandler{
+		log:            log,
+		catalogService: catalogService,
+	}
+}
+
+// CreateMerch
+// @Summary Добавить товар в каталог
+// @Tags admin
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param item body dto.CreateMerchRequest true "Новый товар"
+// @Success 201 {object} dto.MerchDTO "Созданный товар"
+// @Failure 400 {object} dto.ErrorResponse "Неверный запрос (invalid_request, invalid_merch_name, invalid_price, invalid_stock, invalid_purchase_limit, invalid_sale_window)"
+// @Failure 401 {object} dto.ErrorResponse "Неавторизован"
+// @Failure 403 {object} dto.ErrorResponse "Требуется роль admin"
+// @Failure 409 {object} dto.ErrorResponse "Товар с таким названием уже есть, в том числе в архиве"
+// @Failure 500 {object} dto.ErrorResponse "Внутренняя ошибка сервера"
+// @Router /api/admin/merch [post]
+func (h *CatalogHandler) CreateMerch(c *gin.Context) {
+	var input dto.CreateMerchRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		_ = c.Error(fmt.Errorf("%w: %s", ErrInvalidRequest, err))
+		return
+	}
+
+	adminID, err := currentUserID(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	item, err := h.catalogService.CreateItem(c.Request.Context(), adminID, input)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, item)
+}
+
+// UpdateMerch
+// @Summary Изменить товар
+// @Description Меняет цену, название, описание или доступность товара. Изменения цены сохраняются в истории цен.
+// @Tags admin
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param name path string true "Название товара"
+// @Param item body dto.UpdateMerchRequest true "Изменяемые поля"
+// @Success 200 {object} dto.MerchDTO "Измененный товар"
+// @Failure 400 {object} dto.ErrorResponse "Неверный запрос (invalid_request, invalid_merch_name, invalid_price, empty_update)"
+// @Failure 401 {object} dto.ErrorResponse "Неавторизован"
+// @Failure 403 {object} dto.ErrorResponse "Требуется роль admin"
+// @Failure 404 {object} dto.ErrorResponse "Товар не найден или в архиве"
+// @Failure 409 {object} dto.ErrorResponse "Товар с новым названием уже есть"
+// @Failure 500 {object} dto.ErrorResponse "Внутренняя ошибка сервера"
+// @Router /api/admin/merch/{name} [patch]
+func (h *CatalogHandler) UpdateMerch(c *gin.Context) {
+	var input dto.UpdateMerchRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		_ = c.Error(fmt.Errorf("%w: %s", ErrInvalidRequest, err))
+		return
+	}
+
+	adminID, err := currentUserID(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	item, err := h.catalogService.UpdateItem(c.Request.Context(), adminID, c.Param("name"), input)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, item)
+}
+
+// SetMerchLimits
+// @Summary Задать ограничения продажи товара
+// @Description Заменяет остаток, лимит на пользователя и окно продажи. Поле null или отсутствующее поле снимает ограничение.
+// @Tags admin
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param name path string true "Название товара"
+// @Param limits body dto.MerchLimits true "Ограничения продажи"
+// @Success 200 {object} dto.MerchDTO "Измененный товар"
+// @Failure 400 {object} dto.ErrorResponse "Неверный запрос (invalid_request, invalid_stock, invalid_purchase_limit, invalid_sale_window)"
+// @Failure 401 {object} dto.ErrorResponse "Неавторизован"
+// @Failure 403 {object} dto.ErrorResponse "Требуется роль admin"
+// @Failure 404 {object} dto.ErrorResponse "Товар не найден или в архиве"
+// @Failure 500 {object} dto.ErrorResponse "Внутренняя ошибка сервера"
+// @Router /api/admin/merch/{name}/limits [put]
+func (h *CatalogHandler) SetMerchLimits(c *gin.Context) {
+	var input dto.MerchLimits
+	if err := c.ShouldBindJSON(&input); err != nil {
+		_ = c.Error(fmt.Errorf("%w: %s", ErrInvalidRequest, err))
+		return
+	}
+
+	adminID, err := currentUserID(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	item, err := h.catalogService.SetLimits(c.Request.Context(), adminID, c.Param("name"), input)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, item)
+}
+
+// ArchiveMerch
+// @Summary Архивировать товар
+// @Description Скрывает товар из каталога и снимает с продажи. Покупки товара остаются в истории.
+// @Tags admin
+// @Security BearerAuth
+// @Produce json
+// @Param name path string true "Название товара"
+// @Success 200 {object} dto.MerchDTO "Архивный товар"
+// @Failure 401 {object} dto.ErrorResponse "Неавторизован"
+// @Failure 403 {object} dto.ErrorResponse "Требуется роль admin"
+// @Failure 404 {object} dto.ErrorResponse "Товар не найден"
+// @Failure 500 {object} dto.ErrorResponse "Внутренняя ошибка сервера"
+// @Router /api/admin/merch/{name}/archive [post]
+func (h *CatalogHandler) ArchiveMerch(c *gin.Context) {
+	adminID, err := currentUserID(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	item, err := h.catalogService.ArchiveItem(c.Request.Context(), adminID, c.Param("name"))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, item)
+}
+
+// RestoreMerch
+// @Summary Восстановить товар из архива
+// @Tags admin
+// @Security BearerAuth
+// @Produce json
+// @Param name path string true "Название товара"
+// @Success 200 {object} dto.MerchDTO "Восстановленный товар"
+// @Failure 401 {object} dto.ErrorResponse "Неавторизован"
+// @Failure 403 {object} dto.ErrorResponse "Требуется роль admin"
+// @Failure 404 {object} dto.ErrorResponse "Товар не найден"
+// @Failure 500 {object} dto.ErrorResponse "Внутренняя ошибка сервера"
+// @Router /api/admin/merch/{name}/restore [post]
+func (h *CatalogHandler) RestoreMerch(c *gin.Context) {
+	adminID, err := currentUserID(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	item, err := h.catalogService.RestoreItem(c.Request.Context(), adminID, c.Param("name"))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, item)
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"avito-shop/internal/middlewares"
+	"avito-shop/internal/repository"
+	"avito-shop/internal/services"
+	"errors"
+	"net/http"
+)
+
+var (
+	ErrInvalidRequest = errors.New("invalid request")
+	ErrUnauthorized   = errors.New("unauthorized")
+	ErrItemRequired   = errors.New("item is required")
+)
+
+// ErrorMappings - коды ошибок, которые видят клиенты. Коды являются частью API и не должны меняться.
+var ErrorMappings = []middlewares.ErrorMapping{
+	{Err: ErrInvalidRequest, Status: http.StatusBadRequest, Code: "invalid_request"},
+	{Err: ErrUnauthorized, Status: http.StatusUnauthorized, Code: "unauthorized"},
+	{Err: ErrItemRequired, Status: http.StatusBadRequest, Code: "item_required"},
+	{Err: services.ErrInvalidAmount, Status: http.StatusBadRequest, Code: "invalid_amount"},
+	{Err: services.ErrInvalidDirection, Status: http.StatusBadRequest, Code: "invalid_direction"},
+	{Err: services.ErrInvalidDateRange, Status: http.StatusBadRequest, Code: "invalid_date_range"},
+	{Err: services.ErrInvalidCursor, Status: http.StatusBadRequest, Code: "invalid_cursor"},
+	{Err: services.ErrInvalidLimit, Status: http.StatusBadRequest, Code: "invalid_limit"},
+	{Err: services.ErrEmptyCart, Status: http.StatusBadRequest, Code: "empty_cart"},
+	{Err: services.ErrInvalidQuantity, Status: http.StatusBadRequest, Code: "invalid_quantity"},
+	{Err: services.ErrGiftMessageTooLong, Status: http.StatusBadRequest, Code: "gift_message_too_long"},
+	{Err: services.ErrInvalidAdjustment, Status: http.StatusBadRequest, Code: "invalid_adjustment"},
+	{Err: services.ErrReasonRequired, Status: http.StatusBadRequest, Code: "reason_required"},
+	{Err: repository.ErrInsufficientFunds, Status: http.StatusBadRequest, Code: "insufficient_funds"},
+	{Err: repository.ErrNegativeBalance, Status: http.StatusBadRequest, Code: "negative_balance"},
+	// ErrMerchNotFound оборачивает repository.ErrItemNotFound, поэтому проверяется раньше
+	{Err: services.ErrMerchNotFound, Status: http.StatusNotFound, Code: "item_not_found"},
+	{Err: repository.ErrItemNotFound, Status: http.StatusBadRequest, Code: "item_not_found"},
+	{Err: repository.ErrItemUnavailable, Status: http.StatusBadRequest, Code: "item_unavailable"},
+	{Err: repository.ErrOutOfStock, Status: http.StatusBadRequest, Code: "out_of_stock"},
+	{Err: repository.ErrPurchaseLimitReached, Status: http.StatusBadRequest, Code: "purchase_limit_reached"},
+	{Err: repository.ErrSaleClosed, Status: http.StatusBadRequest, Code: "sale_closed"},
+	{Err: repository.ErrItemAlreadyExists, Status: http.StatusConflict, Code: "item_already_exists"},
+	{Err: services.ErrInvalidMerchName, Status: http.StatusBadRequest, Code: "invalid_merch_name"},
+	{Err: services.ErrInvalidPrice, Status: http.StatusBadRequest, Code: "invalid_price"},
+	{Err: services.ErrEmptyUpdate, Status: http.StatusBadRequest, Code: "empty_update"},
+	{Err: services.ErrInvalidStock, Status: http.StatusBadRequest, Code: "invalid_stock"},
+	{Err: services.ErrInvalidPurchaseLimit, Status: http.StatusBadRequest, Code: "invalid_purchase_limit"},
+	{Err: services.ErrInvalidSaleWindow, Status: http.StatusBadRequest, Code: "invalid_sale_window"},
+	{Err: repository.ErrPurchaseNotFound, Status: http.StatusNotFound, Code: "purchase_not_found"},
+	{Err: repository.ErrPurchaseAlreadyReturned, Status: http.StatusConflict, Code: "purchase_already_returned"},
+	{Err: repository.ErrReturnWindowExpired, Status: http.StatusBadRequest, Code: "return_window_expired"},
+	{Err: repository.ErrGiftNotReturnable, Status: http.StatusBadRequest, Code: "gift_not_returnable"},
+	{Err: repository.ErrSelfGift, Status: http.StatusBadRequest, Code: "self_gift"},
+	{Err: repository.ErrRecipientNotFound, Status: http.StatusBadRequest, Code: "recipient_not_found"},
+	{Err: repository.ErrSelfTransfer, Status: http.StatusBadRequest, Code: "self_transfer"},
+	{Err: repository.ErrUserNotFound, Status: http.StatusNotFound, Code: "user_not_found"},
+	{Err: middlewares.ErrIdempotencyKeyInvalid, Status: http.StatusBadRequest, Code: "invalid_idempotency_key"},
+	{Err: middlewares.ErrIdempotencyKeyInUse, Status: http.StatusConflict, Code: "idempotency_key_in_use"},
+	{Err: middlewares.ErrIdempotencyKeyReused, Status: http.StatusUnprocessableEntity, Code: "idempotency_key_reused"},
+}
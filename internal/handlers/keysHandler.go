@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"avito-shop/internal/lib/jwt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+type KeyProvider interface {
+	JWKS() jwt.JWKS
+}
+
+type KeysHandler struct {
+	keys KeyProvider
+}
+
+func NewKeysHandler(keys KeyProvider) *KeysHandler {
+	return &KeysHandler{
+		keys: keys,
+	}
+}
+
+// JWKS
+// @Summary Публичные ключи для проверки JWT
+// @Description Набор ключей в формате JWKS (RFC 7517). Содержит все ключи, которыми могут быть подписаны действующие токены.
+// @Tags auth
+// @Produce json
+// @Success 200 {object} jwt.JWKS "Набор ключей"
+// @Router /.well-known/jwks.json [get]
+func (h *KeysHandler) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age="+strconv.Itoa(int(jwt.JWKSMaxAge.Seconds())))
+	c.JSON(http.StatusOK, h.keys.JWKS())
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"avito-shop/internal/domain/dto"
+	"context"
+	"github.com/gin-gonic/gin"
+	"log/slog"
+	"net/http"
+)
+
+type MerchService interface {
+	ListItems(ctx context.Context) ([]dto.MerchDTO, error)
+	GetItem(ctx context.Context, name string) (dto.MerchDTO, error)
+}
+
+type MerchHandler struct {
+	log          *slog.Logger
+	merchService MerchService
+}
+
+func NewMerchHandler(log *slog.Logger, merchService MerchService) *MerchHandler {
+	return &MerchHandler{
+		log:          log,
+		merchService: merchService,
+	}
+}
+
+// ListMerch godoc
+// @Summary Каталог товаров
+// @Description Возвращает все товары магазина с ценой, доступностью и описанием.
+// @Tags merch
+// @Produce json
+// @Success 200 {object} dto.MerchListResponse "Каталог товаров"
+// @Failure 500 {object} dto.ErrorResponse "Внутренняя ошибка сервера"
+// @Router /api/merch [get]
+func (h *MerchHandler) ListMerch(c *gin.Context) {
+	items, err := h.merchService.ListItems(c.Request.Context())
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.MerchListResponse{Items: items})
+}
+
+// GetMerch godoc
+// @Summary Товар каталога
+// @Description Возвращает товар по названию.
+// @Tags merch
+// @Produce json
+// @Param name path string true "Название товара"
+// @Success 200 {object} dto.MerchDTO "Товар"
+// @Failure 404 {object} dto.ErrorResponse "Товар не найден (item_not_found)"
+// @Failure 500 {object} dto.ErrorResponse "Внутренняя ошибка сервера"
+// @Router /api/merch/{name} [get]
+func (h *MerchHandler) GetMerch(c *gin.Context) {
+	item, err := h.merchService.GetItem(c.Request.Context(), c.Param("name"))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, item)
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"avito-shop/internal/domain/dto"
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"log/slog"
+	"net/http"
+)
+
+type PurchaseService interface {
+	ListPurchases(ctx context.Context, userID uuid.UUID) (dto.PurchasesResponse, error)
+	ReturnPurchase(ctx context.Context, userID, purchaseID uuid.UUID) (dto.PurchaseRecordDTO, error)
+}
+
+type PurchaseHandler struct {
+	log             *slog.Logger
+	purchaseService PurchaseService
+}
+
+func NewPurchaseHandler(log *slog.Logger, purchaseService PurchaseService) *PurchaseHandler {
+	return &PurchaseHandler{
+		log:             log,
+		purchaseService: purchaseService,
+	}
+}
+
+// ListPurchases godoc
+// @Summary Получить список покупок
+// @Description Возвращает отдельные покупки пользователя от новых к старым, включая возвращенные.
+// @Tags purchases
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} dto.PurchasesResponse "Покупки пользователя"
+// @Failure 401 {object} dto.ErrorResponse "Неавторизован"
+// @Failure 500 {object} dto.ErrorResponse "Внутренняя ошибка сервера"
+// @Router /api/purchases [get]
+func (h *PurchaseHandler) ListPurchases(c *gin.Context) {
+	userID, err := currentUserID(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	purchases, err := h.purchaseService.ListPurchases(c.Request.Context(), userID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, purchases)
+}
+
+// ReturnPurchase
+// @Summary Вернуть покупку
+// @Description Возвращает покупку в пределах окна возврата: начисляет цену на момент покупки и возвращает товар на склад.
+// @Tags purchases
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "id покупки из GET /api/purchases"
+// @Param Idempotency-Key header string false "Ключ идемпотентности"
+// @Success 200 {object} dto.PurchaseRecordDTO "Возвращенная покупка"
+// @Failure 400 {object} dto.ErrorResponse "Неверный запрос (invalid_request, return_window_expired, gift_not_returnable)"
+// @Failure 401 {object} dto.ErrorResponse "Неавторизован"
+// @Failure 404 {object} dto.ErrorResponse "Покупка не найдена"
+// @Failure 409 {object} dto.ErrorResponse "Покупка уже возвращена или запрос с этим ключом идемпотентности еще выполняется"
+// @Failure 422 {object} dto.ErrorResponse "Ключ идемпотентности использован с другим запросом"
+// @Failure 500 {object} dto.ErrorResponse "Внутренняя ошибка сервера"
+// @Router /api/purchases/{id}/return [post]
+func (h *PurchaseHandler) ReturnPurchase(c *gin.Context) {
+	purchaseID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		_ = c.Error(ErrInvalidRequest)
+		return
+	}
+
+	userID, err := currentUserID(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	purchase, err := h.purchaseService.ReturnPurchase(c.Request.Context(), userID, purchaseID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, purchase)
+}
@@ -14,9 +14,12 @@ import (
 type UserService interface {
 	GetUserPurchases(ctx context.Context, userID uuid.UUID) ([]dto.PurchaseDTO, error)
 	GetCoinTransactions(ctx context.Context, userID uuid.UUID) (dto.TransactionDTO, error)
-	TransferCoins(ctx context.Context, fromUserID, toUserID uuid.UUID, amount int) error
+	TransferCoins(ctx context.Context, fromUserID uuid.UUID, toUsername string, amount int) error
 	GetUserInfo(ctx context.Context, userID uuid.UUID) (dto.InfoResponse, error)
+	GetTransactions(ctx context.Context, userID uuid.UUID, req dto.TransactionsRequest) (dto.TransactionsResponse, error)
 	BuyItem(ctx context.Context, userID uuid.UUID, item string) error
+	Checkout(ctx context.Context, userID uuid.UUID, req dto.CheckoutRequest) (dto.CheckoutResponse, error)
+	GiftItem(ctx context.Context, fromUserID uuid.UUID, req dto.GiftRequest) error
 }
 
 type UserHandler struct {
@@ -33,7 +36,7 @@ func NewUserHandler(log *slog.Logger, userService UserService) *UserHandler {
 
 // GetUserInfo godoc
 // @Summary Получить информацию о монетах, инвентаре и истории транзакций
-// @Description Возвращает баланс монет, инвентарь (купленные товары) и историю переводов монет.
+// @Description Возвращает баланс монет, инвентарь (купленные и подаренные товары), историю переводов монет и подарков.
 // @Tags user
 // @Security BearerAuth
 // @Produce json
@@ -43,56 +46,91 @@ func NewUserHandler(log *slog.Logger, userService UserService) *UserHandler {
 // @Failure 500 {object} dto.ErrorResponse "Внутренняя ошибка сервера"
 // @Router /api/info [get]
 func (h *UserHandler) GetUserInfo(c *gin.Context) {
-	userIDVal, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-		return
-	}
-	userID, err := uuid.Parse(userIDVal.(string))
+	userID, err := currentUserID(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		_ = c.Error(err)
 		return
 	}
 
 	info, err := h.userService.GetUserInfo(c.Request.Context(), userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		_ = c.Error(err)
 		return
 	}
 
 	c.JSON(http.StatusOK, info)
 }
 
+// GetTransactions godoc
+// @Summary Получить историю переводов монет
+// @Description Возвращает отдельные переводы пользователя от новых к старым с постраничной навигацией по курсору.
+// @Tags user
+// @Security BearerAuth
+// @Produce json
+// @Param direction query string false "sent или received, по умолчанию все переводы"
+// @Param from query string false "Начало периода включительно (RFC 3339 или YYYY-MM-DD)"
+// @Param to query string false "Конец периода не включительно (RFC 3339 или YYYY-MM-DD)"
+// @Param cursor query string false "nextCursor из предыдущего ответа"
+// @Param limit query int false "Размер страницы, от 1 до 100, по умолчанию 20"
+// @Success 200 {object} dto.TransactionsResponse "Страница истории переводов"
+// @Failure 400 {object} dto.ErrorResponse "Неверный запрос (invalid_request, invalid_direction, invalid_date_range, invalid_cursor, invalid_limit)"
+// @Failure 401 {object} dto.ErrorResponse "Неавторизован"
+// @Failure 500 {object} dto.ErrorResponse "Внутренняя ошибка сервера"
+// @Router /api/transactions [get]
+func (h *UserHandler) GetTransactions(c *gin.Context) {
+	var input dto.TransactionsRequest
+	if err := c.ShouldBindQuery(&input); err != nil {
+		_ = c.Error(fmt.Errorf("%w: %s", ErrInvalidRequest, err))
+		return
+	}
+
+	userID, err := currentUserID(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	transactions, err := h.userService.GetTransactions(c.Request.Context(), userID, input)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, transactions)
+}
+
 // TransferCoins
 // @Summary Отправить монеты другому пользователю
-// @Description Выполняет перевод монет от одного пользователя к другому.
+// @Description Переводит монеты от текущего пользователя пользователю с указанным именем.
 // @Tags user
 // @Security BearerAuth
 // @Accept json
 // @Produce json
 // @Param transfer body dto.SendCoinRequest true "Данные для перевода"
+// @Param Idempotency-Key header string false "Ключ идемпотентности"
 // @Success 200 {string} string "Монеты успешно переведены"
-// @Failure 400 {object} dto.ErrorResponse "Неверный запрос"
+// @Failure 400 {object} dto.ErrorResponse "Неверный запрос (invalid_request, invalid_amount, insufficient_funds, recipient_not_found, self_transfer)"
 // @Failure 401 {object} dto.ErrorResponse "Неавторизован"
+// @Failure 409 {object} dto.ErrorResponse "Запрос с этим ключом идемпотентности еще выполняется"
+// @Failure 422 {object} dto.ErrorResponse "Ключ идемпотентности использован с другим запросом"
 // @Failure 500 {object} dto.ErrorResponse "Внутренняя ошибка сервера"
-// @Router /api/sendCoins [post]
+// @Router /api/sendCoin [post]
 func (h *UserHandler) TransferCoins(c *gin.Context) {
 	var input dto.SendCoinRequest
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		_ = c.Error(fmt.Errorf("%w: %s", ErrInvalidRequest, err))
 		return
 	}
 
-	userIDVal, _ := c.Get("user_id")
-
-	if input.FromUserID.String() != userIDVal.(string) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "You can't send coins from another user"})
+	fromUserID, err := currentUserID(c)
+	if err != nil {
+		_ = c.Error(err)
 		return
 	}
 
-	err := h.userService.TransferCoins(c.Request.Context(), input.FromUserID, input.ToUserID, input.Amount)
+	err = h.userService.TransferCoins(c.Request.Context(), fromUserID, input.ToUser, input.Amount)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		_ = c.Error(err)
 		return
 	}
 
@@ -101,39 +139,36 @@ func (h *UserHandler) TransferCoins(c *gin.Context) {
 
 // BuyMerch
 // @Summary Купить предмет за монеты
-// @Description Покупает указанный предмет за монеты пользователя.
+// @Description Покупает указанный предмет за монеты пользователя. Устаревший GET /api/buy/{item} работает так же,
+// @Description пока включен API_ALLOW_GET_BUY, и отвечает с заголовком Deprecation.
 // @Tags user
 // @Security BearerAuth
 // @Produce json
 // @Param item path string true "Название предмета"
+// @Param Idempotency-Key header string false "Ключ идемпотентности"
 // @Success 200 {string} string "Предмет куплен успешно"
-// @Failure 400 {object} dto.ErrorResponse "Неверный запрос"
+// @Failure 400 {object} dto.ErrorResponse "Неверный запрос (item_required, item_not_found, item_unavailable, insufficient_funds, out_of_stock, purchase_limit_reached, sale_closed)"
 // @Failure 401 {object} dto.ErrorResponse "Неавторизован"
+// @Failure 409 {object} dto.ErrorResponse "Запрос с этим ключом идемпотентности еще выполняется"
+// @Failure 422 {object} dto.ErrorResponse "Ключ идемпотентности использован с другим запросом"
 // @Failure 500 {object} dto.ErrorResponse "Внутренняя ошибка сервера"
-// @Router /api/buy/{item} [get]
+// @Router /api/buy/{item} [post]
 func (h *UserHandler) BuyMerch(c *gin.Context) {
 	item := c.Param("item")
 	if item == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Item is required"})
-		fmt.Println("hui")
+		_ = c.Error(ErrItemRequired)
 		return
 	}
 
-	userIDVal, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-		return
-	}
-	userID, err := uuid.Parse(userIDVal.(string))
+	userID, err := currentUserID(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
-		fmt.Println("zhopa")
+		_ = c.Error(err)
 		return
 	}
 
 	err = h.userService.BuyItem(c.Request.Context(), userID, item)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		_ = c.Error(err)
 		return
 	}
 
@@ -143,3 +178,105 @@ func (h *UserHandler) BuyMerch(c *gin.Context) {
 		"time":    time.Now().Format(time.RFC3339),
 	})
 }
+
+// Checkout
+// @Summary Купить несколько товаров одной покупкой
+// @Description Покупает все позиции корзины в одной транзакции: при любой ошибке не покупается ничего.
+// @Tags user
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param checkout body dto.CheckoutRequest true "Товары и количество"
+// @Param Idempotency-Key header string false "Ключ идемпотентности"
+// @Success 200 {object} dto.CheckoutResponse "Купленные позиции и общая сумма"
+// @Failure 400 {object} dto.ErrorResponse "Неверный запрос (invalid_request, item_required, empty_cart, invalid_quantity, item_not_found, item_unavailable, insufficient_funds, out_of_stock, purchase_limit_reached, sale_closed)"
+// @Failure 401 {object} dto.ErrorResponse "Неавторизован"
+// @Failure 409 {object} dto.ErrorResponse "Запрос с этим ключом идемпотентности еще выполняется"
+// @Failure 422 {object} dto.ErrorResponse "Ключ идемпотентности использован с другим запросом"
+// @Failure 500 {object} dto.ErrorResponse "Внутренняя ошибка сервера"
+// @Router /api/checkout [post]
+func (h *UserHandler) Checkout(c *gin.Context) {
+	var input dto.CheckoutRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		_ = c.Error(fmt.Errorf("%w: %s", ErrInvalidRequest, err))
+		return
+	}
+
+	for _, item := range input.Items {
+		if item.Item == "" {
+			_ = c.Error(ErrItemRequired)
+			return
+		}
+	}
+
+	userID, err := currentUserID(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	resp, err := h.userService.Checkout(c.Request.Context(), userID, input)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// GiftMerch
+// @Summary Подарить предмет другому пользователю
+// @Description Покупает предмет за монеты текущего пользователя и кладет его в инвентарь получателя.
+// @Tags user
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param gift body dto.GiftRequest true "Получатель, предмет и необязательное сообщение"
+// @Param Idempotency-Key header string false "Ключ идемпотентности"
+// @Success 200 {string} string "Подарок отправлен"
+// @Failure 400 {object} dto.ErrorResponse "Неверный запрос (invalid_request, item_required, gift_message_too_long, recipient_not_found, self_gift, item_not_found, item_unavailable, insufficient_funds, out_of_stock, purchase_limit_reached, sale_closed)"
+// @Failure 401 {object} dto.ErrorResponse "Неавторизован"
+// @Failure 409 {object} dto.ErrorResponse "Запрос с этим ключом идемпотентности еще выполняется"
+// @Failure 422 {object} dto.ErrorResponse "Ключ идемпотентности использован с другим запросом"
+// @Failure 500 {object} dto.ErrorResponse "Внутренняя ошибка сервера"
+// @Router /api/gift [post]
+func (h *UserHandler) GiftMerch(c *gin.Context) {
+	var input dto.GiftRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		_ = c.Error(fmt.Errorf("%w: %s", ErrInvalidRequest, err))
+		return
+	}
+
+	if input.ToUser == "" {
+		_ = c.Error(fmt.Errorf("%w: toUser is required", ErrInvalidRequest))
+		return
+	}
+
+	if input.Item == "" {
+		_ = c.Error(ErrItemRequired)
+		return
+	}
+
+	fromUserID, err := currentUserID(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	if err := h.userService.GiftItem(c.Request.Context(), fromUserID, input); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// currentUserID возвращает id пользователя, положенный в контекст AuthMiddleware.
+func currentUserID(c *gin.Context) (uuid.UUID, error) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		return uuid.Nil, ErrUnauthorized
+	}
+
+	return userID, nil
+}
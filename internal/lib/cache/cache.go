@@ -0,0 +1,62 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// Value - кеш одного значения в памяти процесса, которое хранится не дольше ttl.
+// При ttl <= 0 значение не кешируется и загружается при каждом обращении.
+type Value[T any] struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	value     T
+	loaded    bool
+	expiresAt time.Time
+	version   uint64
+}
+
+func NewValue[T any](ttl time.Duration) *Value[T] {
+	return &Value[T]{ttl: ttl}
+}
+
+// Get возвращает значение из кеша, а если его нет или оно устарело - загружает через load.
+// Значение, загрузка которого началась до Invalidate, в кеш не попадает, иначе после изменения данных
+// кеш мог бы до истечения ttl отдавать их старую версию.
+func (v *Value[T]) Get(load func() (T, error)) (T, error) {
+	v.mu.Lock()
+	if v.loaded && time.Now().Before(v.expiresAt) {
+		value := v.value
+		v.mu.Unlock()
+		return value, nil
+	}
+	version := v.version
+	v.mu.Unlock()
+
+	value, err := load()
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+
+	v.mu.Lock()
+	if v.ttl > 0 && v.version == version {
+		v.value = value
+		v.loaded = true
+		v.expiresAt = time.Now().Add(v.ttl)
+	}
+	v.mu.Unlock()
+
+	return value, nil
+}
+
+// Invalidate сбрасывает значение, следующий Get загрузит его заново.
+func (v *Value[T]) Invalidate() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	var zero T
+	v.value = zero
+	v.loaded = false
+	v.version++
+}
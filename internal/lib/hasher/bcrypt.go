@@ -0,0 +1,55 @@
+package hasher
+
+import (
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+)
+
+type BcryptHasher struct {
+	cost int
+}
+
+func NewBcrypt(cost int) (*BcryptHasher, error) {
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return nil, fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	}
+
+	return &BcryptHasher{cost: cost}, nil
+}
+
+func (h *BcryptHasher) Hash(password string) ([]byte, error) {
+	return bcrypt.GenerateFromPassword([]byte(password), h.cost)
+}
+
+func (h *BcryptHasher) Compare(hash []byte, password string) error {
+	return compare(hash, password)
+}
+
+// NeedsRehash возвращает true для хешей другого алгоритма и bcrypt хешей с меньшей стоимостью.
+func (h *BcryptHasher) NeedsRehash(hash []byte) bool {
+	if !isBcrypt(hash) {
+		return true
+	}
+
+	cost, err := bcrypt.Cost(hash)
+	if err != nil {
+		return true
+	}
+
+	return cost < h.cost
+}
+
+func isBcrypt(hash []byte) bool {
+	return strings.HasPrefix(string(hash), "$2")
+}
+
+func compareBcrypt(hash []byte, password string) error {
+	err := bcrypt.CompareHashAndPassword(hash, []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return ErrMismatch
+	}
+
+	return err
+}
@@ -0,0 +1,56 @@
+package hasher
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	AlgorithmBcrypt   = "bcrypt"
+	AlgorithmArgon2id = "argon2id"
+)
+
+var (
+	ErrMismatch         = errors.New("password does not match")
+	ErrUnknownFormat    = errors.New("unknown password hash format")
+	ErrUnknownAlgorithm = errors.New("unknown password hash algorithm")
+)
+
+// Hasher хеширует пароли выбранным алгоритмом. Compare принимает хеши любого поддерживаемого
+// алгоритма, а NeedsRehash сообщает, что хеш создан другим алгоритмом или с устаревшими параметрами.
+type Hasher interface {
+	Hash(password string) ([]byte, error)
+	Compare(hash []byte, password string) error
+	NeedsRehash(hash []byte) bool
+}
+
+// Config - параметры хеширования. Используются только параметры выбранного алгоритма.
+type Config struct {
+	Algorithm  string
+	BcryptCost int
+	Argon2id   Argon2idParams
+}
+
+func New(cfg Config) (Hasher, error) {
+	switch cfg.Algorithm {
+	case AlgorithmBcrypt:
+		return NewBcrypt(cfg.BcryptCost)
+	case AlgorithmArgon2id:
+		return NewArgon2id(cfg.Argon2id)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownAlgorithm, cfg.Algorithm)
+	}
+}
+
+// compare проверяет пароль по хешу, определяя алгоритм по префиксу хеша.
+func compare(hash []byte, password string) error {
+	switch {
+	case isBcrypt(hash):
+		return compareBcrypt(hash, password)
+	case strings.HasPrefix(string(hash), argon2idPrefix):
+		return compareArgon2id(hash, password)
+	default:
+		return ErrUnknownFormat
+	}
+}
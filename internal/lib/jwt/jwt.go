@@ -7,50 +7,125 @@ import (
 	"time"
 )
 
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+
+	defaultIssuer   = "avito-shop"
+	defaultAudience = "avito-shop-api"
+)
+
+var (
+	ErrTokenMalformed = errors.New("token is malformed")
+	ErrTokenExpired   = errors.New("token is expired")
+	ErrTokenInvalid   = errors.New("token is invalid")
+	ErrWrongTokenType = errors.New("wrong token type")
+)
+
+// AccessClaims - полезная нагрузка access токена. Subject содержит id пользователя, Role - его роль
+// на момент выдачи токена, поэтому смена роли вступает в силу не позже истечения access токена.
+type AccessClaims struct {
+	Type string `json:"typ"`
+	Role string `json:"role,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// RefreshClaims - полезная нагрузка refresh токена. Family связывает все refresh токены,
+// полученные ротацией из одного логина, чтобы при повторном использовании можно было отозвать всю цепочку.
+type RefreshClaims struct {
+	Type   string `json:"typ"`
+	Family string `json:"fam"`
+	jwt.RegisteredClaims
+}
+
 type Generator struct {
-	secret     []byte
-	accessTTL  time.Duration
-	refreshTTL time.Duration
+	secret      []byte
+	keys        *KeySet
+	legacyUntil time.Time
+	accessTTL   time.Duration
+	refreshTTL  time.Duration
+	issuer      string
+	audience    string
+	leeway      time.Duration
+}
+
+type Option func(*Generator)
+
+// WithIssuer задает значение iss, которое записывается в токены и проверяется при разборе.
+func WithIssuer(issuer string) Option {
+	return func(g *Generator) {
+		g.issuer = issuer
+	}
+}
+
+// WithAudience задает значение aud, которое записывается в токены и проверяется при разборе.
+func WithAudience(audience string) Option {
+	return func(g *Generator) {
+		g.audience = audience
+	}
 }
 
-func NewGenerator(secret string, accessTTL time.Duration, refreshTTL time.Duration) *Generator {
-	return &Generator{
+// WithLeeway задает допустимое расхождение часов при проверке exp, nbf и iat.
+func WithLeeway(leeway time.Duration) Option {
+	return func(g *Generator) {
+		g.leeway = leeway
+	}
+}
+
+// WithKeySet включает подпись асимметричными ключами набора с заголовком kid.
+// Токены HS512 без kid после этого не принимаются, если переходный период не задан WithLegacySecretUntil.
+func WithKeySet(keys *KeySet) Option {
+	return func(g *Generator) {
+		g.keys = keys
+	}
+}
+
+// WithLegacySecretUntil задает конец переходного периода после включения WithKeySet: до until
+// токены HS512 без kid, подписанные secret, еще принимаются, чтобы переход на ключи не инвалидировал
+// уже выданные токены. После until secret больше не позволяет подделать токен.
+func WithLegacySecretUntil(until time.Time) Option {
+	return func(g *Generator) {
+		g.legacyUntil = until
+	}
+}
+
+func NewGenerator(secret string, accessTTL time.Duration, refreshTTL time.Duration, opts ...Option) *Generator {
+	g := &Generator{
 		secret:     []byte(secret),
 		accessTTL:  accessTTL,
 		refreshTTL: refreshTTL,
+		issuer:     defaultIssuer,
+		audience:   defaultAudience,
 	}
-}
 
-func (g *Generator) GeneratePair(id string) (accessToken string, refreshToken string, err error) {
-	now := time.Now().Unix()
+	for _, opt := range opts {
+		opt(g)
+	}
 
-	jtiAccess := uuid.NewString()
-	jtiRefresh := uuid.NewString()
+	return g
+}
 
-	accessClaims := jwt.MapClaims{
-		"sub": id,
-		"iat": now,
-		"exp": time.Now().Add(g.accessTTL).Unix(),
-		"jti": jtiAccess,
-		"typ": "access",
+func (g *Generator) GeneratePair(id, family, role string) (accessToken string, refreshToken string, err error) {
+	now := time.Now()
+
+	accessClaims := AccessClaims{
+		Type:             TokenTypeAccess,
+		Role:             role,
+		RegisteredClaims: g.registeredClaims(id, now, g.accessTTL),
 	}
 
-	refreshClaims := jwt.MapClaims{
-		"sub": id,
-		"iat": now,
-		"exp": time.Now().Add(g.refreshTTL).Unix(),
-		"jti": jtiRefresh,
-		"typ": "refresh",
+	refreshClaims := RefreshClaims{
+		Type:             TokenTypeRefresh,
+		Family:           family,
+		RegisteredClaims: g.registeredClaims(id, now, g.refreshTTL),
 	}
 
-	aToken := jwt.NewWithClaims(jwt.SigningMethodHS512, accessClaims)
-	accessToken, err = aToken.SignedString(g.secret)
+	accessToken, err = g.sign(accessClaims, now)
 	if err != nil {
 		return "", "", err
 	}
 
-	rToken := jwt.NewWithClaims(jwt.SigningMethodHS512, refreshClaims)
-	refreshToken, err = rToken.SignedString(g.secret)
+	refreshToken, err = g.sign(refreshClaims, now)
 	if err != nil {
 		return "", "", err
 	}
@@ -58,27 +133,148 @@ func (g *Generator) GeneratePair(id string) (accessToken string, refreshToken st
 	return accessToken, refreshToken, nil
 }
 
-func (g *Generator) ParseToken(tokenString string) (string, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
+// JWKS возвращает публичные ключи, которыми можно проверить выданные токены.
+func (g *Generator) JWKS() JWKS {
+	if g.keys == nil {
+		return JWKS{Keys: []JWK{}}
+	}
+
+	return g.keys.JWKS()
+}
+
+// ParseAccess проверяет access токен. Refresh токены отклоняются с ErrWrongTokenType.
+func (g *Generator) ParseAccess(tokenString string) (*AccessClaims, error) {
+	claims := &AccessClaims{}
+	if err := g.parse(tokenString, claims); err != nil {
+		return nil, err
+	}
+
+	if claims.Type != TokenTypeAccess {
+		return nil, ErrWrongTokenType
+	}
+
+	return claims, nil
+}
+
+// ParseRefresh проверяет refresh токен. Access токены отклоняются с ErrWrongTokenType.
+func (g *Generator) ParseRefresh(tokenString string) (*RefreshClaims, error) {
+	claims := &RefreshClaims{}
+	if err := g.parse(tokenString, claims); err != nil {
+		return nil, err
+	}
+
+	if claims.Type != TokenTypeRefresh {
+		return nil, ErrWrongTokenType
+	}
+
+	if claims.Family == "" {
+		return nil, ErrTokenInvalid
+	}
+
+	return claims, nil
+}
+
+func (g *Generator) registeredClaims(id string, now time.Time, ttl time.Duration) jwt.RegisteredClaims {
+	return jwt.RegisteredClaims{
+		Subject:   id,
+		Issuer:    g.issuer,
+		Audience:  jwt.ClaimStrings{g.audience},
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		ID:        uuid.NewString(),
+	}
+}
+
+func (g *Generator) sign(claims jwt.Claims, now time.Time) (string, error) {
+	if g.keys == nil {
+		return jwt.NewWithClaims(jwt.SigningMethodHS512, claims).SignedString(g.secret)
+	}
+
+	key, err := g.keys.SigningKey(now)
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+
+	return token.SignedString(key.private)
+}
+
+func (g *Generator) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		if !g.acceptsSecret(time.Now()) || token.Method.Alg() != jwt.SigningMethodHS512.Alg() {
+			return nil, errors.New("missing kid")
 		}
 		return g.secret, nil
-	})
+	}
 
-	if err != nil || !token.Valid {
-		return "", errors.New("invalid token")
+	if g.keys == nil {
+		return nil, errors.New("unknown kid")
 	}
 
-	claims, ok := token.Claims.(jwt.MapClaims)
+	key, ok := g.keys.VerificationKey(kid)
 	if !ok {
-		return "", errors.New("invalid token claims")
+		return nil, errors.New("unknown kid")
 	}
 
-	id, ok := claims["sub"]
-	if !ok {
-		return "", errors.New("invalid user_id in token")
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, errors.New("signing method does not match key")
+	}
+
+	return key.public, nil
+}
+
+func (g *Generator) validMethods() []string {
+	var methods []string
+	if g.acceptsSecret(time.Now()) {
+		methods = append(methods, jwt.SigningMethodHS512.Alg())
+	}
+	if g.keys != nil {
+		methods = append(methods, g.keys.Methods()...)
+	}
+	return methods
+}
+
+// acceptsSecret сообщает, принимаются ли токены HS512: без набора ключей - всегда, если задан secret,
+// с набором ключей - только до конца переходного периода.
+func (g *Generator) acceptsSecret(now time.Time) bool {
+	if len(g.secret) == 0 {
+		return false
+	}
+
+	return g.keys == nil || now.Before(g.legacyUntil)
+}
+
+func (g *Generator) parse(tokenString string, claims jwt.Claims) error {
+	token, err := jwt.ParseWithClaims(tokenString, claims, g.keyFunc,
+		jwt.WithValidMethods(g.validMethods()),
+		jwt.WithIssuer(g.issuer),
+		jwt.WithAudience(g.audience),
+		jwt.WithLeeway(g.leeway),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, jwt.ErrTokenExpired):
+			return ErrTokenExpired
+		case errors.Is(err, jwt.ErrTokenMalformed):
+			return ErrTokenMalformed
+		default:
+			return ErrTokenInvalid
+		}
+	}
+
+	if !token.Valid {
+		return ErrTokenInvalid
+	}
+
+	subject, err := claims.GetSubject()
+	if err != nil || subject == "" {
+		return ErrTokenInvalid
 	}
 
-	return id.(string), nil
+	return nil
 }
@@ -0,0 +1,336 @@
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"log/slog"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrNoSigningKey = errors.New("no active signing key")
+
+// JWKSMaxAge - сколько проверяющие сервисы кешируют JWKS. Ключ, появившийся в каталоге во время работы,
+// начинает подписывать не раньше, чем через JWKSMaxAge, чтобы его успели получить все проверяющие.
+const JWKSMaxAge = 5 * time.Minute
+
+// Key - ключ из каталога ключей. Ключи без приватной части используются только для проверки подписи.
+type Key struct {
+	ID       string
+	Method   jwt.SigningMethod
+	ActiveAt time.Time
+
+	private     crypto.Signer
+	public      crypto.PublicKey
+	publishedAt time.Time // когда ключ появился в JWKS этого процесса, нулевое - был при запуске
+}
+
+// KeySet хранит ключи подписи, загруженные из каталога. Каждый файл <kid>.pem содержит
+// один ключ RSA (RS256) или Ed25519 (EdDSA). Ключ становится подписывающим с момента из файла
+// <kid>.active (RFC 3339), поэтому ротацию можно запланировать заранее; ключ без такого файла активен сразу.
+// Время модификации файлов не учитывается: cp и rsync сохраняют или сбрасывают его непредсказуемо.
+// Все ключи каталога публикуются в JWKS и принимаются при проверке, а ключи, удаленные
+// с диска, остаются доступными для проверки еще retention, чтобы не инвалидировать выданные токены.
+type KeySet struct {
+	dir       string
+	retention time.Duration
+
+	mu      sync.RWMutex
+	keys    map[string]*Key
+	removed map[string]time.Time
+}
+
+func LoadKeySet(dir string, retention time.Duration) (*KeySet, error) {
+	ks := &KeySet{
+		dir:       dir,
+		retention: retention,
+		removed:   make(map[string]time.Time),
+	}
+
+	if err := ks.Reload(); err != nil {
+		return nil, err
+	}
+
+	return ks, nil
+}
+
+// Reload перечитывает каталог ключей.
+func (ks *KeySet) Reload() error {
+	const op = "jwt.KeySet.Reload"
+
+	paths, err := filepath.Glob(filepath.Join(ks.dir, "*.pem"))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	loaded := make(map[string]*Key, len(paths))
+	for _, path := range paths {
+		key, err := loadKey(path)
+		if err != nil {
+			return fmt.Errorf("%s: %s: %w", op, path, err)
+		}
+		loaded[key.ID] = key
+	}
+
+	if len(loaded) == 0 {
+		return fmt.Errorf("%s: no keys found in %s", op, ks.dir)
+	}
+
+	now := time.Now()
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	for kid, key := range loaded {
+		switch previous, ok := ks.keys[kid]; {
+		case ok:
+			key.publishedAt = previous.publishedAt
+		case ks.keys != nil:
+			// новый ключ еще не опубликован в JWKS, закешированных проверяющими
+			key.publishedAt = now
+		}
+	}
+
+	for kid, key := range ks.keys {
+		if _, ok := loaded[kid]; ok {
+			delete(ks.removed, kid)
+			continue
+		}
+
+		removedAt, ok := ks.removed[kid]
+		if !ok {
+			removedAt = now
+			ks.removed[kid] = removedAt
+		}
+
+		if now.Sub(removedAt) >= ks.retention {
+			delete(ks.removed, kid)
+			continue
+		}
+
+		// ключ удален с диска, но им еще могут быть подписаны действующие токены
+		loaded[kid] = &Key{
+			ID:          key.ID,
+			Method:      key.Method,
+			ActiveAt:    key.ActiveAt,
+			public:      key.public,
+			publishedAt: key.publishedAt,
+		}
+	}
+
+	ks.keys = loaded
+
+	return nil
+}
+
+// Watch периодически перечитывает каталог ключей, пока не будет отменен контекст.
+func (ks *KeySet) Watch(ctx context.Context, interval time.Duration, log *slog.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := ks.Reload(); err != nil {
+				log.Error("failed to reload jwt keys", slog.String("error", err.Error()))
+			}
+		}
+	}
+}
+
+// SigningKey возвращает ключ с приватной частью, активированный последним к моменту now, из тех, что
+// опубликованы в JWKS не меньше JWKSMaxAge. Неопубликованный ключ подписывает, только если других нет,
+// например когда прежний ключ удален одновременно с добавлением нового.
+func (ks *KeySet) SigningKey(now time.Time) (*Key, error) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	var active, unpublished *Key
+	for _, key := range ks.keys {
+		if key.private == nil || key.ActiveAt.After(now) {
+			continue
+		}
+		if now.Before(key.publishedAt.Add(JWKSMaxAge)) {
+			unpublished = newerKey(unpublished, key)
+			continue
+		}
+		active = newerKey(active, key)
+	}
+
+	if active == nil {
+		active = unpublished
+	}
+	if active == nil {
+		return nil, ErrNoSigningKey
+	}
+
+	return active, nil
+}
+
+func newerKey(current, candidate *Key) *Key {
+	if current == nil || candidate.ActiveAt.After(current.ActiveAt) ||
+		(candidate.ActiveAt.Equal(current.ActiveAt) && candidate.ID > current.ID) {
+		return candidate
+	}
+	return current
+}
+
+// VerificationKey возвращает ключ проверки подписи по kid.
+func (ks *KeySet) VerificationKey(kid string) (*Key, bool) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	key, ok := ks.keys[kid]
+	return key, ok
+}
+
+// Methods возвращает алгоритмы, которыми подписаны ключи набора.
+func (ks *KeySet) Methods() []string {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	var methods []string
+	for _, key := range ks.keys {
+		alg := key.Method.Alg()
+		if !seen[alg] {
+			seen[alg] = true
+			methods = append(methods, alg)
+		}
+	}
+
+	return methods
+}
+
+// JWK - публичный ключ в формате RFC 7517.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS возвращает публичные части всех ключей набора.
+func (ks *KeySet) JWKS() JWKS {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	set := JWKS{Keys: make([]JWK, 0, len(ks.keys))}
+	for _, key := range ks.keys {
+		jwk := JWK{Kid: key.ID, Use: "sig", Alg: key.Method.Alg()}
+
+		switch public := key.public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		default:
+			continue
+		}
+
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	sort.Slice(set.Keys, func(i, j int) bool {
+		return set.Keys[i].Kid < set.Keys[j].Kid
+	})
+
+	return set
+}
+
+func loadKey(path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	activeAt, err := loadActiveAt(strings.TrimSuffix(path, filepath.Ext(path)) + ".active")
+	if err != nil {
+		return nil, err
+	}
+
+	key := &Key{
+		ID:       strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)),
+		ActiveAt: activeAt,
+	}
+
+	var parsed any
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.Method, key.private, key.public = jwt.SigningMethodRS256, k, &k.PublicKey
+	case ed25519.PrivateKey:
+		key.Method, key.private, key.public = jwt.SigningMethodEdDSA, k, k.Public()
+	case *rsa.PublicKey:
+		key.Method, key.public = jwt.SigningMethodRS256, k
+	case ed25519.PublicKey:
+		key.Method, key.public = jwt.SigningMethodEdDSA, k
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+
+	return key, nil
+}
+
+// loadActiveAt читает момент активации ключа из файла <kid>.active. Без файла ключ активен сразу.
+func loadActiveAt(path string) (time.Time, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	activeAt, err := time.Parse(time.RFC3339, strings.TrimSpace(string(data)))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid activation time in %s: %w", filepath.Base(path), err)
+	}
+
+	return activeAt, nil
+}
@@ -0,0 +1,49 @@
+package retry
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+)
+
+// Policy - сколько раз и с какими паузами повторять операцию.
+type Policy struct {
+	Attempts  int           // всего попыток вместе с первой, значения меньше 1 считаются за 1
+	BaseDelay time.Duration // пауза перед второй попыткой, дальше удваивается
+	MaxDelay  time.Duration // верхняя граница паузы, 0 - без ограничения
+}
+
+// Do вызывает fn, пока она возвращает ошибку, для которой retryable возвращает true, но не больше p.Attempts раз.
+// Пауза растет экспоненциально и выбирается случайно между половиной и полной величиной, чтобы столкнувшиеся
+// операции не повторялись одновременно и не сталкивались снова. Возвращается последняя ошибка fn, а при
+// отмене ctx во время паузы - ошибка контекста.
+func Do(ctx context.Context, p Policy, retryable func(error) bool, fn func(ctx context.Context) error) error {
+	delay := p.BaseDelay
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil || attempt >= p.Attempts || !retryable(err) {
+			return err
+		}
+
+		timer := time.NewTimer(jitter(delay))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		delay *= 2
+		if p.MaxDelay > 0 && delay > p.MaxDelay {
+			delay = p.MaxDelay
+		}
+	}
+}
+
+func jitter(delay time.Duration) time.Duration {
+	half := delay / 2
+	if half <= 0 {
+		return delay
+	}
+	return half + rand.N(half)
+}
@@ -2,18 +2,26 @@ package middlewares
 
 import (
 	"avito-shop/internal/lib/jwt"
+	"errors"
 	"github.com/gin-gonic/gin"
 	"net/http"
 	"strings"
+	"time"
 )
 
+type TokenRevocationChecker interface {
+	IsAccessTokenRevoked(userID, jti string, issuedAt time.Time) (bool, error)
+}
+
 type AuthMiddleware struct {
-	jwtGen *jwt.Generator
+	jwtGen      *jwt.Generator
+	revocations TokenRevocationChecker
 }
 
-func NewAuthMiddleware(jwtGen *jwt.Generator) *AuthMiddleware {
+func NewAuthMiddleware(jwtGen *jwt.Generator, revocations TokenRevocationChecker) *AuthMiddleware {
 	return &AuthMiddleware{
-		jwtGen: jwtGen,
+		jwtGen:      jwtGen,
+		revocations: revocations,
 	}
 }
 
@@ -31,13 +39,33 @@ func (m *AuthMiddleware) Handle() gin.HandlerFunc {
 		}
 		tokenString := parts[1]
 
-		id, err := m.jwtGen.ParseToken(tokenString)
+		claims, err := m.jwtGen.ParseAccess(tokenString)
+		if err != nil {
+			switch {
+			case errors.Is(err, jwt.ErrTokenExpired):
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Token expired"})
+			case errors.Is(err, jwt.ErrWrongTokenType):
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Access token required"})
+			default:
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			}
+			return
+		}
+
+		revoked, err := m.revocations.IsAccessTokenRevoked(claims.Subject, claims.ID, claims.IssuedAt.Time)
 		if err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+			return
+		}
+		if revoked {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Token revoked"})
 			return
 		}
 
-		c.Set("user_id", id)
+		c.Set("user_id", claims.Subject)
+		c.Set("user_role", claims.Role)
+		c.Set("token_id", claims.ID)
+		c.Set("token_expires_at", claims.ExpiresAt.Time)
 		c.Next()
 	}
 }
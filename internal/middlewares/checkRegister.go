@@ -2,6 +2,7 @@ package middlewares
 
 import (
 	"fmt"
+	"strings"
 )
 
 func CheckRegister(login, email, password string) error {
@@ -13,6 +14,12 @@ func CheckRegister(login, email, password string) error {
 		return ErrInvalidEmail
 	}
 
+	// регулярное выражение email принимает только нижний регистр, поэтому Foo@Bar.com без приведения
+	// сохранился бы как имя пользователя, а при входе определялся бы как email
+	if IdentifyLoginInputType(strings.ToLower(login)) == "email" {
+		return ErrLoginIsEmail
+	}
+
 	if len(login) < 3 {
 		return fmt.Errorf("%w: minimum 3 characters required", ErrLoginTooShort)
 	}
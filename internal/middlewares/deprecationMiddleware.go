@@ -0,0 +1,19 @@
+package middlewares
+
+import (
+	"expvar"
+	"github.com/gin-gonic/gin"
+)
+
+// DeprecatedRequests - число запросов к устаревшим роутам по методу и пути, публикуется в /api/admin/debug/vars.
+// Когда счетчик роута перестает расти, клиенты перешли на замену и роут можно удалять.
+var DeprecatedRequests = expvar.NewMap("deprecated_requests")
+
+// Deprecated помечает ответы роута заголовком Deprecation и считает обращения к нему.
+func Deprecated() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Deprecation", "true")
+		DeprecatedRequests.Add(c.Request.Method+" "+c.FullPath(), 1)
+		c.Next()
+	}
+}
@@ -0,0 +1,40 @@
+package middlewares
+
+import (
+	"avito-shop/internal/domain/dto"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+const CodeInternalError = "internal_error"
+
+// ErrorMapping связывает ошибку с HTTP статусом и стабильным кодом для клиентов.
+type ErrorMapping struct {
+	Err    error
+	Status int
+	Code   string
+}
+
+// ErrorHandler превращает ошибку, добавленную обработчиком через c.Error, в dto.ErrorResponse.
+// Ошибка сопоставляется с mappings по errors.Is в порядке перечисления, а в ответ попадает только
+// текст сопоставленной ошибки, без внутренних подробностей. Несопоставленные ошибки отдаются как 500.
+func ErrorHandler(mappings ...ErrorMapping) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+
+		err := c.Errors.Last().Err
+		for _, m := range mappings {
+			if errors.Is(err, m.Err) {
+				c.JSON(m.Status, dto.ErrorResponse{Errors: m.Err.Error(), Code: m.Code})
+				return
+			}
+		}
+
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Errors: "internal server error", Code: CodeInternalError})
+	}
+}
@@ -7,4 +7,11 @@ var (
 	ErrInvalidEmail     = errors.New("email is invalid")
 	ErrLoginTooShort    = errors.New("login must be at least 3 characters")
 	ErrPasswordTooShort = errors.New("password must be at least 8 characters")
+	ErrLoginIsEmail     = errors.New("login must not be an email address")
+)
+
+var (
+	ErrIdempotencyKeyInvalid = errors.New("idempotency key must be 1-255 printable ASCII characters")
+	ErrIdempotencyKeyReused  = errors.New("idempotency key was already used with a different request")
+	ErrIdempotencyKeyInUse   = errors.New("request with this idempotency key is still in progress")
 )
@@ -0,0 +1,170 @@
+package middlewares
+
+import (
+	"avito-shop/internal/domain/models"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const (
+	IdempotencyKeyHeader      = "Idempotency-Key"
+	IdempotentReplayedHeader  = "Idempotent-Replayed"
+	maxIdempotencyKeyLength   = 255
+	idempotencyReplayMimeType = "application/json; charset=utf-8"
+)
+
+type IdempotencyStore interface {
+	ReserveIdempotencyKey(ctx context.Context, userID, key, requestHash string,
+		ttl time.Duration) (models.IdempotencyKey, bool, error)
+	CompleteIdempotencyKey(ctx context.Context, userID, key string, statusCode int, body []byte) error
+	ReleaseIdempotencyKey(ctx context.Context, userID, key string) error
+}
+
+type IdempotencyMiddleware struct {
+	log   *slog.Logger
+	store IdempotencyStore
+	ttl   time.Duration
+}
+
+func NewIdempotencyMiddleware(log *slog.Logger, store IdempotencyStore, ttl time.Duration) *IdempotencyMiddleware {
+	return &IdempotencyMiddleware{
+		log:   log,
+		store: store,
+		ttl:   ttl,
+	}
+}
+
+// Handle выполняет запрос с заголовком Idempotency-Key не больше одного раза для пользователя. Повтор с тем же
+// ключом и тем же запросом (метод, путь, тело) получает сохраненный ответ, с другим запросом - 422.
+// Запросы, завершившиеся ошибкой, не сохраняются: они ничего не изменили, и клиент может повторить их с тем же ключом.
+// Должен стоять после AuthMiddleware, ключи разных пользователей не пересекаются.
+func (m *IdempotencyMiddleware) Handle() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		const op = "middlewares.IdempotencyMiddleware.Handle"
+
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+
+		if !validIdempotencyKey(key) {
+			c.Abort()
+			_ = c.Error(ErrIdempotencyKeyInvalid)
+			return
+		}
+
+		userID := c.GetString("user_id")
+
+		log := m.log.With(
+			slog.String("op", op),
+			slog.String("user_id", userID),
+			slog.String("idempotency_key", key),
+		)
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.Abort()
+			_ = c.Error(fmt.Errorf("%s: %w", op, err))
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		ctx := c.Request.Context()
+		hash := idempotencyRequestHash(c.Request.Method, c.Request.URL.Path, body)
+
+		record, created, err := m.store.ReserveIdempotencyKey(ctx, userID, key, hash, m.ttl)
+		if err != nil {
+			log.Error("failed to reserve idempotency key", slog.String("error", err.Error()))
+			c.Abort()
+			_ = c.Error(fmt.Errorf("%s: %w", op, err))
+			return
+		}
+
+		if !created {
+			switch {
+			case record.RequestHash != hash:
+				log.Info("idempotency key reused with a different request")
+				c.Abort()
+				_ = c.Error(ErrIdempotencyKeyReused)
+			case record.StatusCode == 0:
+				c.Abort()
+				_ = c.Error(ErrIdempotencyKeyInUse)
+			default:
+				log.Info("replaying stored response")
+				c.Header(IdempotentReplayedHeader, "true")
+				if len(record.ResponseBody) == 0 {
+					c.AbortWithStatus(record.StatusCode)
+				} else {
+					c.Data(record.StatusCode, idempotencyReplayMimeType, record.ResponseBody)
+					c.Abort()
+				}
+			}
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+		c.Writer = recorder.ResponseWriter
+
+		// ответ уже отдан или отдается, отмена запроса клиентом не должна оставить ключ занятым
+		ctx = context.WithoutCancel(ctx)
+
+		if len(c.Errors) > 0 || recorder.Status() >= http.StatusInternalServerError {
+			if err := m.store.ReleaseIdempotencyKey(ctx, userID, key); err != nil {
+				log.Error("failed to release idempotency key", slog.String("error", err.Error()))
+			}
+			return
+		}
+
+		if err := m.store.CompleteIdempotencyKey(ctx, userID, key, recorder.Status(), recorder.body.Bytes()); err != nil {
+			log.Error("failed to store idempotent response", slog.String("error", err.Error()))
+		}
+	}
+}
+
+// responseRecorder пишет ответ клиенту и одновременно запоминает его тело.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+func validIdempotencyKey(key string) bool {
+	if len(key) > maxIdempotencyKeyLength {
+		return false
+	}
+
+	for i := 0; i < len(key); i++ {
+		if key[i] < 0x21 || key[i] > 0x7e {
+			return false
+		}
+	}
+
+	return true
+}
+
+func idempotencyRequestHash(method, path string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method + "\n" + path + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,208 @@
+package postgres
+
+import (
+	"avito-shop/internal/domain/dto"
+	"avito-shop/internal/repository"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Виды проводок журнала.
+const (
+	ledgerEntryGrant      = "grant"
+	ledgerEntryTransfer   = "transfer"
+	ledgerEntryPurchase   = "purchase"
+	ledgerEntryRefund     = "refund"
+	ledgerEntryAdjustment = "adjustment"
+)
+
+// Системные счета. issuance - источник всех монет в системе, его баланс равен минус сумме выпущенных монет.
+var (
+	issuanceAccount = ledgerAccount{code: "issuance"}
+	shopAccount     = ledgerAccount{code: "shop"}
+)
+
+var errUnbalancedEntry = errors.New("ledger entry is not balanced")
+
+// ledgerAccount - счет журнала: счет пользователя или системный счет по коду.
+type ledgerAccount struct {
+	userID uuid.UUID
+	code   string
+}
+
+func userAccount(userID uuid.UUID) ledgerAccount {
+	return ledgerAccount{userID: userID}
+}
+
+func (a ledgerAccount) idExpr() squirrel.Sqlizer {
+	if a.code != "" {
+		return squirrel.Expr("(SELECT id FROM ledger_accounts WHERE code = ?)", a.code)
+	}
+	return squirrel.Expr("(SELECT id FROM ledger_accounts WHERE user_id = ?)", a.userID)
+}
+
+// posting - строка проводки: amount > 0 увеличивает баланс счета, amount < 0 уменьшает.
+type posting struct {
+	account ledgerAccount
+	amount  int
+}
+
+// ledgerEntry - проводка, сумма строк которой равна нулю.
+type ledgerEntry struct {
+	kind        string
+	referenceID *uuid.UUID
+	description string
+	postings    []posting
+}
+
+// postEntry записывает проводку в транзакции tx. Несбалансированная проводка отклоняется здесь и, на случай
+// записи в обход этой функции, триггером ledger_postings_balanced при коммите.
+func postEntry(ctx context.Context, tx pgx.Tx, entry ledgerEntry) error {
+	sum := 0
+	for _, p := range entry.postings {
+		sum += p.amount
+	}
+	if len(entry.postings) < 2 || sum != 0 {
+		return errUnbalancedEntry
+	}
+
+	entryQuery, entryArgs, err := squirrel.Insert("ledger_entries").
+		Columns("kind", "reference_id", "description").
+		Values(entry.kind, entry.referenceID, entry.description).
+		Suffix("RETURNING id").
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	var entryID uuid.UUID
+	if err := tx.QueryRow(ctx, entryQuery, entryArgs...).Scan(&entryID); err != nil {
+		return err
+	}
+
+	postingsQuery := squirrel.Insert("ledger_postings").
+		Columns("entry_id", "account_id", "amount").
+		PlaceholderFormat(squirrel.Dollar)
+	for _, p := range entry.postings {
+		postingsQuery = postingsQuery.Values(entryID, p.account.idExpr(), p.amount)
+	}
+
+	sql, args, err := postingsQuery.ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, sql, args...)
+	return err
+}
+
+// openUserAccount заводит счет пользователя и начисляет ему стартовые монеты с счета выпуска.
+func openUserAccount(ctx context.Context, tx pgx.Tx, userID uuid.UUID, coins int) error {
+	sql, args, err := squirrel.Insert("ledger_accounts").
+		Columns("user_id").
+		Values(userID).
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, sql, args...); err != nil {
+		return err
+	}
+
+	if coins == 0 {
+		return nil
+	}
+
+	return postEntry(ctx, tx, ledgerEntry{
+		kind:        ledgerEntryGrant,
+		description: "стартовые монеты",
+		postings:    []posting{{issuanceAccount, -coins}, {userAccount(userID), coins}},
+	})
+}
+
+// RecomputeBalance пересчитывает баланс пользователя по журналу и записывает его в кеш users.coins.
+func (s *Storage) RecomputeBalance(ctx context.Context, username string) (dto.BalanceRecomputeDTO, error) {
+	const op = "storage.Postgres.RecomputeBalance"
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return dto.BalanceRecomputeDTO{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	selectQuery, selectArgs, err := squirrel.Select("id", "coins").
+		From("users").
+		Where(squirrel.Eq{"username": username}).
+		Suffix("FOR NO KEY UPDATE").
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+	if err != nil {
+		return dto.BalanceRecomputeDTO{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var userID uuid.UUID
+	result := dto.BalanceRecomputeDTO{Username: username}
+	if err = tx.QueryRow(ctx, selectQuery, selectArgs...).Scan(&userID, &result.Cached); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = repository.ErrUserNotFound
+		}
+		return dto.BalanceRecomputeDTO{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if result.Ledger, err = ledgerBalance(ctx, tx, userID); err != nil {
+		return dto.BalanceRecomputeDTO{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = setCachedBalance(ctx, tx, userID, result.Ledger); err != nil {
+		return dto.BalanceRecomputeDTO{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return dto.BalanceRecomputeDTO{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return result, nil
+}
+
+// setCachedBalance записывает баланс по журналу в кеш users.coins.
+func setCachedBalance(ctx context.Context, tx pgx.Tx, userID uuid.UUID, coins int) error {
+	sql, args, err := squirrel.Update("users").
+		Set("coins", coins).
+		Where(squirrel.Eq{"id": userID}).
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, sql, args...)
+	return err
+}
+
+// ledgerBalance - баланс счета пользователя как сумма строк проводок.
+func ledgerBalance(ctx context.Context, tx pgx.Tx, userID uuid.UUID) (int, error) {
+	sql, args, err := squirrel.Select("COALESCE(SUM(lp.amount), 0)").
+		From("ledger_postings lp").
+		Join("ledger_accounts la ON la.id = lp.account_id").
+		Where(squirrel.Eq{"la.user_id": userID}).
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	var balance int
+	err = tx.QueryRow(ctx, sql, args...).Scan(&balance)
+	return balance, err
+}
@@ -2,6 +2,8 @@ package postgres
 
 import (
 	"avito-shop/internal/domain/dto"
+	"avito-shop/internal/domain/models"
+	"avito-shop/internal/lib/retry"
 	"avito-shop/internal/repository"
 	"context"
 	"errors"
@@ -11,9 +13,17 @@ import (
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"strings"
 	"time"
 )
 
+// loginColumns - колонки, по которым разрешен вход. inputType приходит из сервиса и
+// никогда не подставляется в запрос напрямую, чтобы squirrel.Eq нельзя было направить на другую колонку.
+var loginColumns = map[string]string{
+	"username": "username",
+	"email":    "LOWER(email)",
+}
+
 type Storage struct {
 	db *pgxpool.Pool
 }
@@ -29,28 +39,59 @@ func NewPostgres(ctx context.Context, conn string) (*Storage, error) {
 	return &Storage{db: db}, nil
 }
 
-func (s *Storage) SaveUser(ctx context.Context, username string, passHash []byte) error {
+// SaveUser создает пользователя. Пустой email сохраняется как NULL.
+func (s *Storage) SaveUser(ctx context.Context, username, email string, passHash []byte) error {
 	const op = "storage.Postgres.SaveUser"
 
+	var emailValue *string
+	if email != "" {
+		emailValue = &email
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
 	sql, args, err := squirrel.Insert("users").
-		Columns("username", "password").
-		Values(username, passHash).
+		Columns("username", "email", "password").
+		Values(username, emailValue, passHash).
+		Suffix("RETURNING id, coins").
 		PlaceholderFormat(squirrel.Dollar).
 		ToSql()
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	_, err = s.db.Exec(ctx, sql, args...)
+	var userID uuid.UUID
+	var coins int
+	err = tx.QueryRow(ctx, sql, args...).Scan(&userID, &coins)
 	if err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			if pgErr.ConstraintName == "users_email_lower_key" {
+				return fmt.Errorf("%s: %w", op, repository.ErrEmailAlreadyExists)
+			}
 			return fmt.Errorf("%s: %w", op, repository.ErrUserAlreadyExists)
 		}
 
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
+	// стартовые монеты из DEFAULT users.coins проводятся через журнал, чтобы кеш совпадал с балансом счета
+	if err = openUserAccount(ctx, tx, userID, coins); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
 	return nil
 }
 
@@ -60,9 +101,18 @@ func (s *Storage) LoginUser(ctx context.Context, inputType, input string) (strin
 	var id string
 	var password []byte
 
+	column, ok := loginColumns[inputType]
+	if !ok {
+		return "", nil, fmt.Errorf("%s: %w", op, repository.ErrUnsupportedLogin)
+	}
+
+	if inputType == "email" {
+		input = strings.ToLower(input)
+	}
+
 	sql, args, err := squirrel.Select("id", "password").
 		From("users").
-		Where(squirrel.Eq{inputType: input}).
+		Where(squirrel.Eq{column: input}).
 		PlaceholderFormat(squirrel.Dollar).
 		ToSql()
 	if err != nil {
@@ -130,10 +180,170 @@ func (s *Storage) GetUserById(ctx context.Context, userID uuid.UUID) (dto.UserDT
 	return user, nil
 }
 
+func (s *Storage) GetPasswordHash(ctx context.Context, userID uuid.UUID) ([]byte, error) {
+	const op = "storage.Postgres.GetPasswordHash"
+
+	sql, args, err := squirrel.Select("password").
+		From("users").
+		Where(squirrel.Eq{"id": userID}).
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var password []byte
+	err = s.db.QueryRow(ctx, sql, args...).Scan(&password)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("%s: %w", op, repository.ErrUserNotFound)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return password, nil
+}
+
+func (s *Storage) UpdatePassword(ctx context.Context, userID uuid.UUID, passHash []byte) error {
+	const op = "storage.Postgres.UpdatePassword"
+
+	sql, args, err := squirrel.Update("users").
+		Set("password", passHash).
+		Where(squirrel.Eq{"id": userID}).
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	cmdTag, err := s.db.Exec(ctx, sql, args...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if cmdTag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, repository.ErrUserNotFound)
+	}
+
+	return nil
+}
+
+func (s *Storage) GetUserRole(ctx context.Context, userID string) (string, error) {
+	const op = "storage.Postgres.GetUserRole"
+
+	sql, args, err := squirrel.Select("role").
+		From("users").
+		Where(squirrel.Eq{"id": userID}).
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	var role string
+	err = s.db.QueryRow(ctx, sql, args...).Scan(&role)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", fmt.Errorf("%s: %w", op, repository.ErrUserNotFound)
+		}
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return role, nil
+}
+
+// AdjustBalance изменяет баланс пользователя на amount и записывает корректировку с причиной и автором.
+// Возвращает новый баланс.
+func (s *Storage) AdjustBalance(ctx context.Context, adminID uuid.UUID, username string, amount int,
+	reason string) (int, error) {
+	const op = "storage.Postgres.AdjustBalance"
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	var userID uuid.UUID
+	var coins int
+
+	selectQuery, selectArgs, err := squirrel.Select("id", "coins").
+		From("users").
+		Where(squirrel.Eq{"username": username}).
+		Suffix("FOR NO KEY UPDATE").
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = tx.QueryRow(ctx, selectQuery, selectArgs...).Scan(&userID, &coins)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = repository.ErrUserNotFound
+		}
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if coins+amount < 0 {
+		err = repository.ErrNegativeBalance
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	updateQuery, updateArgs, err := squirrel.Update("users").
+		Set("coins", squirrel.Expr("coins + ?", amount)).
+		Where(squirrel.Eq{"id": userID}).
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err = tx.Exec(ctx, updateQuery, updateArgs...); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	insertQuery, insertArgs, err := squirrel.Insert("balance_adjustments").
+		Columns("user_id", "admin_id", "amount", "reason", "created_at").
+		Values(userID, adminID, amount, reason, time.Now()).
+		Suffix("RETURNING id").
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var adjustmentID uuid.UUID
+	if err = tx.QueryRow(ctx, insertQuery, insertArgs...).Scan(&adjustmentID); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = postEntry(ctx, tx, ledgerEntry{
+		kind:        ledgerEntryAdjustment,
+		referenceID: &adjustmentID,
+		description: reason,
+		postings:    []posting{{issuanceAccount, -amount}, {userAccount(userID), amount}},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return coins + amount, nil
+}
+
 func (s *Storage) GetUserPurchases(ctx context.Context, userID uuid.UUID) ([]dto.PurchaseDTO, error) {
 	const op = "storage.Postgres.GetUserPurchases"
 
-	sql, args, err := squirrel.Select("m.name AS type", "COUNT(*) AS quantity").
+	sql, args, err := squirrel.Select("m.name AS type",
+		"COUNT(*) FILTER (WHERE p.returned_at IS NULL) AS quantity",
+		"COUNT(*) FILTER (WHERE p.returned_at IS NOT NULL) AS returned").
 		From("purchases p").
 		Join("merch_items m ON p.merch_id = m.id").
 		Where(squirrel.Eq{"p.user_id": userID}).
@@ -153,7 +363,7 @@ func (s *Storage) GetUserPurchases(ctx context.Context, userID uuid.UUID) ([]dto
 	var items []dto.PurchaseDTO
 	for rows.Next() {
 		var item dto.PurchaseDTO
-		if err := rows.Scan(&item.Merch, &item.Amount); err != nil {
+		if err := rows.Scan(&item.Merch, &item.Amount, &item.Returned); err != nil {
 			return nil, fmt.Errorf("%s: %w", op, err)
 		}
 		items = append(items, item)
@@ -162,16 +372,22 @@ func (s *Storage) GetUserPurchases(ctx context.Context, userID uuid.UUID) ([]dto
 	return items, nil
 }
 
+// GetCoinTransactions возвращает суммы переводов по каждой второй стороне. Переводы удаленных пользователей
+// объединяются под именем dto.DeletedUsername.
 func (s *Storage) GetCoinTransactions(ctx context.Context, userID uuid.UUID) (dto.TransactionDTO, error) {
 	const op = "storage.Postgres.GetCoinTransactions"
 
-	var received []dto.CoinTransactionDTO
-	var sent []dto.CoinTransactionDTO
+	received := []dto.ReceivedCoinsDTO{}
+	sent := []dto.SentCoinsDTO{}
 
-	inQuery, inArgs, err := squirrel.Select("from_user_id AS from_user", "SUM(amount) AS amount").
-		From("coin_transactions").
-		Where(squirrel.Eq{"to_user_id": userID}).
-		GroupBy("from_user_id").
+	inQuery, inArgs, err := squirrel.Select().
+		Column(squirrel.Expr("COALESCE(u.username, ?) AS from_user", dto.DeletedUsername)).
+		Column("SUM(ct.amount) AS amount").
+		From("coin_transactions ct").
+		LeftJoin("users u ON u.id = ct.from_user_id").
+		Where(squirrel.Eq{"ct.to_user_id": userID}).
+		GroupBy("ct.from_user_id", "u.username").
+		OrderBy("from_user").
 		PlaceholderFormat(squirrel.Dollar).
 		ToSql()
 	if err != nil {
@@ -185,17 +401,21 @@ func (s *Storage) GetCoinTransactions(ctx context.Context, userID uuid.UUID) (dt
 	defer rowsIn.Close()
 
 	for rowsIn.Next() {
-		var ct dto.CoinTransactionDTO
-		if err := rowsIn.Scan(&ct.Username, &ct.TotalAmount); err != nil {
+		var ct dto.ReceivedCoinsDTO
+		if err := rowsIn.Scan(&ct.FromUser, &ct.Amount); err != nil {
 			return dto.TransactionDTO{}, fmt.Errorf("%s: %w", op, err)
 		}
 		received = append(received, ct)
 	}
 
-	outQuery, outArgs, err := squirrel.Select("to_user_id AS to_user", "SUM(amount) AS amount").
-		From("coin_transactions").
-		Where(squirrel.Eq{"from_user_id": userID}).
-		GroupBy("to_user_id").
+	outQuery, outArgs, err := squirrel.Select().
+		Column(squirrel.Expr("COALESCE(u.username, ?) AS to_user", dto.DeletedUsername)).
+		Column("SUM(ct.amount) AS amount").
+		From("coin_transactions ct").
+		LeftJoin("users u ON u.id = ct.to_user_id").
+		Where(squirrel.Eq{"ct.from_user_id": userID}).
+		GroupBy("ct.to_user_id", "u.username").
+		OrderBy("to_user").
 		PlaceholderFormat(squirrel.Dollar).
 		ToSql()
 	if err != nil {
@@ -209,8 +429,8 @@ func (s *Storage) GetCoinTransactions(ctx context.Context, userID uuid.UUID) (dt
 	defer rowsOut.Close()
 
 	for rowsOut.Next() {
-		var ct dto.CoinTransactionDTO
-		if err := rowsOut.Scan(&ct.Username, &ct.TotalAmount); err != nil {
+		var ct dto.SentCoinsDTO
+		if err := rowsOut.Scan(&ct.ToUser, &ct.Amount); err != nil {
 			return dto.TransactionDTO{}, fmt.Errorf("%s: %w", op, err)
 		}
 		sent = append(sent, ct)
@@ -222,35 +442,156 @@ func (s *Storage) GetCoinTransactions(ctx context.Context, userID uuid.UUID) (dt
 	}, nil
 }
 
-func (s *Storage) TransferCoins(ctx context.Context, fromUserID, toUserID uuid.UUID, amount int) error {
+// GetTransfers возвращает отдельные переводы пользователя с именем второй стороны, от новых к старым.
+func (s *Storage) GetTransfers(ctx context.Context, userID uuid.UUID, filter dto.TransferFilter) ([]dto.TransferDTO, error) {
+	const op = "storage.Postgres.GetTransfers"
+
+	query := squirrel.Select("ct.id").
+		Column(squirrel.Expr("CASE WHEN ct.from_user_id = ? THEN 'sent' ELSE 'received' END", userID)).
+		Column(squirrel.Expr("COALESCE(u.username, ?)", dto.DeletedUsername)).
+		Columns("ct.amount", "ct.created_at").
+		From("coin_transactions ct").
+		LeftJoin("users u ON u.id = CASE WHEN ct.from_user_id = ? THEN ct.to_user_id ELSE ct.from_user_id END", userID).
+		OrderBy("ct.created_at DESC", "ct.id DESC").
+		Limit(uint64(filter.Limit)).
+		PlaceholderFormat(squirrel.Dollar)
+
+	switch filter.Direction {
+	case dto.DirectionSent:
+		query = query.Where(squirrel.Eq{"ct.from_user_id": userID})
+	case dto.DirectionReceived:
+		query = query.Where(squirrel.Eq{"ct.to_user_id": userID})
+	default:
+		query = query.Where(squirrel.Or{
+			squirrel.Eq{"ct.from_user_id": userID},
+			squirrel.Eq{"ct.to_user_id": userID},
+		})
+	}
+
+	if !filter.From.IsZero() {
+		query = query.Where(squirrel.GtOrEq{"ct.created_at": filter.From})
+	}
+	if !filter.To.IsZero() {
+		query = query.Where(squirrel.Lt{"ct.created_at": filter.To})
+	}
+	if !filter.BeforeCreatedAt.IsZero() {
+		query = query.Where("(ct.created_at, ct.id) < (?, ?)", filter.BeforeCreatedAt, filter.BeforeID)
+	}
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := s.db.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	transfers := make([]dto.TransferDTO, 0, filter.Limit)
+	for rows.Next() {
+		var t dto.TransferDTO
+		if err := rows.Scan(&t.ID, &t.Direction, &t.Counterparty, &t.Amount, &t.CreatedAt); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		transfers = append(transfers, t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return transfers, nil
+}
+
+// conflictRetry - повторы транзакции, откаченной Postgres из-за взаимной блокировки или конфликта сериализации.
+var conflictRetry = retry.Policy{Attempts: 5, BaseDelay: 10 * time.Millisecond, MaxDelay: 200 * time.Millisecond}
+
+// TransferCoins переводит монеты пользователю с именем toUsername. Получатель определяется
+// в той же транзакции, что и списание, поэтому перевод не может уйти пользователю, удаленному между запросами.
+// Транзакция, откаченная из-за взаимной блокировки (40P01) или конфликта сериализации (40001), повторяется
+// целиком по conflictRetry.
+func (s *Storage) TransferCoins(ctx context.Context, fromUserID uuid.UUID, toUsername string, amount int) error {
 	const op = "storage.Postgres.TransferCoins"
 
-	tx, err := s.db.Begin(ctx)
+	err := retry.Do(ctx, conflictRetry, IsTransactionConflict, func(ctx context.Context) error {
+		return s.transferCoins(ctx, fromUserID, toUsername, amount)
+	})
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
+
+	return nil
+}
+
+// transferCoins - одна попытка перевода. Строки отправителя и получателя блокируются одним запросом в порядке id,
+// поэтому встречные переводы A→B и B→A ждут друг друга, а не блокируют друг друга навсегда.
+func (s *Storage) transferCoins(ctx context.Context, fromUserID uuid.UUID, toUsername string, amount int) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
 	defer func() {
 		if err != nil {
 			_ = tx.Rollback(ctx)
 		}
 	}()
 
+	recipientQuery, recipientArgs, err := squirrel.Select("id").
+		From("users").
+		Where(squirrel.Eq{"username": toUsername}).
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	var toUserID uuid.UUID
+	err = tx.QueryRow(ctx, recipientQuery, recipientArgs...).Scan(&toUserID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = repository.ErrRecipientNotFound
+		}
+		return err
+	}
+
+	if toUserID == fromUserID {
+		err = repository.ErrSelfTransfer
+		return err
+	}
+
+	coins, err := lockUsers(ctx, tx, fromUserID, toUserID)
+	if err != nil {
+		return err
+	}
+
+	senderCoins, senderFound := coins[fromUserID]
+	_, recipientFound := coins[toUserID]
+	switch {
+	case !senderFound:
+		err = repository.ErrUserNotFound
+	case !recipientFound:
+		// получателя удалили между поиском по имени и блокировкой
+		err = repository.ErrRecipientNotFound
+	case senderCoins < amount:
+		err = repository.ErrInsufficientFunds
+	}
+	if err != nil {
+		return err
+	}
+
 	deductQuery, deductArgs, err := squirrel.Update("users").
 		Set("coins", squirrel.Expr("coins - ?", amount)).
 		Where(squirrel.Eq{"id": fromUserID}).
-		Where("coins >= ?", amount).
 		PlaceholderFormat(squirrel.Dollar).
 		ToSql()
 	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+		return err
 	}
 
-	cmdTag, err := tx.Exec(ctx, deductQuery, deductArgs...)
-	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
-	}
-	if cmdTag.RowsAffected() == 0 {
-		return fmt.Errorf("%s: insufficient funds", op)
+	if _, err = tx.Exec(ctx, deductQuery, deductArgs...); err != nil {
+		return err
 	}
 
 	addQuery, addArgs, err := squirrel.Update("users").
@@ -259,59 +600,132 @@ func (s *Storage) TransferCoins(ctx context.Context, fromUserID, toUserID uuid.U
 		PlaceholderFormat(squirrel.Dollar).
 		ToSql()
 	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+		return err
 	}
-	_, err = tx.Exec(ctx, addQuery, addArgs...)
-	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+
+	if _, err = tx.Exec(ctx, addQuery, addArgs...); err != nil {
+		return err
 	}
 
 	insertQuery, insertArgs, err := squirrel.Insert("coin_transactions").
 		Columns("from_user_id", "to_user_id", "amount", "created_at").
 		Values(fromUserID, toUserID, amount, time.Now()).
+		Suffix("RETURNING id").
 		PlaceholderFormat(squirrel.Dollar).
 		ToSql()
 	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+		return err
 	}
-	_, err = tx.Exec(ctx, insertQuery, insertArgs...)
+
+	var transactionID uuid.UUID
+	if err = tx.QueryRow(ctx, insertQuery, insertArgs...).Scan(&transactionID); err != nil {
+		return err
+	}
+
+	err = postEntry(ctx, tx, ledgerEntry{
+		kind:        ledgerEntryTransfer,
+		referenceID: &transactionID,
+		postings:    []posting{{userAccount(fromUserID), -amount}, {userAccount(toUserID), amount}},
+	})
 	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+		return err
 	}
 
 	if err = tx.Commit(ctx); err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+		return err
 	}
 
 	return nil
 }
 
-func (s *Storage) BuyItem(ctx context.Context, userID uuid.UUID, item string) error {
-	const op = "storage.Postgres.BuyItem"
+// lockUsers блокирует строки пользователей в порядке id и возвращает их балансы; ненайденных пользователей
+// в результате нет. Все транзакции, блокирующие несколько строк users, должны делать это через lockUsers,
+// иначе встречные транзакции могут заблокировать друг друга. Строки merch_items блокируются раньше строк users. FOR NO KEY UPDATE, а не FOR UPDATE: id не меняется,
+// и блокировка не должна конфликтовать с KEY SHARE, которую берут вставки строк со ссылкой на users.
+func lockUsers(ctx context.Context, tx pgx.Tx, ids ...uuid.UUID) (map[uuid.UUID]int, error) {
+	sql, args, err := squirrel.Select("id", "coins").
+		From("users").
+		Where(squirrel.Eq{"id": ids}).
+		OrderBy("id").
+		Suffix("FOR NO KEY UPDATE").
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
 
-	var merchID uuid.UUID
-	var price int
+	rows, err := tx.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	coins := make(map[uuid.UUID]int, len(ids))
+	for rows.Next() {
+		var id uuid.UUID
+		var balance int
+		if err := rows.Scan(&id, &balance); err != nil {
+			return nil, err
+		}
+		coins[id] = balance
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return coins, nil
+}
 
-	sqlSelect, argsSelect, err := squirrel.Select("id", "price").
+// IsTransactionConflict сообщает, что Postgres откатил транзакцию из-за взаимной блокировки или конфликта
+// сериализации и ее можно безопасно повторить.
+func IsTransactionConflict(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && (pgErr.Code == "40P01" || pgErr.Code == "40001")
+}
+
+// GetMerchItems возвращает каталог товаров без архивных, отсортированный по названию.
+func (s *Storage) GetMerchItems(ctx context.Context) ([]dto.MerchDTO, error) {
+	const op = "storage.Postgres.GetMerchItems"
+
+	sql, args, err := squirrel.Select(merchColumns).
 		From("merch_items").
-		Where(squirrel.Eq{"name": item}).
+		Where("archived_at IS NULL").
+		OrderBy("name").
 		PlaceholderFormat(squirrel.Dollar).
 		ToSql()
 	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	err = s.db.QueryRow(ctx, sqlSelect, argsSelect...).Scan(&merchID, &price)
+	rows, err := s.db.Query(ctx, sql, args...)
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return fmt.Errorf("%s: item not found", op)
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	items := []dto.MerchDTO{}
+	for rows.Next() {
+		item, err := scanMerch(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
 		}
-		return fmt.Errorf("%s: %w", op, err)
+		items = append(items, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
+	return items, nil
+}
+
+// CreateMerchItem добавляет товар в каталог и записывает его начальную цену в историю цен.
+func (s *Storage) CreateMerchItem(ctx context.Context, adminID uuid.UUID, item dto.MerchDTO) (dto.MerchDTO, error) {
+	const op = "storage.Postgres.CreateMerchItem"
+
 	tx, err := s.db.Begin(ctx)
 	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+		return dto.MerchDTO{}, fmt.Errorf("%s: %w", op, err)
 	}
 	defer func() {
 		if err != nil {
@@ -319,35 +733,232 @@ func (s *Storage) BuyItem(ctx context.Context, userID uuid.UUID, item string) er
 		}
 	}()
 
-	deductQuery, deductArgs, err := squirrel.Update("users").
-		Set("coins", squirrel.Expr("coins - ?", price)).
-		Where(squirrel.Eq{"id": userID}).
-		Where("coins >= ?", price).
+	insertQuery, insertArgs, err := squirrel.Insert("merch_items").
+		Columns("name", "price", "available", "description", "stock", "per_user_limit", "sale_starts_at",
+			"sale_ends_at").
+		Values(item.Name, item.Price, item.Available, item.Description, item.Stock, item.PerUserLimit,
+			item.SaleStartsAt, item.SaleEndsAt).
+		Suffix("RETURNING id, " + merchColumns).
 		PlaceholderFormat(squirrel.Dollar).
 		ToSql()
 	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+		return dto.MerchDTO{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	cmdTag, err := tx.Exec(ctx, deductQuery, deductArgs...)
-	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+	var merchID uuid.UUID
+	dest := append([]any{&merchID}, merchDest(&item)...)
+	if err = tx.QueryRow(ctx, insertQuery, insertArgs...).Scan(dest...); err != nil {
+		err = merchWriteError(err)
+		return dto.MerchDTO{}, fmt.Errorf("%s: %w", op, err)
 	}
-	if cmdTag.RowsAffected() == 0 {
-		return fmt.Errorf("%s: insufficient funds", op)
+
+	if err = insertPriceChange(ctx, tx, merchID, nil, item.Price, adminID); err != nil {
+		return dto.MerchDTO{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	insertQuery, insertArgs, err := squirrel.Insert("purchases").
-		Columns("user_id", "merch_id", "price_at_purchase", "created_at").
-		Values(userID, merchID, price, time.Now()).
-		PlaceholderFormat(squirrel.Dollar).
-		ToSql()
-	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+	if err = tx.Commit(ctx); err != nil {
+		return dto.MerchDTO{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	_, err = tx.Exec(ctx, insertQuery, insertArgs...)
-	if err != nil {
+	return item, nil
+}
+
+// UpdateMerchItem меняет заданные поля товара. Архивный товар не меняется, его нужно сначала восстановить.
+// Изменение цены записывается в историю цен вместе с автором.
+func (s *Storage) UpdateMerchItem(ctx context.Context, adminID uuid.UUID, name string,
+	update dto.UpdateMerchRequest) (dto.MerchDTO, error) {
+	const op = "storage.Postgres.UpdateMerchItem"
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return dto.MerchDTO{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	selectQuery, selectArgs, err := squirrel.Select("id", "price").
+		From("merch_items").
+		Where(squirrel.Eq{"name": name}).
+		Where("archived_at IS NULL").
+		Suffix("FOR UPDATE").
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+	if err != nil {
+		return dto.MerchDTO{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var merchID uuid.UUID
+	var oldPrice int
+	if err = tx.QueryRow(ctx, selectQuery, selectArgs...).Scan(&merchID, &oldPrice); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = repository.ErrItemNotFound
+		}
+		return dto.MerchDTO{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	query := squirrel.Update("merch_items").
+		Where(squirrel.Eq{"id": merchID}).
+		Suffix("RETURNING " + merchColumns).
+		PlaceholderFormat(squirrel.Dollar)
+	if update.Name != nil {
+		query = query.Set("name", *update.Name)
+	}
+	if update.Price != nil {
+		query = query.Set("price", *update.Price)
+	}
+	if update.Description != nil {
+		query = query.Set("description", *update.Description)
+	}
+	if update.Available != nil {
+		query = query.Set("available", *update.Available)
+	}
+
+	updateQuery, updateArgs, err := query.ToSql()
+	if err != nil {
+		return dto.MerchDTO{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	item, err := scanMerch(tx.QueryRow(ctx, updateQuery, updateArgs...))
+	if err != nil {
+		err = merchWriteError(err)
+		return dto.MerchDTO{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if item.Price != oldPrice {
+		if err = insertPriceChange(ctx, tx, merchID, &oldPrice, item.Price, adminID); err != nil {
+			return dto.MerchDTO{}, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return dto.MerchDTO{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return item, nil
+}
+
+// SetMerchItemArchived архивирует товар или восстанавливает его из архива. Архивный товар скрыт из каталога и
+// не продается, но покупки с ним остаются в истории. Повторная архивация не меняет время архивации.
+func (s *Storage) SetMerchItemArchived(ctx context.Context, name string, archived bool) (dto.MerchDTO, error) {
+	const op = "storage.Postgres.SetMerchItemArchived"
+
+	archivedAt := squirrel.Expr("NULL")
+	if archived {
+		archivedAt = squirrel.Expr("COALESCE(archived_at, ?)", time.Now())
+	}
+
+	sql, args, err := squirrel.Update("merch_items").
+		Set("archived_at", archivedAt).
+		Where(squirrel.Eq{"name": name}).
+		Suffix("RETURNING " + merchColumns).
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+	if err != nil {
+		return dto.MerchDTO{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	item, err := scanMerch(s.db.QueryRow(ctx, sql, args...))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return dto.MerchDTO{}, fmt.Errorf("%s: %w", op, repository.ErrItemNotFound)
+		}
+		return dto.MerchDTO{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return item, nil
+}
+
+// merchColumns - колонки dto.MerchDTO. available вычисляется: товар можно купить прямо сейчас.
+const merchColumns = `name, price,
+	available AND (stock IS NULL OR stock > 0)
+		AND (sale_starts_at IS NULL OR sale_starts_at <= NOW())
+		AND (sale_ends_at IS NULL OR sale_ends_at > NOW()) AS available,
+	description, archived_at, stock, per_user_limit, sale_starts_at, sale_ends_at`
+
+func merchDest(item *dto.MerchDTO) []any {
+	return []any{&item.Name, &item.Price, &item.Available, &item.Description, &item.ArchivedAt,
+		&item.Stock, &item.PerUserLimit, &item.SaleStartsAt, &item.SaleEndsAt}
+}
+
+func scanMerch(row pgx.Row) (dto.MerchDTO, error) {
+	var item dto.MerchDTO
+	err := row.Scan(merchDest(&item)...)
+	return item, err
+}
+
+// SetMerchItemLimits заменяет ограничения продажи товара: остаток, лимит на пользователя и окно продажи.
+func (s *Storage) SetMerchItemLimits(ctx context.Context, name string, limits dto.MerchLimits) (dto.MerchDTO, error) {
+	const op = "storage.Postgres.SetMerchItemLimits"
+
+	sql, args, err := squirrel.Update("merch_items").
+		Set("stock", limits.Stock).
+		Set("per_user_limit", limits.PerUserLimit).
+		Set("sale_starts_at", limits.SaleStartsAt).
+		Set("sale_ends_at", limits.SaleEndsAt).
+		Where(squirrel.Eq{"name": name}).
+		Where("archived_at IS NULL").
+		Suffix("RETURNING " + merchColumns).
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+	if err != nil {
+		return dto.MerchDTO{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	item, err := scanMerch(s.db.QueryRow(ctx, sql, args...))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return dto.MerchDTO{}, fmt.Errorf("%s: %w", op, repository.ErrItemNotFound)
+		}
+		return dto.MerchDTO{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return item, nil
+}
+
+func insertPriceChange(ctx context.Context, tx pgx.Tx, merchID uuid.UUID, oldPrice *int, newPrice int,
+	adminID uuid.UUID) error {
+	sql, args, err := squirrel.Insert("merch_price_history").
+		Columns("merch_id", "old_price", "new_price", "changed_by", "changed_at").
+		Values(merchID, oldPrice, newPrice, adminID, time.Now()).
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, sql, args...)
+	return err
+}
+
+// merchWriteError превращает нарушение уникальности названия товара в repository.ErrItemAlreadyExists.
+func merchWriteError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return repository.ErrItemAlreadyExists
+	}
+	return err
+}
+
+// BuyItem покупает один товар. Проверка ограничений, списание монет и остатка и запись покупки выполняются
+// в одной транзакции.
+func (s *Storage) BuyItem(ctx context.Context, userID uuid.UUID, item string) error {
+	const op = "storage.Postgres.BuyItem"
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	order := purchaseOrder{buyerID: userID, ownerID: userID, item: item, quantity: 1}
+	if _, err = purchaseItem(ctx, tx, order, time.Now()); err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
@@ -358,6 +969,608 @@ func (s *Storage) BuyItem(ctx context.Context, userID uuid.UUID, item string) er
 	return nil
 }
 
+// Checkout покупает несколько товаров в одной транзакции: либо куплены все позиции, либо ни одной.
+// Цены берутся из merch_items внутри транзакции, на каждую штуку пишется отдельная строка purchases.
+// Транзакция, откаченная из-за взаимной блокировки или конфликта сериализации, повторяется целиком по conflictRetry.
+func (s *Storage) Checkout(ctx context.Context, userID uuid.UUID, items []dto.CheckoutItem) ([]dto.CheckoutLineDTO, error) {
+	const op = "storage.Postgres.Checkout"
+
+	var lines []dto.CheckoutLineDTO
+	err := retry.Do(ctx, conflictRetry, IsTransactionConflict, func(ctx context.Context) error {
+		var err error
+		lines, err = s.checkout(ctx, userID, items)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return lines, nil
+}
+
+// checkout - одна попытка покупки корзины. Строки товаров блокируются заранее в порядке id, поэтому корзины
+// [A, B] и [B, A] разных пользователей не блокируют друг друга, списывая остатки в разном порядке.
+func (s *Storage) checkout(ctx context.Context, userID uuid.UUID, items []dto.CheckoutItem) ([]dto.CheckoutLineDTO, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	names := make([]string, 0, len(items))
+	for _, item := range items {
+		names = append(names, item.Item)
+	}
+	if err = lockMerchItems(ctx, tx, names); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	lines := make([]dto.CheckoutLineDTO, 0, len(items))
+	for _, item := range items {
+		var price int
+		order := purchaseOrder{buyerID: userID, ownerID: userID, item: item.Item, quantity: item.Quantity}
+		price, err = purchaseItem(ctx, tx, order, now)
+		if err != nil {
+			err = fmt.Errorf("%s: %w", item.Item, err)
+			return nil, err
+		}
+		lines = append(lines, dto.CheckoutLineDTO{Item: item.Item, Quantity: item.Quantity, Price: price})
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return lines, nil
+}
+
+// lockMerchItems блокирует строки товаров с ограниченным остатком в порядке id. Товары без остатка
+// не блокируются: их строки не обновляются при покупке.
+func lockMerchItems(ctx context.Context, tx pgx.Tx, names []string) error {
+	sql, args, err := squirrel.Select("id").
+		From("merch_items").
+		Where(squirrel.Eq{"name": names}).
+		Where("stock IS NOT NULL").
+		OrderBy("id").
+		Suffix("FOR NO KEY UPDATE").
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	rows, err := tx.Query(ctx, sql, args...)
+	if err != nil {
+		return err
+	}
+	rows.Close()
+
+	return rows.Err()
+}
+
+// GiftItem покупает товар за монеты отправителя и записывает покупку в инвентарь получателя.
+// Лимит на пользователя проверяется у получателя, так как товар достается ему.
+func (s *Storage) GiftItem(ctx context.Context, fromUserID uuid.UUID, toUsername, item, message string) error {
+	const op = "storage.Postgres.GiftItem"
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	recipientQuery, recipientArgs, err := squirrel.Select("id").
+		From("users").
+		Where(squirrel.Eq{"username": toUsername}).
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	var toUserID uuid.UUID
+	if err = tx.QueryRow(ctx, recipientQuery, recipientArgs...).Scan(&toUserID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = repository.ErrRecipientNotFound
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if toUserID == fromUserID {
+		err = repository.ErrSelfGift
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	order := purchaseOrder{buyerID: fromUserID, ownerID: toUserID, item: item, quantity: 1, gift: true, message: message}
+	if _, err = purchaseItem(ctx, tx, order, time.Now()); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// GetGifts возвращает подарки, полученные и отправленные пользователем, от новых к старым.
+func (s *Storage) GetGifts(ctx context.Context, userID uuid.UUID) (dto.GiftHistoryDTO, error) {
+	const op = "storage.Postgres.GetGifts"
+
+	gifts := dto.GiftHistoryDTO{Received: []dto.ReceivedGiftDTO{}, Sent: []dto.SentGiftDTO{}}
+
+	inQuery, inArgs, err := squirrel.Select().
+		Column(squirrel.Expr("COALESCE(u.username, ?) AS from_user", dto.DeletedUsername)).
+		Columns("m.name", "COALESCE(p.gift_message, '')", "p.created_at").
+		From("purchases p").
+		Join("merch_items m ON m.id = p.merch_id").
+		LeftJoin("users u ON u.id = p.gifted_by").
+		Where(squirrel.Eq{"p.user_id": userID, "p.is_gift": true}).
+		OrderBy("p.created_at DESC", "p.id DESC").
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+	if err != nil {
+		return dto.GiftHistoryDTO{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsIn, err := s.db.Query(ctx, inQuery, inArgs...)
+	if err != nil {
+		return dto.GiftHistoryDTO{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rowsIn.Close()
+
+	for rowsIn.Next() {
+		var gift dto.ReceivedGiftDTO
+		if err := rowsIn.Scan(&gift.FromUser, &gift.Merch, &gift.Message, &gift.CreatedAt); err != nil {
+			return dto.GiftHistoryDTO{}, fmt.Errorf("%s: %w", op, err)
+		}
+		gifts.Received = append(gifts.Received, gift)
+	}
+
+	outQuery, outArgs, err := squirrel.Select("u.username", "m.name", "COALESCE(p.gift_message, '')", "p.created_at").
+		From("purchases p").
+		Join("merch_items m ON m.id = p.merch_id").
+		Join("users u ON u.id = p.user_id").
+		Where(squirrel.Eq{"p.gifted_by": userID}).
+		OrderBy("p.created_at DESC", "p.id DESC").
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+	if err != nil {
+		return dto.GiftHistoryDTO{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rowsOut, err := s.db.Query(ctx, outQuery, outArgs...)
+	if err != nil {
+		return dto.GiftHistoryDTO{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rowsOut.Close()
+
+	for rowsOut.Next() {
+		var gift dto.SentGiftDTO
+		if err := rowsOut.Scan(&gift.ToUser, &gift.Merch, &gift.Message, &gift.CreatedAt); err != nil {
+			return dto.GiftHistoryDTO{}, fmt.Errorf("%s: %w", op, err)
+		}
+		gifts.Sent = append(gifts.Sent, gift)
+	}
+
+	return gifts, nil
+}
+
+// purchaseOrder - покупка quantity штук товара. Монеты списываются у buyerID, покупка записывается на ownerID:
+// у обычной покупки это один и тот же пользователь, у подарка ownerID - получатель.
+type purchaseOrder struct {
+	buyerID  uuid.UUID
+	ownerID  uuid.UUID
+	item     string
+	quantity int
+	gift     bool
+	message  string
+}
+
+// purchaseItem выполняет покупку в транзакции tx и возвращает цену за штуку.
+func purchaseItem(ctx context.Context, tx pgx.Tx, order purchaseOrder, now time.Time) (int, error) {
+	userID, item, quantity := order.ownerID, order.item, order.quantity
+
+	var merchID uuid.UUID
+	var price int
+	var available bool
+	var stock, perUserLimit *int
+	var saleStartsAt, saleEndsAt *time.Time
+
+	sqlSelect, argsSelect, err := squirrel.Select("id", "price", "available", "stock", "per_user_limit",
+		"sale_starts_at", "sale_ends_at").
+		From("merch_items").
+		Where(squirrel.Eq{"name": item}).
+		Where("archived_at IS NULL").
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.QueryRow(ctx, sqlSelect, argsSelect...).
+		Scan(&merchID, &price, &available, &stock, &perUserLimit, &saleStartsAt, &saleEndsAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, repository.ErrItemNotFound
+		}
+		return 0, err
+	}
+
+	switch {
+	case !available:
+		return 0, repository.ErrItemUnavailable
+	case saleStartsAt != nil && now.Before(*saleStartsAt), saleEndsAt != nil && !now.Before(*saleEndsAt):
+		return 0, repository.ErrSaleClosed
+	}
+
+	// остаток списывается до блокировки пользователей: строки merch_items всегда блокируются раньше строк users
+	if stock != nil {
+		stockQuery, stockArgs, err := squirrel.Update("merch_items").
+			Set("stock", squirrel.Expr("stock - ?", quantity)).
+			Where(squirrel.Eq{"id": merchID}).
+			Where("stock >= ?", quantity).
+			PlaceholderFormat(squirrel.Dollar).
+			ToSql()
+		if err != nil {
+			return 0, err
+		}
+
+		cmdTag, err := tx.Exec(ctx, stockQuery, stockArgs...)
+		if err != nil {
+			return 0, err
+		}
+		if cmdTag.RowsAffected() == 0 {
+			return 0, repository.ErrOutOfStock
+		}
+	}
+
+	// лимит считается по владельцу, а у подарка это не покупатель: блокируем обоих до подсчета, иначе
+	// параллельные подарки одному получателю увидят старое число покупок и вместе превысят лимит
+	if _, err := lockUsers(ctx, tx, order.buyerID, order.ownerID); err != nil {
+		return 0, err
+	}
+
+	total := price * quantity
+	deductQuery, deductArgs, err := squirrel.Update("users").
+		Set("coins", squirrel.Expr("coins - ?", total)).
+		Where(squirrel.Eq{"id": order.buyerID}).
+		Where("coins >= ?", total).
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	cmdTag, err := tx.Exec(ctx, deductQuery, deductArgs...)
+	if err != nil {
+		return 0, err
+	}
+	if cmdTag.RowsAffected() == 0 {
+		return 0, repository.ErrInsufficientFunds
+	}
+
+	if perUserLimit != nil {
+		countQuery, countArgs, err := squirrel.Select("COUNT(*)").
+			From("purchases").
+			Where(squirrel.Eq{"user_id": userID, "merch_id": merchID, "returned_at": nil}).
+			PlaceholderFormat(squirrel.Dollar).
+			ToSql()
+		if err != nil {
+			return 0, err
+		}
+
+		var bought int
+		if err := tx.QueryRow(ctx, countQuery, countArgs...).Scan(&bought); err != nil {
+			return 0, err
+		}
+		if bought+quantity > *perUserLimit {
+			return 0, repository.ErrPurchaseLimitReached
+		}
+	}
+
+	var giftedBy *uuid.UUID
+	var message *string
+	if order.gift {
+		giftedBy = &order.buyerID
+		if order.message != "" {
+			message = &order.message
+		}
+	}
+
+	insert := squirrel.Insert("purchases").
+		Columns("user_id", "merch_id", "price_at_purchase", "created_at", "is_gift", "gifted_by", "gift_message").
+		Suffix("RETURNING id").
+		PlaceholderFormat(squirrel.Dollar)
+	for i := 0; i < quantity; i++ {
+		insert = insert.Values(userID, merchID, price, now, order.gift, giftedBy, message)
+	}
+
+	insertQuery, insertArgs, err := insert.ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := tx.Query(ctx, insertQuery, insertArgs...)
+	if err != nil {
+		return 0, err
+	}
+	purchaseIDs, err := pgx.CollectRows(rows, pgx.RowTo[uuid.UUID])
+	if err != nil {
+		return 0, err
+	}
+
+	// проводка на каждую строку purchases, как и у возврата: по reference_id видно, какая покупка или подарок
+	// оплачены, а возврат одной штуки сторнирует ровно одну проводку
+	for _, purchaseID := range purchaseIDs {
+		err = postEntry(ctx, tx, ledgerEntry{
+			kind:        ledgerEntryPurchase,
+			referenceID: &purchaseID,
+			description: item,
+			postings:    []posting{{userAccount(order.buyerID), -price}, {shopAccount, price}},
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return price, nil
+}
+
+// GetPurchaseHistory возвращает покупки пользователя от новых к старым, включая возвращенные.
+func (s *Storage) GetPurchaseHistory(ctx context.Context, userID uuid.UUID) ([]dto.PurchaseRecordDTO, error) {
+	const op = "storage.Postgres.GetPurchaseHistory"
+
+	sql, args, err := squirrel.Select("p.id", "m.name", "p.price_at_purchase", "p.created_at", "p.returned_at").
+		From("purchases p").
+		Join("merch_items m ON p.merch_id = m.id").
+		Where(squirrel.Eq{"p.user_id": userID}).
+		OrderBy("p.created_at DESC", "p.id DESC").
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := s.db.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	purchases := []dto.PurchaseRecordDTO{}
+	for rows.Next() {
+		var purchase dto.PurchaseRecordDTO
+		err := rows.Scan(&purchase.ID, &purchase.Merch, &purchase.Price, &purchase.CreatedAt, &purchase.ReturnedAt)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		purchases = append(purchases, purchase)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return purchases, nil
+}
+
+// ReturnPurchase возвращает покупку, сделанную не раньше window назад: отмечает ее возвращенной, начисляет
+// пользователю price_at_purchase и возвращает товар на склад, если у товара ведется остаток.
+func (s *Storage) ReturnPurchase(ctx context.Context, userID, purchaseID uuid.UUID,
+	window time.Duration) (dto.PurchaseRecordDTO, error) {
+	const op = "storage.Postgres.ReturnPurchase"
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return dto.PurchaseRecordDTO{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	selectQuery, selectArgs, err := squirrel.Select("p.id", "m.name", "p.price_at_purchase", "p.created_at",
+		"p.returned_at", "p.merch_id", "p.is_gift").
+		From("purchases p").
+		Join("merch_items m ON p.merch_id = m.id").
+		Where(squirrel.Eq{"p.id": purchaseID, "p.user_id": userID}).
+		Suffix("FOR UPDATE OF p").
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+	if err != nil {
+		return dto.PurchaseRecordDTO{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var purchase dto.PurchaseRecordDTO
+	var merchID uuid.UUID
+	var gift bool
+	err = tx.QueryRow(ctx, selectQuery, selectArgs...).
+		Scan(&purchase.ID, &purchase.Merch, &purchase.Price, &purchase.CreatedAt, &purchase.ReturnedAt, &merchID, &gift)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = repository.ErrPurchaseNotFound
+		}
+		return dto.PurchaseRecordDTO{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	now := time.Now()
+	switch {
+	case gift:
+		err = repository.ErrGiftNotReturnable
+	case purchase.ReturnedAt != nil:
+		err = repository.ErrPurchaseAlreadyReturned
+	case now.Sub(purchase.CreatedAt) > window:
+		err = repository.ErrReturnWindowExpired
+	}
+	if err != nil {
+		return dto.PurchaseRecordDTO{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	returnQuery, returnArgs, err := squirrel.Update("purchases").
+		Set("returned_at", now).
+		Where(squirrel.Eq{"id": purchaseID}).
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+	if err != nil {
+		return dto.PurchaseRecordDTO{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err = tx.Exec(ctx, returnQuery, returnArgs...); err != nil {
+		return dto.PurchaseRecordDTO{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// товар возвращается на склад до начисления монет, чтобы строка merch_items блокировалась раньше строки users
+	stockQuery, stockArgs, err := squirrel.Update("merch_items").
+		Set("stock", squirrel.Expr("stock + 1")).
+		Where(squirrel.Eq{"id": merchID}).
+		Where("stock IS NOT NULL").
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+	if err != nil {
+		return dto.PurchaseRecordDTO{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err = tx.Exec(ctx, stockQuery, stockArgs...); err != nil {
+		return dto.PurchaseRecordDTO{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	refundQuery, refundArgs, err := squirrel.Update("users").
+		Set("coins", squirrel.Expr("coins + ?", purchase.Price)).
+		Where(squirrel.Eq{"id": userID}).
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+	if err != nil {
+		return dto.PurchaseRecordDTO{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err = tx.Exec(ctx, refundQuery, refundArgs...); err != nil {
+		return dto.PurchaseRecordDTO{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = postEntry(ctx, tx, ledgerEntry{
+		kind:        ledgerEntryRefund,
+		referenceID: &purchaseID,
+		description: purchase.Merch,
+		postings:    []posting{{shopAccount, -purchase.Price}, {userAccount(userID), purchase.Price}},
+	})
+	if err != nil {
+		return dto.PurchaseRecordDTO{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return dto.PurchaseRecordDTO{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	purchase.ReturnedAt = &now
+
+	return purchase, nil
+}
+
+// ReserveIdempotencyKey закрепляет ключ за запросом с хешем requestHash. Если ключ уже был использован
+// (и не истек ttl), возвращается сохраненная запись и created = false.
+func (s *Storage) ReserveIdempotencyKey(ctx context.Context, userID, key, requestHash string,
+	ttl time.Duration) (models.IdempotencyKey, bool, error) {
+	const op = "storage.Postgres.ReserveIdempotencyKey"
+
+	deleteQuery, deleteArgs, err := squirrel.Delete("idempotency_keys").
+		Where(squirrel.Eq{"user_id": userID, "key": key}).
+		Where(squirrel.Lt{"created_at": time.Now().Add(-ttl)}).
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+	if err != nil {
+		return models.IdempotencyKey{}, false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err = s.db.Exec(ctx, deleteQuery, deleteArgs...); err != nil {
+		return models.IdempotencyKey{}, false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	insertQuery, insertArgs, err := squirrel.Insert("idempotency_keys").
+		Columns("user_id", "key", "request_hash", "created_at").
+		Values(userID, key, requestHash, time.Now()).
+		Suffix("ON CONFLICT (user_id, key) DO NOTHING").
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+	if err != nil {
+		return models.IdempotencyKey{}, false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	tag, err := s.db.Exec(ctx, insertQuery, insertArgs...)
+	if err != nil {
+		return models.IdempotencyKey{}, false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	record := models.IdempotencyKey{UserID: userID, Key: key}
+
+	selectQuery, selectArgs, err := squirrel.Select("request_hash", "COALESCE(status_code, 0)", "response_body", "created_at").
+		From("idempotency_keys").
+		Where(squirrel.Eq{"user_id": userID, "key": key}).
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+	if err != nil {
+		return models.IdempotencyKey{}, false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = s.db.QueryRow(ctx, selectQuery, selectArgs...).
+		Scan(&record.RequestHash, &record.StatusCode, &record.ResponseBody, &record.CreatedAt)
+	if err != nil {
+		return models.IdempotencyKey{}, false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return record, tag.RowsAffected() == 1, nil
+}
+
+// CompleteIdempotencyKey сохраняет ответ на запрос, чтобы повторы с тем же ключом получали его без выполнения.
+func (s *Storage) CompleteIdempotencyKey(ctx context.Context, userID, key string, statusCode int, body []byte) error {
+	const op = "storage.Postgres.CompleteIdempotencyKey"
+
+	sql, args, err := squirrel.Update("idempotency_keys").
+		Set("status_code", statusCode).
+		Set("response_body", body).
+		Where(squirrel.Eq{"user_id": userID, "key": key}).
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err = s.db.Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ReleaseIdempotencyKey освобождает ключ, запрос с которым не был выполнен, чтобы его можно было повторить.
+func (s *Storage) ReleaseIdempotencyKey(ctx context.Context, userID, key string) error {
+	const op = "storage.Postgres.ReleaseIdempotencyKey"
+
+	sql, args, err := squirrel.Delete("idempotency_keys").
+		Where(squirrel.Eq{"user_id": userID, "key": key}).
+		Where("status_code IS NULL").
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if _, err = s.db.Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
 func (s *Storage) Close() error {
 	s.db.Close()
 	return nil
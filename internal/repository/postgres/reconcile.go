@@ -0,0 +1,141 @@
+package postgres
+
+import (
+	"avito-shop/internal/domain/dto"
+	"avito-shop/internal/repository"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"time"
+)
+
+// expectedBalanceExpr - баланс пользователя u по истории операций. Подарок оплачивает отправитель (gifted_by),
+// возвращенные покупки не учитываются: их цена вернулась пользователю.
+const expectedBalanceExpr = `? - COALESCE((SELECT SUM(p.price_at_purchase) FROM purchases p
+        WHERE p.returned_at IS NULL AND CASE WHEN p.is_gift THEN p.gifted_by ELSE p.user_id END = u.id), 0)
+    - COALESCE((SELECT SUM(ct.amount) FROM coin_transactions ct WHERE ct.from_user_id = u.id), 0)
+    + COALESCE((SELECT SUM(ct.amount) FROM coin_transactions ct WHERE ct.to_user_id = u.id), 0)
+    + COALESCE((SELECT SUM(ba.amount) FROM balance_adjustments ba WHERE ba.user_id = u.id), 0)`
+
+const ledgerBalanceExpr = `COALESCE((SELECT SUM(lp.amount) FROM ledger_postings lp
+        JOIN ledger_accounts la ON la.id = lp.account_id WHERE la.user_id = u.id), 0)`
+
+func balanceCheckQuery(startingGrant int) squirrel.SelectBuilder {
+	return squirrel.Select("u.id", "u.username", "u.coins").
+		Column(squirrel.Expr(expectedBalanceExpr, startingGrant)).
+		Column(ledgerBalanceExpr).
+		From("users u").
+		PlaceholderFormat(squirrel.Dollar)
+}
+
+func scanBalanceCheck(row pgx.Row) (dto.BalanceDriftDTO, error) {
+	var check dto.BalanceDriftDTO
+	if err := row.Scan(&check.UserID, &check.Username, &check.Coins, &check.Expected, &check.Ledger); err != nil {
+		return dto.BalanceDriftDTO{}, err
+	}
+	check.Drift = check.Coins - check.Expected
+	check.LedgerDrift = check.Coins - check.Ledger
+	return check, nil
+}
+
+// GetBalanceChecks возвращает баланс каждого пользователя вместе с ожидаемым по истории и балансом по журналу.
+func (s *Storage) GetBalanceChecks(ctx context.Context, startingGrant int) ([]dto.BalanceDriftDTO, error) {
+	const op = "storage.Postgres.GetBalanceChecks"
+
+	sql, args, err := balanceCheckQuery(startingGrant).OrderBy("u.username").ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := s.db.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	checks := []dto.BalanceDriftDTO{}
+	for rows.Next() {
+		check, err := scanBalanceCheck(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		checks = append(checks, check)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return checks, nil
+}
+
+// CorrectBalance приводит баланс пользователя к журналу проводок: users.coins перезаписывается балансом
+// по журналу, как в RecomputeBalance, а разница между журналом и историей операций записывается одной
+// корректировкой без автора с причиной reason. Корректировка не проводится по журналу: она объясняет
+// историю, а не двигает монеты. Сверка повторяется под блокировкой строки пользователя; возвращается ее
+// результат до исправления, без расхождений - если их уже нет.
+func (s *Storage) CorrectBalance(ctx context.Context, userID uuid.UUID, startingGrant int,
+	reason string) (dto.BalanceDriftDTO, error) {
+	const op = "storage.Postgres.CorrectBalance"
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return dto.BalanceDriftDTO{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	sql, args, err := balanceCheckQuery(startingGrant).
+		Where(squirrel.Eq{"u.id": userID}).
+		Suffix("FOR NO KEY UPDATE OF u").
+		ToSql()
+	if err != nil {
+		return dto.BalanceDriftDTO{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	check, err := scanBalanceCheck(tx.QueryRow(ctx, sql, args...))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = repository.ErrUserNotFound
+		}
+		return dto.BalanceDriftDTO{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if check.LedgerDrift != 0 {
+		if err = setCachedBalance(ctx, tx, userID, check.Ledger); err != nil {
+			return dto.BalanceDriftDTO{}, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if historyDrift := check.Ledger - check.Expected; historyDrift != 0 {
+		if err = insertHistoryAdjustment(ctx, tx, userID, historyDrift, reason); err != nil {
+			return dto.BalanceDriftDTO{}, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return dto.BalanceDriftDTO{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return check, nil
+}
+
+// insertHistoryAdjustment записывает корректировку без автора, которая объясняет расхождение истории с журналом.
+func insertHistoryAdjustment(ctx context.Context, tx pgx.Tx, userID uuid.UUID, amount int, reason string) error {
+	sql, args, err := squirrel.Insert("balance_adjustments").
+		Columns("user_id", "amount", "reason", "created_at").
+		Values(userID, amount, reason, time.Now()).
+		PlaceholderFormat(squirrel.Dollar).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, sql, args...)
+	return err
+}
@@ -1,18 +1,32 @@
 package redis
 
 import (
+	"avito-shop/internal/repository"
 	"context"
+	"errors"
 	"github.com/redis/go-redis/v9"
 	"strconv"
 	"time"
 )
 
+const (
+	refreshTokenPrefix  = "refresh_token:"
+	refreshFamilyPrefix = "refresh_family:"
+	userFamiliesPrefix  = "user_refresh_families:"
+	accessDenylistKey   = "access_denylist:"
+	userRevokedAtPrefix = "user_revoked_at:"
+	passwordResetPrefix = "password_reset:"
+	loginFailuresPrefix = "login_failures:"
+	loginLockPrefix     = "login_lock:"
+)
+
 type Storage struct {
 	db         *redis.Client
+	accessTTL  time.Duration
 	refreshTTL time.Duration
 }
 
-func InitRedis(connStr, redisPassword, redisDbNumber string, refreshTTL time.Duration) (*Storage, error) {
+func InitRedis(connStr, redisPassword, redisDbNumber string, accessTTL, refreshTTL time.Duration) (*Storage, error) {
 	dbNumber, err := strconv.Atoi(redisDbNumber)
 	if err != nil {
 		return nil, err
@@ -23,16 +37,172 @@ func InitRedis(connStr, redisPassword, redisDbNumber string, refreshTTL time.Dur
 		Password: redisPassword,
 		DB:       dbNumber,
 	})
-	return &Storage{db: redisClient, refreshTTL: refreshTTL}, nil
+	return &Storage{db: redisClient, accessTTL: accessTTL, refreshTTL: refreshTTL}, nil
 }
 
 var ctx = context.Background()
 
-func (s *Storage) StoreRefreshToken(userID, refreshToken string) error {
-	err := s.db.Set(ctx, refreshToken, userID, s.refreshTTL).Err()
-	if err != nil {
+// StoreRefreshToken сохраняет refresh токен и добавляет его в семейство, чтобы при
+// обнаружении повторного использования можно было отозвать все токены цепочки разом.
+func (s *Storage) StoreRefreshToken(userID, family, refreshToken string) error {
+	familyKey := refreshFamilyPrefix + family
+	userKey := userFamiliesPrefix + userID
+
+	pipe := s.db.TxPipeline()
+	pipe.Set(ctx, refreshTokenPrefix+refreshToken, userID, s.refreshTTL)
+	pipe.SAdd(ctx, familyKey, refreshToken)
+	pipe.Expire(ctx, familyKey, s.refreshTTL)
+	pipe.SAdd(ctx, userKey, family)
+	pipe.Expire(ctx, userKey, s.refreshTTL)
+
+	if _, err := pipe.Exec(ctx); err != nil {
 		return err
 	}
 
 	return nil
 }
+
+// ConsumeRefreshToken атомарно удаляет refresh токен и возвращает id его владельца.
+// Если токена нет (уже использован, отозван или истек), возвращает repository.ErrTokenNotFound.
+func (s *Storage) ConsumeRefreshToken(refreshToken string) (string, error) {
+	userID, err := s.db.GetDel(ctx, refreshTokenPrefix+refreshToken).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return "", repository.ErrTokenNotFound
+		}
+		return "", err
+	}
+
+	return userID, nil
+}
+
+// RevokeRefreshFamily удаляет все refresh токены семейства.
+func (s *Storage) RevokeRefreshFamily(family string) error {
+	familyKey := refreshFamilyPrefix + family
+
+	tokens, err := s.db.SMembers(ctx, familyKey).Result()
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(tokens)+1)
+	for _, token := range tokens {
+		keys = append(keys, refreshTokenPrefix+token)
+	}
+	keys = append(keys, familyKey)
+
+	return s.db.Del(ctx, keys...).Err()
+}
+
+// DenyAccessToken заносит jti access токена в denylist до истечения его срока действия.
+func (s *Storage) DenyAccessToken(jti string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return nil
+	}
+
+	return s.db.Set(ctx, accessDenylistKey+jti, 1, ttl).Err()
+}
+
+// RevokeUserTokens отзывает все refresh токены пользователя и запоминает момент отзыва:
+// access токены, выпущенные раньше этого момента, больше не принимаются.
+func (s *Storage) RevokeUserTokens(userID string) error {
+	userKey := userFamiliesPrefix + userID
+
+	if err := s.db.Set(ctx, userRevokedAtPrefix+userID, time.Now().Unix(), s.accessTTL).Err(); err != nil {
+		return err
+	}
+
+	families, err := s.db.SMembers(ctx, userKey).Result()
+	if err != nil {
+		return err
+	}
+
+	for _, family := range families {
+		if err := s.RevokeRefreshFamily(family); err != nil {
+			return err
+		}
+	}
+
+	return s.db.Del(ctx, userKey).Err()
+}
+
+// IsAccessTokenRevoked проверяет, что access токен не был отозван явно или через выход со всех устройств.
+func (s *Storage) IsAccessTokenRevoked(userID, jti string, issuedAt time.Time) (bool, error) {
+	pipe := s.db.Pipeline()
+	denied := pipe.Exists(ctx, accessDenylistKey+jti)
+	revokedAt := pipe.Get(ctx, userRevokedAtPrefix+userID)
+
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return false, err
+	}
+
+	if denied.Val() > 0 {
+		return true, nil
+	}
+
+	revokedAtUnix, err := revokedAt.Int64()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	// iat хранится с точностью до секунды, поэтому токены, выпущенные в ту же секунду,
+	// что и отзыв, считаются новыми; текущий токен при этом отзывается через denylist.
+	return issuedAt.Unix() < revokedAtUnix, nil
+}
+
+// StorePasswordResetToken сохраняет хеш токена сброса пароля на время ttl.
+func (s *Storage) StorePasswordResetToken(tokenHash, userID string, ttl time.Duration) error {
+	return s.db.Set(ctx, passwordResetPrefix+tokenHash, userID, ttl).Err()
+}
+
+// ConsumePasswordResetToken атомарно удаляет токен сброса пароля и возвращает id пользователя.
+func (s *Storage) ConsumePasswordResetToken(tokenHash string) (string, error) {
+	userID, err := s.db.GetDel(ctx, passwordResetPrefix+tokenHash).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return "", repository.ErrTokenNotFound
+		}
+		return "", err
+	}
+
+	return userID, nil
+}
+
+// LoginLockTTL возвращает оставшееся время блокировки входа для ключа или 0, если блокировки нет.
+func (s *Storage) LoginLockTTL(key string) (time.Duration, error) {
+	ttl, err := s.db.PTTL(ctx, loginLockPrefix+key).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	// PTTL возвращает отрицательные значения для отсутствующего ключа и ключа без срока
+	return max(ttl, 0), nil
+}
+
+// RegisterLoginFailure увеличивает счетчик неудачных попыток входа и продлевает его на window.
+func (s *Storage) RegisterLoginFailure(key string, window time.Duration) (int64, error) {
+	failuresKey := loginFailuresPrefix + key
+
+	pipe := s.db.TxPipeline()
+	failures := pipe.Incr(ctx, failuresKey)
+	pipe.Expire(ctx, failuresKey, window)
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+
+	return failures.Val(), nil
+}
+
+// LockLogin блокирует вход для ключа на ttl.
+func (s *Storage) LockLogin(key string, ttl time.Duration) error {
+	return s.db.Set(ctx, loginLockPrefix+key, 1, ttl).Err()
+}
+
+// ResetLoginFailures сбрасывает счетчик неудачных попыток входа.
+func (s *Storage) ResetLoginFailures(key string) error {
+	return s.db.Del(ctx, loginFailuresPrefix+key).Err()
+}
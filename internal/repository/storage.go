@@ -5,7 +5,25 @@ import (
 )
 
 var (
-	ErrUserNotFound      = errors.New("user not found")
-	ErrUserAlreadyExists = errors.New("user already exists")
-	ErrWrongPassword     = errors.New("wrong password")
+	ErrUserNotFound            = errors.New("user not found")
+	ErrUserAlreadyExists       = errors.New("user already exists")
+	ErrEmailAlreadyExists      = errors.New("email already exists")
+	ErrWrongPassword           = errors.New("wrong password")
+	ErrTokenNotFound           = errors.New("token not found")
+	ErrUnsupportedLogin        = errors.New("unsupported login type")
+	ErrNegativeBalance         = errors.New("balance would become negative")
+	ErrInsufficientFunds       = errors.New("insufficient funds")
+	ErrItemNotFound            = errors.New("item not found")
+	ErrItemUnavailable         = errors.New("item is not available for purchase")
+	ErrItemAlreadyExists       = errors.New("item already exists")
+	ErrOutOfStock              = errors.New("item is out of stock")
+	ErrPurchaseLimitReached    = errors.New("purchase limit for this item reached")
+	ErrSaleClosed              = errors.New("item is not on sale now")
+	ErrPurchaseNotFound        = errors.New("purchase not found")
+	ErrPurchaseAlreadyReturned = errors.New("purchase already returned")
+	ErrReturnWindowExpired     = errors.New("return window expired")
+	ErrGiftNotReturnable       = errors.New("gifts cannot be returned")
+	ErrSelfGift                = errors.New("cannot gift to yourself")
+	ErrRecipientNotFound       = errors.New("recipient not found")
+	ErrSelfTransfer            = errors.New("cannot transfer coins to yourself")
 )
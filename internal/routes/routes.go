@@ -1,8 +1,11 @@
 package routes
 
 import (
+	"avito-shop/internal/config"
+	"avito-shop/internal/domain/models"
 	"avito-shop/internal/handlers"
 	"avito-shop/internal/middlewares"
+	"expvar"
 	"github.com/go-openapi/runtime/middleware"
 
 	"github.com/gin-contrib/cors"
@@ -10,15 +13,21 @@ import (
 	"time"
 )
 
-func InitRoutes(authHandler *handlers.AuthHandler, userHandler *handlers.UserHandler, authMiddleware *middlewares.AuthMiddleware) *gin.Engine {
+func InitRoutes(authHandler *handlers.AuthHandler, userHandler *handlers.UserHandler,
+	accountHandler *handlers.AccountHandler, adminHandler *handlers.AdminHandler, merchHandler *handlers.MerchHandler,
+	catalogHandler *handlers.CatalogHandler, purchaseHandler *handlers.PurchaseHandler, keysHandler *handlers.KeysHandler,
+	authMiddleware *middlewares.AuthMiddleware, idempotencyMiddleware *middlewares.IdempotencyMiddleware,
+	apiConfig config.APIConfig) *gin.Engine {
 	router := gin.Default()
 
 	_ = router.SetTrustedProxies(nil)
 
+	router.Use(middlewares.ErrorHandler(handlers.ErrorMappings...))
+
 	router.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"http://localhost:8080"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", middlewares.IdempotencyKeyHeader},
 		ExposeHeaders:    []string{"Content-Length"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
@@ -33,10 +42,18 @@ func InitRoutes(authHandler *handlers.AuthHandler, userHandler *handlers.UserHan
 		sh.ServeHTTP(c.Writer, c.Request)
 	})
 
+	router.GET("/.well-known/jwks.json", keysHandler.JWKS)
+
 	api := router.Group("/api")
 
 	// паблик роут
 	api.POST("/auth", authHandler.Auth)
+	api.POST("/register", authHandler.Register)
+	api.POST("/auth/refresh", authHandler.Refresh)
+	api.POST("/auth/password/forgot", accountHandler.RequestPasswordReset)
+	api.POST("/auth/password/reset", accountHandler.ResetPassword)
+	api.GET("/merch", merchHandler.ListMerch)
+	api.GET("/merch/:name", merchHandler.GetMerch)
 	api.GET("/ping", func(c *gin.Context) {
 		c.JSON(200, gin.H{
 			"message": "pong",
@@ -44,11 +61,39 @@ func InitRoutes(authHandler *handlers.AuthHandler, userHandler *handlers.UserHan
 	})
 
 	// защищенные роуты
+	idempotent := idempotencyMiddleware.Handle()
+
 	api.Use(authMiddleware.Handle())
 	{
+		api.POST("/auth/logout", authHandler.Logout)
+		api.POST("/auth/logout-all", authHandler.LogoutAll)
+		api.POST("/account/password", accountHandler.ChangePassword)
 		api.GET("/info", userHandler.GetUserInfo)
-		api.POST("/sendCoins", userHandler.TransferCoins)
-		api.GET("/buy/:item", userHandler.BuyMerch)
+		api.GET("/transactions", userHandler.GetTransactions)
+		api.POST("/sendCoin", idempotent, userHandler.TransferCoins)
+		api.POST("/sendCoins", idempotent, userHandler.TransferCoins) // старый путь, оставлен для совместимости
+		api.POST("/buy/:item", idempotent, userHandler.BuyMerch)
+		if apiConfig.AllowGetBuy {
+			// покупка через GET может сработать от префетча или краулера, оставлена до перехода клиентов на POST
+			api.GET("/buy/:item", middlewares.Deprecated(), idempotent, userHandler.BuyMerch)
+		}
+		api.POST("/checkout", idempotent, userHandler.Checkout)
+		api.POST("/gift", idempotent, userHandler.GiftMerch)
+		api.GET("/purchases", purchaseHandler.ListPurchases)
+		api.POST("/purchases/:id/return", idempotent, purchaseHandler.ReturnPurchase)
+	}
+
+	// роуты администратора
+	admin := api.Group("/admin", middlewares.RequireRole(models.RoleAdmin))
+	{
+		admin.GET("/debug/vars", gin.WrapH(expvar.Handler()))
+		admin.POST("/users/:username/balance", adminHandler.AdjustBalance)
+		admin.POST("/users/:username/balance/recompute", adminHandler.RecomputeBalance)
+		admin.POST("/merch", catalogHandler.CreateMerch)
+		admin.PATCH("/merch/:name", catalogHandler.UpdateMerch)
+		admin.PUT("/merch/:name/limits", catalogHandler.SetMerchLimits)
+		admin.POST("/merch/:name/archive", catalogHandler.ArchiveMerch)
+		admin.POST("/merch/:name/restore", catalogHandler.RestoreMerch)
 	}
 
 	return router
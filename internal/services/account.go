@@ -0,0 +1,233 @@
+package services
+
+import (
+	"avito-shop/internal/lib/hasher"
+	"avito-shop/internal/middlewares"
+	"avito-shop/internal/repository"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+type AccountService struct {
+	log               *slog.Logger
+	accountRepository AccountRepository
+	tokens            AccountTokenStore
+	mailer            Mailer
+	hasher            PasswordHasher
+	resetTTL          time.Duration
+}
+
+type AccountRepository interface {
+	LoginUser(ctx context.Context, inputType, input string) (string, []byte, error)
+	GetPasswordHash(ctx context.Context, userID uuid.UUID) ([]byte, error)
+	UpdatePassword(ctx context.Context, userID uuid.UUID, passHash []byte) error
+}
+
+type AccountTokenStore interface {
+	StorePasswordResetToken(tokenHash, userID string, ttl time.Duration) error
+	ConsumePasswordResetToken(tokenHash string) (string, error)
+	RevokeUserTokens(userID string) error
+}
+
+// Mailer доставляет письма пользователям.
+type Mailer interface {
+	Send(ctx context.Context, to, subject, body string) error
+}
+
+var (
+	ErrInvalidResetToken = errors.New("invalid password reset token")
+)
+
+func NewAccountService(log *slog.Logger, accountRepository AccountRepository, tokens AccountTokenStore, mailer Mailer,
+	hasher PasswordHasher, resetTTL time.Duration) *AccountService {
+	return &AccountService{
+		log:               log,
+		accountRepository: accountRepository,
+		tokens:            tokens,
+		mailer:            mailer,
+		hasher:            hasher,
+		resetTTL:          resetTTL,
+	}
+}
+
+// ChangePassword меняет пароль после проверки текущего и завершает все сессии пользователя.
+func (s *AccountService) ChangePassword(ctx context.Context, userID uuid.UUID, oldPassword, newPassword string) error {
+	const op = "services.AccountService.ChangePassword"
+
+	log := s.log.With(
+		slog.String("op", op),
+		slog.String("user_id", userID.String()),
+	)
+
+	if err := checkNewPassword(newPassword); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	storedHash, err := s.accountRepository.GetPasswordHash(ctx, userID)
+	if err != nil {
+		log.Error("failed to get password hash", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.hasher.Compare(storedHash, oldPassword); err != nil {
+		if !errors.Is(err, hasher.ErrMismatch) {
+			log.Error("failed to compare password hash", slog.String("error", err.Error()))
+		}
+		log.Info("old password does not match")
+		return fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+	}
+
+	if err := s.setPassword(ctx, log, userID, newPassword); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("password changed")
+
+	return nil
+}
+
+// RequestPasswordReset отправляет на email одноразовый токен сброса пароля. Для неизвестного
+// email ошибка не возвращается, чтобы по ответу нельзя было проверить наличие аккаунта.
+func (s *AccountService) RequestPasswordReset(ctx context.Context, email string) error {
+	const op = "services.AccountService.RequestPasswordReset"
+
+	email = strings.ToLower(strings.TrimSpace(email))
+
+	log := s.log.With(
+		slog.String("op", op),
+	)
+
+	if !middlewares.CorrectEmailChecker(email) {
+		return fmt.Errorf("%s: %w", op, middlewares.ErrInvalidEmail)
+	}
+
+	id, _, err := s.accountRepository.LoginUser(ctx, "email", email)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			log.Info("password reset requested for unknown email")
+			return nil
+		}
+		log.Error("failed to find user by email", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.String("user_id", id))
+
+	token, err := newResetToken()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.tokens.StorePasswordResetToken(hashResetToken(token), id, s.resetTTL); err != nil {
+		log.Error("failed to store password reset token", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	body := fmt.Sprintf("Код для сброса пароля: %s\nКод действует %s и может быть использован один раз.",
+		token, s.resetTTL)
+	if err := s.mailer.Send(ctx, email, "Сброс пароля", body); err != nil {
+		log.Error("failed to send password reset email", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("password reset email sent")
+
+	return nil
+}
+
+// ResetPassword устанавливает новый пароль по токену сброса и завершает все сессии пользователя.
+func (s *AccountService) ResetPassword(ctx context.Context, token, newPassword string) error {
+	const op = "services.AccountService.ResetPassword"
+
+	log := s.log.With(
+		slog.String("op", op),
+	)
+
+	if err := checkNewPassword(newPassword); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if token == "" {
+		return fmt.Errorf("%s: %w", op, ErrInvalidResetToken)
+	}
+
+	storedID, err := s.tokens.ConsumePasswordResetToken(hashResetToken(token))
+	if err != nil {
+		if errors.Is(err, repository.ErrTokenNotFound) {
+			log.Info("unknown or used password reset token")
+			return fmt.Errorf("%s: %w", op, ErrInvalidResetToken)
+		}
+		log.Error("failed to consume password reset token", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	userID, err := uuid.Parse(storedID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, ErrInvalidResetToken)
+	}
+
+	log = log.With(slog.String("user_id", storedID))
+
+	if err := s.setPassword(ctx, log, userID, newPassword); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("password reset")
+
+	return nil
+}
+
+func (s *AccountService) setPassword(ctx context.Context, log *slog.Logger, userID uuid.UUID, password string) error {
+	passHash, err := s.hasher.Hash(password)
+	if err != nil {
+		return err
+	}
+
+	if err := s.accountRepository.UpdatePassword(ctx, userID, passHash); err != nil {
+		log.Error("failed to update password", slog.String("error", err.Error()))
+		return err
+	}
+
+	if err := s.tokens.RevokeUserTokens(userID.String()); err != nil {
+		log.Error("failed to revoke user tokens", slog.String("error", err.Error()))
+		return err
+	}
+
+	return nil
+}
+
+func checkNewPassword(password string) error {
+	if password == "" {
+		return middlewares.ErrEmptyField
+	}
+
+	if len(password) < 8 {
+		return fmt.Errorf("%w: minimum 8 characters required", middlewares.ErrPasswordTooShort)
+	}
+
+	return nil
+}
+
+func newResetToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// hashResetToken - в Redis хранится только хеш токена, чтобы дамп базы не давал возможности сбросить пароль.
+func hashResetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
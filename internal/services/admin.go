@@ -0,0 +1,97 @@
+package services
+
+import (
+	"avito-shop/internal/domain/dto"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"log/slog"
+	"strings"
+)
+
+type AdminService struct {
+	log             *slog.Logger
+	adminRepository AdminRepository
+}
+
+type AdminRepository interface {
+	AdjustBalance(ctx context.Context, adminID uuid.UUID, username string, amount int, reason string) (int, error)
+	RecomputeBalance(ctx context.Context, username string) (dto.BalanceRecomputeDTO, error)
+}
+
+var (
+	ErrInvalidAdjustment = errors.New("adjustment amount must not be zero")
+	ErrReasonRequired    = errors.New("adjustment reason is required")
+)
+
+func NewAdminService(log *slog.Logger, adminRepository AdminRepository) *AdminService {
+	return &AdminService{
+		log:             log,
+		adminRepository: adminRepository,
+	}
+}
+
+// AdjustBalance начисляет (amount > 0) или списывает (amount < 0) монеты пользователю и возвращает новый баланс.
+func (s *AdminService) AdjustBalance(ctx context.Context, adminID uuid.UUID, username string, amount int,
+	reason string) (int, error) {
+	const op = "services.AdminService.AdjustBalance"
+
+	log := s.log.With(
+		slog.String("op", op),
+		slog.String("admin_id", adminID.String()),
+		slog.String("username", username),
+		slog.Int("amount", amount),
+	)
+
+	if amount == 0 {
+		return 0, fmt.Errorf("%s: %w", op, ErrInvalidAdjustment)
+	}
+
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return 0, fmt.Errorf("%s: %w", op, ErrReasonRequired)
+	}
+
+	log.Info("adjusting balance")
+
+	coins, err := s.adminRepository.AdjustBalance(ctx, adminID, username, amount, reason)
+	if err != nil {
+		log.Error("failed to adjust balance", slog.String("error", err.Error()))
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("balance adjusted", slog.String("event", "audit.balance_adjustment"),
+		slog.String("reason", reason), slog.Int("coins", coins))
+
+	return coins, nil
+}
+
+// RecomputeBalance пересчитывает баланс пользователя по журналу проводок и перезаписывает кеш users.coins.
+// Расхождение кеша с журналом логируется как предупреждение: само по себе оно означает ошибку в коде записи.
+func (s *AdminService) RecomputeBalance(ctx context.Context, adminID uuid.UUID,
+	username string) (dto.BalanceRecomputeDTO, error) {
+	const op = "services.AdminService.RecomputeBalance"
+
+	log := s.log.With(
+		slog.String("op", op),
+		slog.String("admin_id", adminID.String()),
+		slog.String("username", username),
+	)
+
+	result, err := s.adminRepository.RecomputeBalance(ctx, username)
+	if err != nil {
+		log.Error("failed to recompute balance", slog.String("error", err.Error()))
+		return dto.BalanceRecomputeDTO{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if result.Cached != result.Ledger {
+		log.Warn("cached balance drifted from ledger", slog.Int("cached", result.Cached),
+			slog.Int("ledger", result.Ledger))
+	}
+
+	log.Info("balance recomputed", slog.String("event", "audit.balance_recompute"),
+		slog.Int("cached", result.Cached), slog.Int("coins", result.Ledger))
+
+	return result, nil
+}
@@ -1,14 +1,17 @@
 package services
 
 import (
+	"avito-shop/internal/lib/hasher"
 	"avito-shop/internal/lib/jwt"
 	"avito-shop/internal/middlewares"
 	"avito-shop/internal/repository"
 	"context"
 	"errors"
 	"fmt"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/google/uuid"
 	"log/slog"
+	"strings"
+	"time"
 )
 
 type AuthService struct {
@@ -16,35 +19,59 @@ type AuthService struct {
 	authRepository AuthRepository
 	redis          RedisClient
 	jwtGen         *jwt.Generator
+	hasher         PasswordHasher
+	autoRegister   bool
 }
 
 type AuthRepository interface {
-	SaveUser(ctx context.Context, login string, password []byte) error
+	SaveUser(ctx context.Context, login, email string, password []byte) error
 	LoginUser(ctx context.Context, inputType, input string) (string, []byte, error)
 	CheckUsernameIsAvailable(ctx context.Context, login string) (bool, error)
+	UpdatePassword(ctx context.Context, userID uuid.UUID, passHash []byte) error
+	GetUserRole(ctx context.Context, userID string) (string, error)
+}
+
+// PasswordHasher хеширует пароли. NeedsRehash сообщает, что хеш создан устаревшим алгоритмом
+// или с устаревшими параметрами и его стоит пересчитать при следующем успешном входе.
+type PasswordHasher interface {
+	Hash(password string) ([]byte, error)
+	Compare(hash []byte, password string) error
+	NeedsRehash(hash []byte) bool
 }
 
 type RedisClient interface {
-	StoreRefreshToken(userID, refreshToken string) error
+	StoreRefreshToken(userID, family, refreshToken string) error
+	ConsumeRefreshToken(refreshToken string) (string, error)
+	RevokeRefreshFamily(family string) error
+	DenyAccessToken(jti string, ttl time.Duration) error
+	RevokeUserTokens(userID string) error
 }
 
 var (
 	ErrInvalidCredentials        = errors.New("invalid credentials")
 	ErrUserAlreadyExists         = errors.New("user already exists")
+	ErrEmailAlreadyExists        = errors.New("email already exists")
 	ErrFailedToGenerateTokens    = errors.New("failed to generate tokens")
 	ErrFailedToStoreRefreshToken = errors.New("failed to store refresh token")
+	ErrInvalidRefreshToken       = errors.New("invalid refresh token")
+	ErrRefreshTokenReused        = errors.New("refresh token reused")
 )
 
+// NewAuthService создает сервис авторизации. Если autoRegister выключен, /api/auth
+// не создает пользователя с неизвестным логином, а регистрация возможна только через Register.
 func NewAuthService(log *slog.Logger, authRepository AuthRepository, redis RedisClient,
-	jwtGen *jwt.Generator) *AuthService {
+	jwtGen *jwt.Generator, hasher PasswordHasher, autoRegister bool) *AuthService {
 	return &AuthService{
 		log:            log,
 		authRepository: authRepository,
 		redis:          redis,
 		jwtGen:         jwtGen,
+		hasher:         hasher,
+		autoRegister:   autoRegister,
 	}
 }
 
+// Login авторизует пользователя по имени пользователя или email.
 func (s *AuthService) Login(ctx context.Context, username, password string) (accessToken string, refreshToken string,
 	err error) {
 	const op = "auth.Auth"
@@ -58,32 +85,22 @@ func (s *AuthService) Login(ctx context.Context, username, password string) (acc
 		return "", "", fmt.Errorf("%s: %w", op, err)
 	}
 
-	id, storedHash, err := s.authRepository.LoginUser(ctx, "username", username)
+	inputType := middlewares.IdentifyLoginInputType(strings.ToLower(username))
+
+	id, storedHash, err := s.lookupUser(ctx, inputType, username)
 	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) && (!s.autoRegister || inputType == "email") {
+			log.Info("user not found", slog.String("input_type", inputType))
+			return "", "", fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+		}
+
 		if errors.Is(err, repository.ErrUserNotFound) {
 			log.Info("user not found, registration")
 
-			log.Info("hashing password")
-
-			passHash, hashErr := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-			if hashErr != nil {
-				return "", "", fmt.Errorf("%s: %w", op, hashErr)
-			}
-
-			log.Info("password hashed")
-
-			log.Info("saving user")
-
-			err := s.authRepository.SaveUser(ctx, username, passHash)
-			if err != nil {
-				if errors.Is(err, repository.ErrUserAlreadyExists) {
-					return "", "", fmt.Errorf("%s: %w", op, ErrUserAlreadyExists)
-				}
+			if err := s.saveUser(ctx, log, username, "", password); err != nil {
 				return "", "", fmt.Errorf("%s: %w", op, err)
 			}
 
-			log.Info("user saved")
-
 			log.Info("login user")
 
 			id, storedHash, err = s.authRepository.LoginUser(ctx, "username", username)
@@ -102,20 +119,259 @@ func (s *AuthService) Login(ctx context.Context, username, password string) (acc
 
 	log.Info("comparing passwords")
 
-	err = bcrypt.CompareHashAndPassword(storedHash, []byte(password))
+	err = s.hasher.Compare(storedHash, password)
 	if err != nil {
-		if errors.Is(err, repository.ErrWrongPassword) {
+		if errors.Is(err, hasher.ErrMismatch) {
 			log.Info("invalid credentials", slog.String("error", err.Error()))
 			return "", "", fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
 		}
+		log.Error("failed to compare password hash", slog.String("error", err.Error()))
 		return "", "", fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
 	}
 
 	log.Info("passwords match")
 
+	if s.hasher.NeedsRehash(storedHash) {
+		s.rehash(ctx, log, id, password)
+	}
+
+	return s.issueTokens(ctx, log, id, uuid.NewString())
+}
+
+// ResolveLoginIdentity возвращает id пользователя, которому принадлежит логин (имя пользователя или email).
+// Для неизвестного логина возвращается repository.ErrUserNotFound.
+func (s *AuthService) ResolveLoginIdentity(ctx context.Context, login string) (string, error) {
+	id, _, err := s.lookupUser(ctx, middlewares.IdentifyLoginInputType(strings.ToLower(login)), login)
+	if err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+// Register явно создает пользователя с email и сразу выдает ему пару токенов.
+func (s *AuthService) Register(ctx context.Context, username, email, password string) (accessToken string,
+	refreshToken string, err error) {
+	const op = "auth.Register"
+
+	email = strings.ToLower(strings.TrimSpace(email))
+
+	log := s.log.With(
+		slog.String("op", op),
+		slog.String("username", username),
+	)
+
+	if err := middlewares.CheckRegister(username, email, password); err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.saveUser(ctx, log, username, email, password); err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, _, err := s.authRepository.LoginUser(ctx, "username", username)
+	if err != nil {
+		log.Error("failed to load registered user", slog.String("error", err.Error()))
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("user registered")
+
+	return s.issueTokens(ctx, log, id, uuid.NewString())
+}
+
+// Refresh обменивает refresh токен на новую пару. Каждый refresh токен одноразовый:
+// предъявление уже использованного токена считается утечкой и отзывает всё семейство.
+func (s *AuthService) Refresh(ctx context.Context, refreshToken string) (accessToken string, newRefreshToken string,
+	err error) {
+	const op = "auth.Refresh"
+
+	log := s.log.With(
+		slog.String("op", op),
+	)
+
+	claims, err := s.jwtGen.ParseRefresh(refreshToken)
+	if err != nil {
+		log.Info("invalid refresh token", slog.String("error", err.Error()))
+		return "", "", fmt.Errorf("%s: %w", op, ErrInvalidRefreshToken)
+	}
+
+	id, family := claims.Subject, claims.Family
+
+	log = log.With(slog.String("user_id", id))
+
+	log.Info("consuming refresh token")
+
+	storedID, err := s.redis.ConsumeRefreshToken(refreshToken)
+	if err != nil {
+		if errors.Is(err, repository.ErrTokenNotFound) {
+			log.Warn("refresh token reuse detected, revoking family", slog.String("family", family))
+
+			if err := s.redis.RevokeRefreshFamily(family); err != nil {
+				log.Error("failed to revoke refresh token family", slog.String("error", err.Error()))
+			}
+
+			return "", "", fmt.Errorf("%s: %w", op, ErrRefreshTokenReused)
+		}
+
+		log.Error("failed to consume refresh token", slog.String("error", err.Error()))
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if storedID != id {
+		log.Warn("refresh token owner mismatch")
+		return "", "", fmt.Errorf("%s: %w", op, ErrInvalidRefreshToken)
+	}
+
+	log.Info("refresh token consumed")
+
+	return s.issueTokens(ctx, log, id, family)
+}
+
+// Logout завершает текущую сессию: access токен попадает в denylist до истечения срока,
+// а переданный refresh токен отзывается вместе со всем семейством.
+func (s *AuthService) Logout(ctx context.Context, userID, accessTokenID string, accessExpiresAt time.Time,
+	refreshToken string) error {
+	const op = "auth.Logout"
+
+	log := s.log.With(
+		slog.String("op", op),
+		slog.String("user_id", userID),
+	)
+
+	family := ""
+	if refreshToken != "" {
+		claims, err := s.jwtGen.ParseRefresh(refreshToken)
+		if err != nil || claims.Subject != userID {
+			log.Info("invalid refresh token on logout")
+			return fmt.Errorf("%s: %w", op, ErrInvalidRefreshToken)
+		}
+		family = claims.Family
+	}
+
+	log.Info("revoking access token")
+
+	if err := s.redis.DenyAccessToken(accessTokenID, time.Until(accessExpiresAt)); err != nil {
+		log.Error("failed to revoke access token", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if family != "" {
+		log.Info("revoking refresh token family")
+
+		if err := s.redis.RevokeRefreshFamily(family); err != nil {
+			log.Error("failed to revoke refresh token family", slog.String("error", err.Error()))
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	log.Info("logged out")
+
+	return nil
+}
+
+// LogoutAll завершает все сессии пользователя: отзывает все refresh токены и все ранее выданные access токены.
+func (s *AuthService) LogoutAll(ctx context.Context, userID, accessTokenID string, accessExpiresAt time.Time) error {
+	const op = "auth.LogoutAll"
+
+	log := s.log.With(
+		slog.String("op", op),
+		slog.String("user_id", userID),
+	)
+
+	log.Info("revoking all user tokens")
+
+	if err := s.redis.RevokeUserTokens(userID); err != nil {
+		log.Error("failed to revoke user tokens", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.redis.DenyAccessToken(accessTokenID, time.Until(accessExpiresAt)); err != nil {
+		log.Error("failed to revoke access token", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("logged out from all sessions")
+
+	return nil
+}
+
+func (s *AuthService) lookupUser(ctx context.Context, inputType, login string) (string, []byte, error) {
+	id, storedHash, err := s.authRepository.LoginUser(ctx, inputType, login)
+	if errors.Is(err, repository.ErrUserNotFound) && inputType == "email" {
+		// имя пользователя может выглядеть как email: такие аккаунты создавались до проверки при регистрации
+		return s.authRepository.LoginUser(ctx, "username", login)
+	}
+
+	return id, storedHash, err
+}
+
+func (s *AuthService) saveUser(ctx context.Context, log *slog.Logger, username, email, password string) error {
+	log.Info("hashing password")
+
+	passHash, err := s.hasher.Hash(password)
+	if err != nil {
+		return err
+	}
+
+	log.Info("password hashed")
+
+	log.Info("saving user")
+
+	err = s.authRepository.SaveUser(ctx, username, email, passHash)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserAlreadyExists) {
+			return ErrUserAlreadyExists
+		}
+		if errors.Is(err, repository.ErrEmailAlreadyExists) {
+			return ErrEmailAlreadyExists
+		}
+		return err
+	}
+
+	log.Info("user saved")
+
+	return nil
+}
+
+// rehash пересчитывает хеш пароля текущим алгоритмом. Ошибка не прерывает вход:
+// хеш будет пересчитан при следующем успешном входе.
+func (s *AuthService) rehash(ctx context.Context, log *slog.Logger, id, password string) {
+	userID, err := uuid.Parse(id)
+	if err != nil {
+		log.Error("failed to parse user id for rehash", slog.String("error", err.Error()))
+		return
+	}
+
+	passHash, err := s.hasher.Hash(password)
+	if err != nil {
+		log.Error("failed to rehash password", slog.String("error", err.Error()))
+		return
+	}
+
+	if err := s.authRepository.UpdatePassword(ctx, userID, passHash); err != nil {
+		log.Error("failed to store rehashed password", slog.String("error", err.Error()))
+		return
+	}
+
+	log.Info("password rehashed")
+}
+
+// issueTokens выдает пару токенов. Роль читается из базы при каждой выдаче, в том числе при refresh,
+// чтобы изменение роли попадало в следующий access токен.
+func (s *AuthService) issueTokens(ctx context.Context, log *slog.Logger, id, family string) (accessToken string,
+	refreshToken string, err error) {
+	const op = "auth.issueTokens"
+
+	role, err := s.authRepository.GetUserRole(ctx, id)
+	if err != nil {
+		log.Error("failed to get user role", slog.String("error", err.Error()))
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
 	log.Info("generating tokens")
 
-	accessToken, refreshToken, err = s.jwtGen.GeneratePair(id)
+	accessToken, refreshToken, err = s.jwtGen.GeneratePair(id, family, role)
 	if err != nil {
 		log.Error("failed to generate tokens", slog.String("error", err.Error()))
 		return "", "", fmt.Errorf("%s: %w", op, ErrFailedToGenerateTokens)
@@ -125,14 +381,12 @@ func (s *AuthService) Login(ctx context.Context, username, password string) (acc
 
 	log.Info("storing refresh token")
 
-	if err := s.redis.StoreRefreshToken(id, refreshToken); err != nil {
+	if err := s.redis.StoreRefreshToken(id, family, refreshToken); err != nil {
 		log.Error("failed to store refresh token", slog.String("error", err.Error()))
 		return "", "", fmt.Errorf("%s: %w", op, ErrFailedToStoreRefreshToken)
 	}
 
 	log.Info("refresh token stored")
 
-	log.Info("tokens stored")
-
 	return accessToken, refreshToken, nil
 }
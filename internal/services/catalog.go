@@ -0,0 +1,257 @@
+package services
+
+import (
+	"avito-shop/internal/domain/dto"
+	"avito-shop/internal/repository"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"log/slog"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const maxMerchNameLength = 50
+
+var (
+	ErrInvalidMerchName     = errors.New("merch name must be 1-50 characters without spaces or slashes")
+	ErrInvalidPrice         = errors.New("price must be positive")
+	ErrEmptyUpdate          = errors.New("nothing to update")
+	ErrInvalidStock         = errors.New("stock must not be negative")
+	ErrInvalidPurchaseLimit = errors.New("per-user limit must be positive")
+	ErrInvalidSaleWindow    = errors.New("sale must start before it ends")
+)
+
+// CatalogService - управление каталогом товаров администраторами.
+type CatalogService struct {
+	log               *slog.Logger
+	catalogRepository CatalogRepository
+	cache             CatalogCache
+}
+
+type CatalogRepository interface {
+	CreateMerchItem(ctx context.Context, adminID uuid.UUID, item dto.MerchDTO) (dto.MerchDTO, error)
+	UpdateMerchItem(ctx context.Context, adminID uuid.UUID, name string, update dto.UpdateMerchRequest) (dto.MerchDTO, error)
+	SetMerchItemArchived(ctx context.Context, name string, archived bool) (dto.MerchDTO, error)
+	SetMerchItemLimits(ctx context.Context, name string, limits dto.MerchLimits) (dto.MerchDTO, error)
+}
+
+// CatalogCache - кеш каталога, который сбрасывается после каждого изменения.
+type CatalogCache interface {
+	InvalidateCache()
+}
+
+func NewCatalogService(log *slog.Logger, catalogRepository CatalogRepository, cache CatalogCache) *CatalogService {
+	return &CatalogService{
+		log:               log,
+		catalogRepository: catalogRepository,
+		cache:             cache,
+	}
+}
+
+// CreateItem добавляет товар в каталог. Без явного available товар сразу поступает в продажу.
+func (s *CatalogService) CreateItem(ctx context.Context, adminID uuid.UUID, req dto.CreateMerchRequest) (dto.MerchDTO, error) {
+	const op = "services.CatalogService.CreateItem"
+
+	log := s.log.With(
+		slog.String("op", op),
+		slog.String("admin_id", adminID.String()),
+		slog.String("item", req.Name),
+	)
+
+	if !validMerchName(req.Name) {
+		return dto.MerchDTO{}, fmt.Errorf("%s: %w", op, ErrInvalidMerchName)
+	}
+
+	if req.Price <= 0 {
+		return dto.MerchDTO{}, fmt.Errorf("%s: %w", op, ErrInvalidPrice)
+	}
+
+	if err := validateLimits(req.MerchLimits); err != nil {
+		return dto.MerchDTO{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	item := dto.MerchDTO{
+		Name:        req.Name,
+		Price:       req.Price,
+		Available:   req.Available == nil || *req.Available,
+		Description: strings.TrimSpace(req.Description),
+		MerchLimits: req.MerchLimits,
+	}
+
+	item, err := s.catalogRepository.CreateMerchItem(ctx, adminID, item)
+	if err != nil {
+		log.Error("failed to create merch item", slog.String("error", err.Error()))
+		return dto.MerchDTO{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.cache.InvalidateCache()
+
+	log.Info("merch item created", slog.String("event", "audit.catalog_change"),
+		slog.String("action", "create"), slog.Int("price", item.Price))
+
+	return item, nil
+}
+
+// UpdateItem меняет цену, название, описание или доступность товара.
+func (s *CatalogService) UpdateItem(ctx context.Context, adminID uuid.UUID, name string,
+	req dto.UpdateMerchRequest) (dto.MerchDTO, error) {
+	const op = "services.CatalogService.UpdateItem"
+
+	log := s.log.With(
+		slog.String("op", op),
+		slog.String("admin_id", adminID.String()),
+		slog.String("item", name),
+	)
+
+	if req.Name == nil && req.Price == nil && req.Description == nil && req.Available == nil {
+		return dto.MerchDTO{}, fmt.Errorf("%s: %w", op, ErrEmptyUpdate)
+	}
+
+	if req.Name != nil && !validMerchName(*req.Name) {
+		return dto.MerchDTO{}, fmt.Errorf("%s: %w", op, ErrInvalidMerchName)
+	}
+
+	if req.Price != nil && *req.Price <= 0 {
+		return dto.MerchDTO{}, fmt.Errorf("%s: %w", op, ErrInvalidPrice)
+	}
+
+	if req.Description != nil {
+		description := strings.TrimSpace(*req.Description)
+		req.Description = &description
+	}
+
+	item, err := s.catalogRepository.UpdateMerchItem(ctx, adminID, name, req)
+	if err != nil {
+		log.Error("failed to update merch item", slog.String("error", err.Error()))
+		return dto.MerchDTO{}, fmt.Errorf("%s: %w", op, merchNotFound(err))
+	}
+
+	s.cache.InvalidateCache()
+
+	log.Info("merch item updated", slog.String("event", "audit.catalog_change"),
+		slog.String("action", "update"), slog.String("new_name", item.Name), slog.Int("price", item.Price))
+
+	return item, nil
+}
+
+// SetLimits заменяет ограничения продажи товара целиком: незаданное ограничение снимается.
+func (s *CatalogService) SetLimits(ctx context.Context, adminID uuid.UUID, name string,
+	limits dto.MerchLimits) (dto.MerchDTO, error) {
+	const op = "services.CatalogService.SetLimits"
+
+	log := s.log.With(
+		slog.String("op", op),
+		slog.String("admin_id", adminID.String()),
+		slog.String("item", name),
+	)
+
+	if err := validateLimits(limits); err != nil {
+		return dto.MerchDTO{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	item, err := s.catalogRepository.SetMerchItemLimits(ctx, name, limits)
+	if err != nil {
+		log.Error("failed to set merch item limits", slog.String("error", err.Error()))
+		return dto.MerchDTO{}, fmt.Errorf("%s: %w", op, merchNotFound(err))
+	}
+
+	s.cache.InvalidateCache()
+
+	log.Info("merch item limits changed", slog.String("event", "audit.catalog_change"),
+		slog.String("action", "limits"))
+
+	return item, nil
+}
+
+// ArchiveItem снимает товар с продажи и скрывает его из каталога, не затрагивая историю покупок.
+func (s *CatalogService) ArchiveItem(ctx context.Context, adminID uuid.UUID, name string) (dto.MerchDTO, error) {
+	const op = "services.CatalogService.ArchiveItem"
+
+	item, err := s.setArchived(ctx, adminID, name, true)
+	if err != nil {
+		return dto.MerchDTO{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return item, nil
+}
+
+// RestoreItem возвращает архивный товар в каталог.
+func (s *CatalogService) RestoreItem(ctx context.Context, adminID uuid.UUID, name string) (dto.MerchDTO, error) {
+	const op = "services.CatalogService.RestoreItem"
+
+	item, err := s.setArchived(ctx, adminID, name, false)
+	if err != nil {
+		return dto.MerchDTO{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return item, nil
+}
+
+func (s *CatalogService) setArchived(ctx context.Context, adminID uuid.UUID, name string,
+	archived bool) (dto.MerchDTO, error) {
+	action := "restore"
+	if archived {
+		action = "archive"
+	}
+
+	log := s.log.With(
+		slog.String("op", "services.CatalogService.setArchived"),
+		slog.String("admin_id", adminID.String()),
+		slog.String("item", name),
+		slog.String("action", action),
+	)
+
+	item, err := s.catalogRepository.SetMerchItemArchived(ctx, name, archived)
+	if err != nil {
+		log.Error("failed to change merch item archive state", slog.String("error", err.Error()))
+		return dto.MerchDTO{}, merchNotFound(err)
+	}
+
+	s.cache.InvalidateCache()
+
+	log.Info("merch item archive state changed", slog.String("event", "audit.catalog_change"))
+
+	return item, nil
+}
+
+// validMerchName - название товара используется в пути /api/buy/:item, поэтому в нем нет пробелов и слешей.
+func validMerchName(name string) bool {
+	if name == "" || utf8.RuneCountInString(name) > maxMerchNameLength {
+		return false
+	}
+
+	for _, r := range name {
+		if r == '/' || unicode.IsSpace(r) || !unicode.IsPrint(r) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func validateLimits(limits dto.MerchLimits) error {
+	if limits.Stock != nil && *limits.Stock < 0 {
+		return ErrInvalidStock
+	}
+
+	if limits.PerUserLimit != nil && *limits.PerUserLimit <= 0 {
+		return ErrInvalidPurchaseLimit
+	}
+
+	if limits.SaleStartsAt != nil && limits.SaleEndsAt != nil && !limits.SaleStartsAt.Before(*limits.SaleEndsAt) {
+		return ErrInvalidSaleWindow
+	}
+
+	return nil
+}
+
+// merchNotFound - для роутов каталога отсутствие товара означает 404, а не ошибку покупки.
+func merchNotFound(err error) error {
+	if errors.Is(err, repository.ErrItemNotFound) {
+		return fmt.Errorf("%w: %w", ErrMerchNotFound, err)
+	}
+	return err
+}
@@ -0,0 +1,183 @@
+package services
+
+import (
+	"avito-shop/internal/repository"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// LoginAttemptStore хранит счетчики неудачных попыток входа и блокировки.
+type LoginAttemptStore interface {
+	LoginLockTTL(key string) (time.Duration, error)
+	RegisterLoginFailure(key string, window time.Duration) (int64, error)
+	LockLogin(key string, ttl time.Duration) error
+	ResetLoginFailures(key string) error
+}
+
+// LoginIdentityResolver находит id пользователя по логину, чтобы вход по имени и по email
+// учитывался одним счетчиком. Для неизвестного логина возвращает repository.ErrUserNotFound.
+type LoginIdentityResolver interface {
+	ResolveLoginIdentity(ctx context.Context, login string) (string, error)
+}
+
+// LoginLimiterConfig задает пороги блокировки. После MaxAttempts неудачных попыток (для IP - MaxAttemptsPerIP)
+// вход блокируется на BaseLockout, и каждая следующая неудача удваивает блокировку вплоть до MaxLockout.
+// Счетчик неудач сбрасывается, если в течение Window не было новых неудачных попыток.
+type LoginLimiterConfig struct {
+	MaxAttempts      int
+	MaxAttemptsPerIP int
+	BaseLockout      time.Duration
+	MaxLockout       time.Duration
+	Window           time.Duration
+}
+
+var ErrTooManyLoginAttempts = errors.New("too many login attempts")
+
+// LockoutError возвращается, когда вход временно заблокирован.
+type LockoutError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockoutError) Error() string {
+	return fmt.Sprintf("too many login attempts, retry after %s", e.RetryAfter)
+}
+
+func (e *LockoutError) Unwrap() error {
+	return ErrTooManyLoginAttempts
+}
+
+// LoginIdentity - аккаунт, к которому относится попытка входа. Определяется один раз на запрос через Identify
+// и передается в Check, Fail и Succeed.
+type LoginIdentity struct {
+	Login string // логин в том виде, в каком его ввели
+	key   string
+}
+
+type LoginLimiter struct {
+	log      *slog.Logger
+	store    LoginAttemptStore
+	resolver LoginIdentityResolver
+	cfg      LoginLimiterConfig
+}
+
+func NewLoginLimiter(log *slog.Logger, store LoginAttemptStore, resolver LoginIdentityResolver,
+	cfg LoginLimiterConfig) *LoginLimiter {
+	return &LoginLimiter{
+		log:      log,
+		store:    store,
+		resolver: resolver,
+		cfg:      cfg,
+	}
+}
+
+// Identify определяет аккаунт по логину. Известный логин сводится к id пользователя, поэтому попытки
+// по имени и по email попадают в один счетчик. Неизвестный логин учитывается по нормализованной строке,
+// чтобы перебор несуществующих имен тоже ограничивался.
+func (l *LoginLimiter) Identify(ctx context.Context, login string) (LoginIdentity, error) {
+	const op = "services.LoginLimiter.Identify"
+
+	id, err := l.resolver.ResolveLoginIdentity(ctx, login)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		return LoginIdentity{Login: login, key: "login:" + strings.ToLower(strings.TrimSpace(login))}, nil
+	}
+	if err != nil {
+		return LoginIdentity{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return LoginIdentity{Login: login, key: "user:" + id}, nil
+}
+
+// Check возвращает *LockoutError, если вход для аккаунта или IP заблокирован.
+func (l *LoginLimiter) Check(ctx context.Context, identity LoginIdentity, ip string) error {
+	const op = "services.LoginLimiter.Check"
+
+	var retryAfter time.Duration
+	for _, key := range []string{identity.key, ipKey(ip)} {
+		ttl, err := l.store.LoginLockTTL(key)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		retryAfter = max(retryAfter, ttl)
+	}
+
+	if retryAfter > 0 {
+		return &LockoutError{RetryAfter: retryAfter}
+	}
+
+	return nil
+}
+
+// Fail учитывает неудачную попытку входа и при превышении порога блокирует аккаунт или IP.
+func (l *LoginLimiter) Fail(ctx context.Context, identity LoginIdentity, ip string) error {
+	const op = "services.LoginLimiter.Fail"
+
+	limits := []struct {
+		key         string
+		scope       string
+		maxAttempts int
+	}{
+		{identity.key, "username", l.cfg.MaxAttempts},
+		{ipKey(ip), "ip", l.cfg.MaxAttemptsPerIP},
+	}
+
+	for _, limit := range limits {
+		failures, err := l.store.RegisterLoginFailure(limit.key, l.cfg.Window)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		if limit.maxAttempts <= 0 || failures < int64(limit.maxAttempts) {
+			continue
+		}
+
+		lockout := l.lockoutFor(failures - int64(limit.maxAttempts))
+		if lockout <= 0 {
+			// ключ без срока жизни заблокировал бы вход навсегда
+			continue
+		}
+
+		if err := l.store.LockLogin(limit.key, lockout); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		l.log.Warn("login locked out",
+			slog.String("event", "audit.login_lockout"),
+			slog.String("scope", limit.scope),
+			slog.String("username", identity.Login),
+			slog.String("ip", ip),
+			slog.Int64("failures", failures),
+			slog.Duration("lockout", lockout),
+		)
+	}
+
+	return nil
+}
+
+// Succeed сбрасывает счетчик неудач аккаунта. Счетчик IP не сбрасывается, чтобы успешный вход
+// в собственный аккаунт не позволял продолжать перебор чужих паролей с того же адреса.
+func (l *LoginLimiter) Succeed(ctx context.Context, identity LoginIdentity) error {
+	const op = "services.LoginLimiter.Succeed"
+
+	if err := l.store.ResetLoginFailures(identity.key); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (l *LoginLimiter) lockoutFor(excess int64) time.Duration {
+	lockout := l.cfg.BaseLockout
+	for i := int64(0); i < excess && lockout < l.cfg.MaxLockout; i++ {
+		lockout *= 2
+	}
+
+	return min(lockout, l.cfg.MaxLockout)
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
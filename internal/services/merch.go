@@ -0,0 +1,72 @@
+package services
+
+import (
+	"avito-shop/internal/domain/dto"
+	"avito-shop/internal/lib/cache"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+var ErrMerchNotFound = errors.New("merch item not found")
+
+type MerchService struct {
+	log             *slog.Logger
+	merchRepository MerchRepository
+	catalog         *cache.Value[[]dto.MerchDTO]
+}
+
+type MerchRepository interface {
+	GetMerchItems(ctx context.Context) ([]dto.MerchDTO, error)
+}
+
+// NewMerchService создает сервис каталога. Каталог кешируется в памяти процесса на cacheTTL: изменения каталога,
+// покупки и возвраты через этот экземпляр сбрасывают кеш сразу, остальные экземпляры приложения увидят их
+// не позже чем через cacheTTL.
+func NewMerchService(log *slog.Logger, merchRepository MerchRepository, cacheTTL time.Duration) *MerchService {
+	return &MerchService{
+		log:             log,
+		merchRepository: merchRepository,
+		catalog:         cache.NewValue[[]dto.MerchDTO](cacheTTL),
+	}
+}
+
+// ListItems возвращает весь каталог товаров.
+func (s *MerchService) ListItems(ctx context.Context) ([]dto.MerchDTO, error) {
+	const op = "services.MerchService.ListItems"
+
+	items, err := s.catalog.Get(func() ([]dto.MerchDTO, error) {
+		return s.merchRepository.GetMerchItems(ctx)
+	})
+	if err != nil {
+		s.log.Error("failed to get merch items", slog.String("op", op), slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return items, nil
+}
+
+// GetItem возвращает товар каталога по названию.
+func (s *MerchService) GetItem(ctx context.Context, name string) (dto.MerchDTO, error) {
+	const op = "services.MerchService.GetItem"
+
+	items, err := s.ListItems(ctx)
+	if err != nil {
+		return dto.MerchDTO{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, item := range items {
+		if item.Name == name {
+			return item, nil
+		}
+	}
+
+	return dto.MerchDTO{}, fmt.Errorf("%s: %w", op, ErrMerchNotFound)
+}
+
+// InvalidateCache сбрасывает кеш каталога. Вызывается после любого изменения merch_items, включая остаток.
+func (s *MerchService) InvalidateCache() {
+	s.catalog.Invalidate()
+}
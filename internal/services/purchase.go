@@ -0,0 +1,73 @@
+package services
+
+import (
+	"avito-shop/internal/domain/dto"
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"log/slog"
+	"time"
+)
+
+// PurchaseService - история покупок пользователя и возвраты.
+type PurchaseService struct {
+	log                *slog.Logger
+	purchaseRepository PurchaseRepository
+	returnWindow       time.Duration
+	catalog            CatalogCache
+}
+
+type PurchaseRepository interface {
+	GetPurchaseHistory(ctx context.Context, userID uuid.UUID) ([]dto.PurchaseRecordDTO, error)
+	ReturnPurchase(ctx context.Context, userID, purchaseID uuid.UUID, window time.Duration) (dto.PurchaseRecordDTO, error)
+}
+
+// NewPurchaseService создает сервис покупок. Возврат кладет товар обратно на склад, поэтому после него
+// кеш каталога catalog сбрасывается.
+func NewPurchaseService(log *slog.Logger, purchaseRepository PurchaseRepository, returnWindow time.Duration,
+	catalog CatalogCache) *PurchaseService {
+	return &PurchaseService{
+		log:                log,
+		purchaseRepository: purchaseRepository,
+		returnWindow:       returnWindow,
+		catalog:            catalog,
+	}
+}
+
+// ListPurchases возвращает покупки пользователя от новых к старым вместе с возвращенными.
+func (s *PurchaseService) ListPurchases(ctx context.Context, userID uuid.UUID) (dto.PurchasesResponse, error) {
+	const op = "services.PurchaseService.ListPurchases"
+
+	purchases, err := s.purchaseRepository.GetPurchaseHistory(ctx, userID)
+	if err != nil {
+		s.log.Error("failed to get purchase history", slog.String("op", op),
+			slog.String("user_id", userID.String()), slog.String("error", err.Error()))
+		return dto.PurchasesResponse{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return dto.PurchasesResponse{Purchases: purchases}, nil
+}
+
+// ReturnPurchase возвращает покупку в пределах окна возврата и начисляет пользователю ее цену на момент покупки.
+func (s *PurchaseService) ReturnPurchase(ctx context.Context, userID, purchaseID uuid.UUID) (dto.PurchaseRecordDTO, error) {
+	const op = "services.PurchaseService.ReturnPurchase"
+
+	log := s.log.With(
+		slog.String("op", op),
+		slog.String("user_id", userID.String()),
+		slog.String("purchase_id", purchaseID.String()),
+	)
+
+	purchase, err := s.purchaseRepository.ReturnPurchase(ctx, userID, purchaseID, s.returnWindow)
+	if err != nil {
+		log.Error("failed to return purchase", slog.String("error", err.Error()))
+		return dto.PurchaseRecordDTO{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.catalog.InvalidateCache()
+
+	log.Info("purchase returned", slog.String("event", "audit.purchase_return"),
+		slog.String("item", purchase.Merch), slog.Int("refund", purchase.Price))
+
+	return purchase, nil
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"avito-shop/internal/domain/dto"
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// ReconcileService сверяет users.coins с журналом проводок и с балансом, ожидаемым по истории покупок,
+// переводов и корректировок. Источник истины - журнал.
+type ReconcileService struct {
+	log                 *slog.Logger
+	reconcileRepository ReconcileRepository
+	startingGrant       int
+}
+
+type ReconcileRepository interface {
+	GetBalanceChecks(ctx context.Context, startingGrant int) ([]dto.BalanceDriftDTO, error)
+	CorrectBalance(ctx context.Context, userID uuid.UUID, startingGrant int, reason string) (dto.BalanceDriftDTO, error)
+}
+
+// NewReconcileService создает сервис сверки. startingGrant - монеты, которые пользователь получает при регистрации.
+func NewReconcileService(log *slog.Logger, reconcileRepository ReconcileRepository,
+	startingGrant int) *ReconcileService {
+	return &ReconcileService{
+		log:                 log,
+		reconcileRepository: reconcileRepository,
+		startingGrant:       startingGrant,
+	}
+}
+
+// Reconcile находит пользователей, у которых users.coins расходится с журналом или с историей операций.
+// Если fix = true, баланс таких пользователей приводится к журналу, расхождение истории записывается
+// корректировкой с причиной reason, и строки отчета помечаются corrected.
+func (s *ReconcileService) Reconcile(ctx context.Context, fix bool, reason string) (dto.ReconcileReport, error) {
+	const op = "services.ReconcileService.Reconcile"
+
+	log := s.log.With(
+		slog.String("op", op),
+		slog.Bool("fix", fix),
+	)
+
+	reason = strings.TrimSpace(reason)
+	if fix && reason == "" {
+		return dto.ReconcileReport{}, fmt.Errorf("%s: %w", op, ErrReasonRequired)
+	}
+
+	checks, err := s.reconcileRepository.GetBalanceChecks(ctx, s.startingGrant)
+	if err != nil {
+		log.Error("failed to get balance checks", slog.String("error", err.Error()))
+		return dto.ReconcileReport{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	report := dto.ReconcileReport{
+		CheckedAt: time.Now(),
+		Users:     len(checks),
+		Drifts:    []dto.BalanceDriftDTO{},
+	}
+
+	for _, check := range checks {
+		if !check.HasDrift() {
+			continue
+		}
+
+		log.Warn("balance drift", slog.String("user_id", check.UserID.String()),
+			slog.String("username", check.Username), slog.Int("coins", check.Coins),
+			slog.Int("expected", check.Expected), slog.Int("ledger", check.Ledger))
+
+		if fix {
+			corrected, err := s.reconcileRepository.CorrectBalance(ctx, check.UserID, s.startingGrant, reason)
+			if err != nil {
+				log.Error("failed to correct balance", slog.String("user_id", check.UserID.String()),
+					slog.String("error", err.Error()))
+				return dto.ReconcileReport{}, fmt.Errorf("%s: %w", op, err)
+			}
+
+			// расхождение исчезло между проверкой и исправлением - исправлять нечего
+			if corrected.HasDrift() {
+				check = corrected
+				check.Corrected = true
+				log.Info("balance corrected", slog.String("event", "audit.balance_reconcile"),
+					slog.String("user_id", check.UserID.String()), slog.String("username", check.Username),
+					slog.Int("cached", check.Coins), slog.Int("coins", check.Ledger),
+					slog.Int("adjustment", check.Ledger-check.Expected), slog.String("reason", reason))
+			}
+		}
+
+		report.Drifts = append(report.Drifts, check)
+	}
+
+	log.Info("balances reconciled", slog.Int("users", report.Users), slog.Int("drifts", len(report.Drifts)))
+
+	return report, nil
+}
@@ -2,29 +2,62 @@ package services
 
 import (
 	"avito-shop/internal/domain/dto"
+	"avito-shop/internal/repository"
 	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
 	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	ErrInvalidAmount      = errors.New("amount must be positive")
+	ErrInvalidDirection   = errors.New("direction must be sent or received")
+	ErrInvalidDateRange   = errors.New("invalid date range")
+	ErrInvalidCursor      = errors.New("invalid cursor")
+	ErrInvalidLimit       = errors.New("limit must be between 1 and 100")
+	ErrEmptyCart          = errors.New("checkout has no items")
+	ErrInvalidQuantity    = errors.New("quantity must be positive and total at most 100 units")
+	ErrGiftMessageTooLong = errors.New("gift message must be at most 200 characters")
+)
+
+const (
+	defaultTransactionsLimit = 20
+	maxTransactionsLimit     = 100
+	maxCheckoutUnits         = 100
+	maxGiftMessageLength     = 200
 )
 
 type UserService struct {
 	log            *slog.Logger
 	userRepository UserRepository
+	catalog        CatalogCache
 }
 
 type UserRepository interface {
 	GetUserById(ctx context.Context, userID uuid.UUID) (dto.UserDTO, error)
 	GetUserPurchases(ctx context.Context, userID uuid.UUID) ([]dto.PurchaseDTO, error)
 	GetCoinTransactions(ctx context.Context, userID uuid.UUID) (dto.TransactionDTO, error)
-	TransferCoins(ctx context.Context, fromUserID, toUserID uuid.UUID, amount int) error
+	GetTransfers(ctx context.Context, userID uuid.UUID, filter dto.TransferFilter) ([]dto.TransferDTO, error)
+	TransferCoins(ctx context.Context, fromUserID uuid.UUID, toUsername string, amount int) error
 	BuyItem(ctx context.Context, userID uuid.UUID, item string) error
+	Checkout(ctx context.Context, userID uuid.UUID, items []dto.CheckoutItem) ([]dto.CheckoutLineDTO, error)
+	GiftItem(ctx context.Context, fromUserID uuid.UUID, toUsername, item, message string) error
+	GetGifts(ctx context.Context, userID uuid.UUID) (dto.GiftHistoryDTO, error)
 }
 
-func NewUserService(log *slog.Logger, userRepository UserRepository) *UserService {
+// NewUserService создает сервис пользователя. Покупки меняют остаток товаров, поэтому после каждой успешной
+// покупки кеш каталога catalog сбрасывается.
+func NewUserService(log *slog.Logger, userRepository UserRepository, catalog CatalogCache) *UserService {
 	return &UserService{
 		log:            log,
 		userRepository: userRepository,
+		catalog:        catalog,
 	}
 }
 
@@ -46,10 +79,16 @@ func (s *UserService) GetUserInfo(ctx context.Context, userID uuid.UUID) (dto.In
 		return dto.InfoResponse{}, fmt.Errorf("%s: %w", op, err)
 	}
 
+	gifts, err := s.userRepository.GetGifts(ctx, userID)
+	if err != nil {
+		return dto.InfoResponse{}, fmt.Errorf("%s: %w", op, err)
+	}
+
 	return dto.InfoResponse{
 		Coins:       user.Coins,
 		Inventory:   inventory,
 		CoinHistory: coinHistory,
+		Gifts:       gifts,
 	}, nil
 }
 
@@ -65,7 +104,7 @@ func (s *UserService) GetUserPurchases(ctx context.Context, userID uuid.UUID) ([
 
 	purchases, err := s.userRepository.GetUserPurchases(ctx, userID)
 	if err != nil {
-		log.Error("failed to get user purchases", err)
+		log.Error("failed to get user purchases", slog.String("error", err.Error()))
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
@@ -86,7 +125,7 @@ func (s *UserService) GetCoinTransactions(ctx context.Context, userID uuid.UUID)
 
 	coinTransactions, err := s.userRepository.GetCoinTransactions(ctx, userID)
 	if err != nil {
-		log.Error("failed to get user coin transactions", err)
+		log.Error("failed to get user coin transactions", slog.String("error", err.Error()))
 		return dto.TransactionDTO{}, fmt.Errorf("%s: %w", op, err)
 	}
 
@@ -95,20 +134,69 @@ func (s *UserService) GetCoinTransactions(ctx context.Context, userID uuid.UUID)
 	return coinTransactions, err
 }
 
-func (s *UserService) TransferCoins(ctx context.Context, fromUserID, toUserID uuid.UUID, amount int) error {
+// GetTransactions возвращает страницу истории переводов пользователя от новых к старым. Для следующей
+// страницы клиент передает NextCursor из ответа, пустой NextCursor означает, что страниц больше нет.
+func (s *UserService) GetTransactions(ctx context.Context, userID uuid.UUID,
+	req dto.TransactionsRequest) (dto.TransactionsResponse, error) {
+	const op = "services.UserService.GetTransactions"
+
+	log := s.log.With(
+		slog.String("op", op),
+		slog.String("user_id", userID.String()),
+	)
+
+	filter, err := transferFilter(req)
+	if err != nil {
+		return dto.TransactionsResponse{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// запрашиваем на одну запись больше, чтобы узнать, есть ли следующая страница
+	limit := filter.Limit
+	filter.Limit++
+
+	transfers, err := s.userRepository.GetTransfers(ctx, userID, filter)
+	if err != nil {
+		log.Error("failed to get transfers", slog.String("error", err.Error()))
+		return dto.TransactionsResponse{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if transfers == nil {
+		transfers = []dto.TransferDTO{}
+	}
+
+	resp := dto.TransactionsResponse{Transactions: transfers}
+	if len(transfers) > limit {
+		resp.Transactions = transfers[:limit]
+		last := resp.Transactions[limit-1]
+		resp.NextCursor = encodeTransferCursor(last.CreatedAt, last.ID)
+	}
+
+	return resp, nil
+}
+
+// TransferCoins переводит amount монет от fromUserID пользователю с именем toUsername.
+func (s *UserService) TransferCoins(ctx context.Context, fromUserID uuid.UUID, toUsername string, amount int) error {
 	const op = "services.UserService.TransferCoins"
 
 	log := s.log.With(
 		slog.String("op", op),
 		slog.String("from_user_id", fromUserID.String()),
-		slog.String("to_user_id", toUserID.String()),
+		slog.String("to_user", toUsername),
 		slog.Int("amount", amount),
 	)
 
+	if amount <= 0 {
+		return fmt.Errorf("%s: %w", op, ErrInvalidAmount)
+	}
+
+	if toUsername == "" {
+		return fmt.Errorf("%s: %w", op, repository.ErrRecipientNotFound)
+	}
+
 	log.Info("sending coins")
 
-	if err := s.userRepository.TransferCoins(ctx, fromUserID, toUserID, amount); err != nil {
-		log.Error("failed to transfer coins", err)
+	if err := s.userRepository.TransferCoins(ctx, fromUserID, toUsername, amount); err != nil {
+		log.Error("failed to transfer coins", slog.String("error", err.Error()))
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
@@ -129,11 +217,181 @@ func (s *UserService) BuyItem(ctx context.Context, userID uuid.UUID, item string
 	log.Info("buying item")
 
 	if err := s.userRepository.BuyItem(ctx, userID, item); err != nil {
-		log.Error("failed to buy item", err)
+		log.Error("failed to buy item", slog.String("error", err.Error()))
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
+	s.catalog.InvalidateCache()
+
 	log.Info("bought item")
 
 	return nil
 }
+
+// GiftItem покупает товар за монеты отправителя в подарок пользователю toUsername.
+func (s *UserService) GiftItem(ctx context.Context, fromUserID uuid.UUID, req dto.GiftRequest) error {
+	const op = "services.UserService.GiftItem"
+
+	log := s.log.With(
+		slog.String("op", op),
+		slog.String("user_id", fromUserID.String()),
+		slog.String("to_user", req.ToUser),
+		slog.String("item", req.Item),
+	)
+
+	message := strings.TrimSpace(req.Message)
+	if utf8.RuneCountInString(message) > maxGiftMessageLength {
+		return fmt.Errorf("%s: %w", op, ErrGiftMessageTooLong)
+	}
+
+	log.Info("gifting item")
+
+	if err := s.userRepository.GiftItem(ctx, fromUserID, req.ToUser, req.Item, message); err != nil {
+		log.Error("failed to gift item", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.catalog.InvalidateCache()
+
+	log.Info("gifted item")
+
+	return nil
+}
+
+// Checkout покупает корзину товаров атомарно. Повторяющиеся позиции объединяются в порядке первого появления.
+func (s *UserService) Checkout(ctx context.Context, userID uuid.UUID, req dto.CheckoutRequest) (dto.CheckoutResponse, error) {
+	const op = "services.UserService.Checkout"
+
+	log := s.log.With(
+		slog.String("op", op),
+		slog.String("user_id", userID.String()),
+	)
+
+	if len(req.Items) == 0 {
+		return dto.CheckoutResponse{}, fmt.Errorf("%s: %w", op, ErrEmptyCart)
+	}
+
+	items := make([]dto.CheckoutItem, 0, len(req.Items))
+	positions := make(map[string]int, len(req.Items))
+	units := 0
+	for _, item := range req.Items {
+		if item.Quantity <= 0 {
+			return dto.CheckoutResponse{}, fmt.Errorf("%s: %w", op, ErrInvalidQuantity)
+		}
+		units += item.Quantity
+		if units > maxCheckoutUnits {
+			return dto.CheckoutResponse{}, fmt.Errorf("%s: %w", op, ErrInvalidQuantity)
+		}
+
+		if i, ok := positions[item.Item]; ok {
+			items[i].Quantity += item.Quantity
+			continue
+		}
+		positions[item.Item] = len(items)
+		items = append(items, item)
+	}
+
+	log.Info("checking out", slog.Int("units", units))
+
+	lines, err := s.userRepository.Checkout(ctx, userID, items)
+	if err != nil {
+		log.Error("failed to check out", slog.String("error", err.Error()))
+		return dto.CheckoutResponse{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.catalog.InvalidateCache()
+
+	resp := dto.CheckoutResponse{Items: lines}
+	for _, line := range lines {
+		resp.Total += line.Price * line.Quantity
+	}
+
+	log.Info("checked out", slog.Int("total", resp.Total))
+
+	return resp, nil
+}
+
+func transferFilter(req dto.TransactionsRequest) (dto.TransferFilter, error) {
+	filter := dto.TransferFilter{Limit: req.Limit}
+
+	switch req.Direction {
+	case "", dto.DirectionSent, dto.DirectionReceived:
+		filter.Direction = req.Direction
+	default:
+		return dto.TransferFilter{}, ErrInvalidDirection
+	}
+
+	if filter.Limit == 0 {
+		filter.Limit = defaultTransactionsLimit
+	}
+	if filter.Limit < 0 || filter.Limit > maxTransactionsLimit {
+		return dto.TransferFilter{}, ErrInvalidLimit
+	}
+
+	var err error
+	if filter.From, err = parseTimeFilter(req.From); err != nil {
+		return dto.TransferFilter{}, err
+	}
+	if filter.To, err = parseTimeFilter(req.To); err != nil {
+		return dto.TransferFilter{}, err
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return dto.TransferFilter{}, ErrInvalidDateRange
+	}
+
+	if req.Cursor != "" {
+		if filter.BeforeCreatedAt, filter.BeforeID, err = decodeTransferCursor(req.Cursor); err != nil {
+			return dto.TransferFilter{}, err
+		}
+	}
+
+	return filter, nil
+}
+
+// parseTimeFilter принимает дату в RFC 3339 или YYYY-MM-DD (полночь UTC). Пустая строка - нет ограничения.
+func parseTimeFilter(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %q is not a date", ErrInvalidDateRange, value)
+	}
+
+	return t, nil
+}
+
+// курсор - непрозрачная для клиента позиция последнего перевода страницы: время создания и id
+func encodeTransferCursor(createdAt time.Time, id uuid.UUID) string {
+	raw := strconv.FormatInt(createdAt.UnixNano(), 10) + ":" + id.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeTransferCursor(cursor string) (time.Time, uuid.UUID, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, uuid.Nil, ErrInvalidCursor
+	}
+
+	nanos, idPart, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return time.Time{}, uuid.Nil, ErrInvalidCursor
+	}
+
+	unixNano, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return time.Time{}, uuid.Nil, ErrInvalidCursor
+	}
+
+	id, err := uuid.Parse(idPart)
+	if err != nil {
+		return time.Time{}, uuid.Nil, ErrInvalidCursor
+	}
+
+	return time.Unix(0, unixNano), id, nil
+}
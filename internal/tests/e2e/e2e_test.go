@@ -1,8 +1,11 @@
 package e2e
 
 import (
+	"avito-shop/internal/config"
 	"avito-shop/internal/domain/dto"
+	"avito-shop/internal/domain/models"
 	"avito-shop/internal/handlers"
+	"avito-shop/internal/lib/hasher"
 	"avito-shop/internal/lib/jwt"
 	"avito-shop/internal/middlewares"
 	"avito-shop/internal/repository"
@@ -11,10 +14,13 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"expvar"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"sort"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -24,6 +30,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
 )
 
 type memoryStorage struct {
@@ -31,33 +38,71 @@ type memoryStorage struct {
 	users        map[uuid.UUID]*userRecord
 	purchases    []purchaseRecord
 	transactions []transactionRecord
-	merchPrices  map[string]int
+	merch        map[string]*merchRecord
+	priceChanges []priceChangeRecord
+	idempotency  map[string]models.IdempotencyKey
 }
 
 type userRecord struct {
 	username string
+	email    string
 	password []byte
 	coins    int
+	role     string
 }
 
 type purchaseRecord struct {
-	userID uuid.UUID
-	merch  string
+	id         uuid.UUID
+	userID     uuid.UUID
+	merch      *merchRecord
+	price      int
+	createdAt  time.Time
+	returnedAt *time.Time
+	gift       bool
+	giftedBy   uuid.UUID
+	message    string
+}
+
+type merchRecord struct {
+	name        string
+	price       int
+	description string
+	available   bool
+	archivedAt  *time.Time
+	limits      dto.MerchLimits
+}
+
+type priceChangeRecord struct {
+	merch     *merchRecord
+	oldPrice  int
+	newPrice  int
+	changedBy uuid.UUID
 }
 
 type transactionRecord struct {
-	from   uuid.UUID
-	to     uuid.UUID
-	amount int
+	id        uuid.UUID
+	from      uuid.UUID
+	to        uuid.UUID
+	amount    int
+	createdAt time.Time
 }
 
 func newMemoryStorage() *memoryStorage {
 	return &memoryStorage{
 		users:       make(map[uuid.UUID]*userRecord),
-		merchPrices: defaultMerchPrices(),
+		merch:       defaultMerch(),
+		idempotency: make(map[string]models.IdempotencyKey),
 	}
 }
 
+func defaultMerch() map[string]*merchRecord {
+	merch := make(map[string]*merchRecord)
+	for name, price := range defaultMerchPrices() {
+		merch[name] = &merchRecord{name: name, price: price, available: true}
+	}
+	return merch
+}
+
 func defaultMerchPrices() map[string]int {
 	return map[string]int{
 		"t-shirt":    8000,
@@ -79,9 +124,10 @@ func (s *memoryStorage) reset() {
 	s.users = make(map[uuid.UUID]*userRecord)
 	s.purchases = nil
 	s.transactions = nil
+	s.idempotency = make(map[string]models.IdempotencyKey)
 }
 
-func (s *memoryStorage) SaveUser(ctx context.Context, username string, passHash []byte) error {
+func (s *memoryStorage) SaveUser(ctx context.Context, username, email string, passHash []byte) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -89,11 +135,15 @@ func (s *memoryStorage) SaveUser(ctx context.Context, username string, passHash
 		if user.username == username {
 			return repository.ErrUserAlreadyExists
 		}
+		if email != "" && user.email == email {
+			return repository.ErrEmailAlreadyExists
+		}
 	}
 
 	id := uuid.New()
 	s.users[id] = &userRecord{
 		username: username,
+		email:    email,
 		password: passHash,
 		coins:    100000,
 	}
@@ -108,6 +158,9 @@ func (s *memoryStorage) LoginUser(ctx context.Context, inputType, input string)
 		if inputType == "username" && user.username == input {
 			return id.String(), user.password, nil
 		}
+		if inputType == "email" && user.email != "" && strings.EqualFold(user.email, input) {
+			return id.String(), user.password, nil
+		}
 	}
 
 	return "", nil, repository.ErrUserNotFound
@@ -125,6 +178,47 @@ func (s *memoryStorage) CheckUsernameIsAvailable(ctx context.Context, username s
 	return true, nil
 }
 
+func (s *memoryStorage) GetPasswordHash(ctx context.Context, userID uuid.UUID) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[userID]
+	if !ok {
+		return nil, repository.ErrUserNotFound
+	}
+	return user.password, nil
+}
+
+func (s *memoryStorage) UpdatePassword(ctx context.Context, userID uuid.UUID, passHash []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[userID]
+	if !ok {
+		return repository.ErrUserNotFound
+	}
+	user.password = passHash
+	return nil
+}
+
+func (s *memoryStorage) GetUserRole(ctx context.Context, userID string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return "", repository.ErrUserNotFound
+	}
+	user, ok := s.users[id]
+	if !ok {
+		return "", repository.ErrUserNotFound
+	}
+	if user.role == "" {
+		return models.RoleUser, nil
+	}
+	return user.role, nil
+}
+
 func (s *memoryStorage) GetUserById(ctx context.Context, userID uuid.UUID) (dto.UserDTO, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -141,21 +235,82 @@ func (s *memoryStorage) GetUserPurchases(ctx context.Context, userID uuid.UUID)
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	counts := make(map[string]int)
+	items := make(map[string]*dto.PurchaseDTO)
 	for _, p := range s.purchases {
-		if p.userID == userID {
-			counts[p.merch]++
+		if p.userID != userID {
+			continue
+		}
+		item, ok := items[p.merch.name]
+		if !ok {
+			item = &dto.PurchaseDTO{Merch: p.merch.name}
+			items[p.merch.name] = item
+		}
+		if p.returnedAt != nil {
+			item.Returned++
+		} else {
+			item.Amount++
 		}
 	}
 
 	var result []dto.PurchaseDTO
-	for merch, amount := range counts {
-		result = append(result, dto.PurchaseDTO{Merch: merch, Amount: amount})
+	for _, item := range items {
+		result = append(result, *item)
 	}
 
 	return result, nil
 }
 
+func (s *memoryStorage) GetPurchaseHistory(ctx context.Context, userID uuid.UUID) ([]dto.PurchaseRecordDTO, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	purchases := []dto.PurchaseRecordDTO{}
+	for i := len(s.purchases) - 1; i >= 0; i-- {
+		if p := s.purchases[i]; p.userID == userID {
+			purchases = append(purchases, p.dto())
+		}
+	}
+	return purchases, nil
+}
+
+func (s *memoryStorage) ReturnPurchase(ctx context.Context, userID, purchaseID uuid.UUID,
+	window time.Duration) (dto.PurchaseRecordDTO, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.purchases {
+		p := &s.purchases[i]
+		if p.id != purchaseID || p.userID != userID {
+			continue
+		}
+		if p.gift {
+			return dto.PurchaseRecordDTO{}, repository.ErrGiftNotReturnable
+		}
+		if p.returnedAt != nil {
+			return dto.PurchaseRecordDTO{}, repository.ErrPurchaseAlreadyReturned
+		}
+		now := time.Now()
+		if now.Sub(p.createdAt) > window {
+			return dto.PurchaseRecordDTO{}, repository.ErrReturnWindowExpired
+		}
+
+		p.returnedAt = &now
+		s.users[userID].coins += p.price
+		if stock := p.merch.limits.Stock; stock != nil {
+			left := *stock + 1
+			p.merch.limits.Stock = &left
+		}
+		return p.dto(), nil
+	}
+	return dto.PurchaseRecordDTO{}, repository.ErrPurchaseNotFound
+}
+
+func (p purchaseRecord) dto() dto.PurchaseRecordDTO {
+	return dto.PurchaseRecordDTO{
+		ID: p.id, Merch: p.merch.name, Price: p.price, CreatedAt: p.createdAt, ReturnedAt: p.returnedAt,
+	}
+}
+
 func (s *memoryStorage) GetCoinTransactions(ctx context.Context, userID uuid.UUID) (dto.TransactionDTO, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -172,19 +327,73 @@ func (s *memoryStorage) GetCoinTransactions(ctx context.Context, userID uuid.UUI
 		}
 	}
 
-	toDTO := func(m map[uuid.UUID]int) []dto.CoinTransactionDTO {
-		var res []dto.CoinTransactionDTO
-		for id, total := range m {
-			user := s.users[id]
-			res = append(res, dto.CoinTransactionDTO{Username: user.username, TotalAmount: total})
+	history := dto.TransactionDTO{Received: []dto.ReceivedCoinsDTO{}, Sent: []dto.SentCoinsDTO{}}
+	for id, total := range received {
+		history.Received = append(history.Received, dto.ReceivedCoinsDTO{FromUser: s.username(id), Amount: total})
+	}
+	for id, total := range sent {
+		history.Sent = append(history.Sent, dto.SentCoinsDTO{ToUser: s.username(id), Amount: total})
+	}
+
+	return history, nil
+}
+
+func (s *memoryStorage) username(id uuid.UUID) string {
+	if user, ok := s.users[id]; ok {
+		return user.username
+	}
+	return dto.DeletedUsername
+}
+
+func (s *memoryStorage) GetTransfers(ctx context.Context, userID uuid.UUID,
+	filter dto.TransferFilter) ([]dto.TransferDTO, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var transfers []dto.TransferDTO
+	for _, tx := range s.transactions {
+		transfer := dto.TransferDTO{ID: tx.id, Amount: tx.amount, CreatedAt: tx.createdAt}
+		switch {
+		case tx.from == userID && filter.Direction != dto.DirectionReceived:
+			transfer.Direction, transfer.Counterparty = dto.DirectionSent, s.username(tx.to)
+		case tx.to == userID && filter.Direction != dto.DirectionSent:
+			transfer.Direction, transfer.Counterparty = dto.DirectionReceived, s.username(tx.from)
+		default:
+			continue
+		}
+
+		if !filter.From.IsZero() && tx.createdAt.Before(filter.From) {
+			continue
+		}
+		if !filter.To.IsZero() && !tx.createdAt.Before(filter.To) {
+			continue
 		}
-		return res
+		if !filter.BeforeCreatedAt.IsZero() && !transferBefore(transfer, filter.BeforeCreatedAt, filter.BeforeID) {
+			continue
+		}
+
+		transfers = append(transfers, transfer)
+	}
+
+	sort.Slice(transfers, func(i, j int) bool {
+		return transferBefore(transfers[j], transfers[i].CreatedAt, transfers[i].ID)
+	})
+
+	if len(transfers) > filter.Limit {
+		transfers = transfers[:filter.Limit]
 	}
+	return transfers, nil
+}
 
-	return dto.TransactionDTO{Received: toDTO(received), Sent: toDTO(sent)}, nil
+// transferBefore повторяет сравнение (created_at, id) < (createdAt, id) из Postgres
+func transferBefore(t dto.TransferDTO, createdAt time.Time, id uuid.UUID) bool {
+	if !t.CreatedAt.Equal(createdAt) {
+		return t.CreatedAt.Before(createdAt)
+	}
+	return t.ID.String() < id.String()
 }
 
-func (s *memoryStorage) TransferCoins(ctx context.Context, fromUserID, toUserID uuid.UUID, amount int) error {
+func (s *memoryStorage) TransferCoins(ctx context.Context, fromUserID uuid.UUID, toUsername string, amount int) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -192,18 +401,30 @@ func (s *memoryStorage) TransferCoins(ctx context.Context, fromUserID, toUserID
 	if !ok {
 		return repository.ErrUserNotFound
 	}
-	toUser, ok := s.users[toUserID]
-	if !ok {
-		return repository.ErrUserNotFound
+
+	var toUserID uuid.UUID
+	var toUser *userRecord
+	for id, user := range s.users {
+		if user.username == toUsername {
+			toUserID, toUser = id, user
+		}
+	}
+	if toUser == nil {
+		return repository.ErrRecipientNotFound
+	}
+	if toUserID == fromUserID {
+		return repository.ErrSelfTransfer
 	}
 
 	if fromUser.coins < amount {
-		return fmt.Errorf("insufficient funds")
+		return repository.ErrInsufficientFunds
 	}
 
 	fromUser.coins -= amount
 	toUser.coins += amount
-	s.transactions = append(s.transactions, transactionRecord{from: fromUserID, to: toUserID, amount: amount})
+	s.transactions = append(s.transactions, transactionRecord{
+		id: uuid.New(), from: fromUserID, to: toUserID, amount: amount, createdAt: time.Now(),
+	})
 	return nil
 }
 
@@ -211,197 +432,1709 @@ func (s *memoryStorage) BuyItem(ctx context.Context, userID uuid.UUID, item stri
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	_, err := s.purchase(userID, userID, item, 1)
+	return err
+}
+
+// Checkout при ошибке восстанавливает баланс, остатки и покупки, как откат транзакции.
+func (s *memoryStorage) Checkout(ctx context.Context, userID uuid.UUID,
+	items []dto.CheckoutItem) ([]dto.CheckoutLineDTO, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	user, ok := s.users[userID]
 	if !ok {
-		return repository.ErrUserNotFound
+		return nil, repository.ErrUserNotFound
 	}
-	price, ok := s.merchPrices[item]
+	coins, purchases := user.coins, len(s.purchases)
+	stocks := make(map[*merchRecord]*int, len(s.merch))
+	for _, merch := range s.merch {
+		stocks[merch] = merch.limits.Stock
+	}
+
+	lines := make([]dto.CheckoutLineDTO, 0, len(items))
+	for _, item := range items {
+		price, err := s.purchase(userID, userID, item.Item, item.Quantity)
+		if err != nil {
+			user.coins, s.purchases = coins, s.purchases[:purchases]
+			for merch, stock := range stocks {
+				merch.limits.Stock = stock
+			}
+			return nil, err
+		}
+		lines = append(lines, dto.CheckoutLineDTO{Item: item.Item, Quantity: item.Quantity, Price: price})
+	}
+	return lines, nil
+}
+
+// purchase списывает монеты у buyerID и записывает покупку на ownerID.
+func (s *memoryStorage) purchase(buyerID, userID uuid.UUID, item string, quantity int) (int, error) {
+	user, ok := s.users[buyerID]
 	if !ok {
-		return fmt.Errorf("item not found")
+		return 0, repository.ErrUserNotFound
+	}
+	merch, ok := s.merch[item]
+	if !ok || merch.archivedAt != nil {
+		return 0, repository.ErrItemNotFound
+	}
+	if !merch.available {
+		return 0, repository.ErrItemUnavailable
 	}
-	if user.coins < price {
-		return fmt.Errorf("insufficient funds")
+	if !merch.onSale(time.Now()) {
+		return 0, repository.ErrSaleClosed
+	}
+	if user.coins < merch.price*quantity {
+		return 0, repository.ErrInsufficientFunds
+	}
+	if limit := merch.limits.PerUserLimit; limit != nil && s.boughtCount(userID, merch)+quantity > *limit {
+		return 0, repository.ErrPurchaseLimitReached
+	}
+	if stock := merch.limits.Stock; stock != nil {
+		if *stock < quantity {
+			return 0, repository.ErrOutOfStock
+		}
+		left := *stock - quantity
+		merch.limits.Stock = &left
 	}
 
-	user.coins -= price
-	s.purchases = append(s.purchases, purchaseRecord{userID: userID, merch: item})
-	return nil
+	user.coins -= merch.price * quantity
+	for i := 0; i < quantity; i++ {
+		s.purchases = append(s.purchases, purchaseRecord{
+			id: uuid.New(), userID: userID, merch: merch, price: merch.price, createdAt: time.Now(),
+		})
+	}
+	return merch.price, nil
 }
 
-func (s *memoryStorage) Close() error { return nil }
+func (s *memoryStorage) GiftItem(ctx context.Context, fromUserID uuid.UUID, toUsername, item, message string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-type memoryRedis struct {
-	mu    sync.Mutex
-	store map[string]string
-}
+	toUserID := uuid.Nil
+	for id, user := range s.users {
+		if user.username == toUsername {
+			toUserID = id
+		}
+	}
+	if toUserID == uuid.Nil {
+		return repository.ErrRecipientNotFound
+	}
+	if toUserID == fromUserID {
+		return repository.ErrSelfGift
+	}
 
-func newMemoryRedis() *memoryRedis {
-	return &memoryRedis{store: make(map[string]string)}
+	if _, err := s.purchase(fromUserID, toUserID, item, 1); err != nil {
+		return err
+	}
+	gift := &s.purchases[len(s.purchases)-1]
+	gift.gift, gift.giftedBy, gift.message = true, fromUserID, message
+	return nil
 }
 
-func (r *memoryRedis) StoreRefreshToken(userID, refreshToken string) error {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-	r.store[refreshToken] = userID
-	return nil
+func (s *memoryStorage) GetGifts(ctx context.Context, userID uuid.UUID) (dto.GiftHistoryDTO, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	gifts := dto.GiftHistoryDTO{Received: []dto.ReceivedGiftDTO{}, Sent: []dto.SentGiftDTO{}}
+	for i := len(s.purchases) - 1; i >= 0; i-- {
+		p := s.purchases[i]
+		switch {
+		case !p.gift:
+		case p.userID == userID:
+			gifts.Received = append(gifts.Received, dto.ReceivedGiftDTO{
+				FromUser: s.username(p.giftedBy), Merch: p.merch.name, Message: p.message, CreatedAt: p.createdAt,
+			})
+		case p.giftedBy == userID:
+			gifts.Sent = append(gifts.Sent, dto.SentGiftDTO{
+				ToUser: s.username(p.userID), Merch: p.merch.name, Message: p.message, CreatedAt: p.createdAt,
+			})
+		}
+	}
+	return gifts, nil
 }
 
-type testServer struct {
-	server  *httptest.Server
-	storage *memoryStorage
-	jwtGen  *jwt.Generator
+func (s *memoryStorage) boughtCount(userID uuid.UUID, merch *merchRecord) int {
+	count := 0
+	for _, purchase := range s.purchases {
+		if purchase.userID == userID && purchase.merch == merch && purchase.returnedAt == nil {
+			count++
+		}
+	}
+	return count
 }
 
-func newTestServer(t *testing.T) *testServer {
-	t.Helper()
+func (s *memoryStorage) GetMerchItems(ctx context.Context) ([]dto.MerchDTO, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	gin.SetMode(gin.TestMode)
-	storage := newMemoryStorage()
-	redisStorage := newMemoryRedis()
-	jwtGen := jwt.NewGenerator("secret", time.Minute, 24*time.Hour)
+	items := make([]dto.MerchDTO, 0, len(s.merch))
+	for _, merch := range s.merch {
+		if merch.archivedAt == nil {
+			items = append(items, merch.dto())
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Name < items[j].Name })
+	return items, nil
+}
 
-	log := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelDebug}))
+func (s *memoryStorage) CreateMerchItem(ctx context.Context, adminID uuid.UUID, item dto.MerchDTO) (dto.MerchDTO, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	authService := services.NewAuthService(log, storage, redisStorage, jwtGen)
-	userService := services.NewUserService(log, storage)
+	if _, ok := s.merch[item.Name]; ok {
+		return dto.MerchDTO{}, repository.ErrItemAlreadyExists
+	}
 
-	authHandler := handlers.NewAuthHandler(log, authService)
-	userHandler := handlers.NewUserHandler(log, userService)
+	merch := &merchRecord{
+		name:        item.Name,
+		price:       item.Price,
+		description: item.Description,
+		available:   item.Available,
+		limits:      item.MerchLimits,
+	}
+	s.merch[item.Name] = merch
+	s.priceChanges = append(s.priceChanges, priceChangeRecord{merch: merch, newPrice: item.Price, changedBy: adminID})
+	return merch.dto(), nil
+}
 
-	authMiddleware := middlewares.NewAuthMiddleware(jwtGen)
-	router := routes.InitRoutes(authHandler, userHandler, authMiddleware)
+func (s *memoryStorage) UpdateMerchItem(ctx context.Context, adminID uuid.UUID, name string,
+	update dto.UpdateMerchRequest) (dto.MerchDTO, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	return &testServer{server: httptest.NewServer(router), storage: storage, jwtGen: jwtGen}
-}
+	merch, ok := s.merch[name]
+	if !ok || merch.archivedAt != nil {
+		return dto.MerchDTO{}, repository.ErrItemNotFound
+	}
 
-func (s *testServer) close() {
-	s.server.Close()
-}
+	if update.Name != nil && *update.Name != name {
+		if _, taken := s.merch[*update.Name]; taken {
+			return dto.MerchDTO{}, repository.ErrItemAlreadyExists
+		}
+		delete(s.merch, name)
+		merch.name = *update.Name
+		s.merch[merch.name] = merch
+	}
+	if update.Price != nil && *update.Price != merch.price {
+		s.priceChanges = append(s.priceChanges, priceChangeRecord{
+			merch: merch, oldPrice: merch.price, newPrice: *update.Price, changedBy: adminID,
+		})
+		merch.price = *update.Price
+	}
+	if update.Description != nil {
+		merch.description = *update.Description
+	}
+	if update.Available != nil {
+		merch.available = *update.Available
+	}
 
-func (s *testServer) url(path string) string {
-	return s.server.URL + path
+	return merch.dto(), nil
 }
 
-func (s *testServer) login(t *testing.T, username, password string) (token string, refresh string) {
-	t.Helper()
-	body := map[string]string{"username": username, "password": password}
-	payload, err := json.Marshal(body)
-	require.NoError(t, err)
-
-	resp, err := http.Post(s.url("/api/auth"), "application/json", bytes.NewReader(payload))
-	require.NoError(t, err)
-	defer resp.Body.Close()
+func (s *memoryStorage) SetMerchItemArchived(ctx context.Context, name string, archived bool) (dto.MerchDTO, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	require.Equal(t, http.StatusOK, resp.StatusCode)
+	merch, ok := s.merch[name]
+	if !ok {
+		return dto.MerchDTO{}, repository.ErrItemNotFound
+	}
 
-	var parsed struct {
-		Token        string `json:"token"`
-		RefreshToken string `json:"refreshToken"`
+	switch {
+	case !archived:
+		merch.archivedAt = nil
+	case merch.archivedAt == nil:
+		now := time.Now()
+		merch.archivedAt = &now
 	}
-	err = json.NewDecoder(resp.Body).Decode(&parsed)
-	require.NoError(t, err)
 
-	return parsed.Token, parsed.RefreshToken
+	return merch.dto(), nil
 }
 
-func (s *testServer) getInfo(t *testing.T, token string) dto.InfoResponse {
-	t.Helper()
-	req, err := http.NewRequest(http.MethodGet, s.url("/api/info"), nil)
-	require.NoError(t, err)
-	req.Header.Set("Authorization", "Bearer "+token)
+func (s *memoryStorage) SetMerchItemLimits(ctx context.Context, name string, limits dto.MerchLimits) (dto.MerchDTO, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	resp, err := http.DefaultClient.Do(req)
-	require.NoError(t, err)
-	defer resp.Body.Close()
+	merch, ok := s.merch[name]
+	if !ok || merch.archivedAt != nil {
+		return dto.MerchDTO{}, repository.ErrItemNotFound
+	}
 
-	require.Equal(t, http.StatusOK, resp.StatusCode)
+	merch.limits = limits
+	return merch.dto(), nil
+}
 
-	var info dto.InfoResponse
-	err = json.NewDecoder(resp.Body).Decode(&info)
-	require.NoError(t, err)
-	return info
+func (m *merchRecord) onSale(now time.Time) bool {
+	startsAt, endsAt := m.limits.SaleStartsAt, m.limits.SaleEndsAt
+	return (startsAt == nil || !now.Before(*startsAt)) && (endsAt == nil || now.Before(*endsAt))
 }
 
-func (s *testServer) transferCoins(t *testing.T, token string, fromID, toID uuid.UUID, amount int) *http.Response {
-	t.Helper()
-	request := dto.SendCoinRequest{FromUserID: fromID, ToUserID: toID, Amount: amount}
-	payload, err := json.Marshal(request)
-	require.NoError(t, err)
+func (m *merchRecord) dto() dto.MerchDTO {
+	inStock := m.limits.Stock == nil || *m.limits.Stock > 0
+	return dto.MerchDTO{
+		Name:        m.name,
+		Price:       m.price,
+		Available:   m.available && inStock && m.onSale(time.Now()),
+		Description: m.description,
+		ArchivedAt:  m.archivedAt,
+		MerchLimits: m.limits,
+	}
+}
 
-	req, err := http.NewRequest(http.MethodPost, s.url("/api/sendCoins"), bytes.NewReader(payload))
-	require.NoError(t, err)
-	req.Header.Set("Authorization", "Bearer "+token)
-	req.Header.Set("Content-Type", "application/json")
+func (s *memoryStorage) AdjustBalance(ctx context.Context, adminID uuid.UUID, username string, amount int,
+	reason string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	resp, err := http.DefaultClient.Do(req)
-	require.NoError(t, err)
-	return resp
+	for _, user := range s.users {
+		if user.username != username {
+			continue
+		}
+		if user.coins+amount < 0 {
+			return 0, repository.ErrNegativeBalance
+		}
+		user.coins += amount
+		return user.coins, nil
+	}
+
+	return 0, repository.ErrUserNotFound
 }
 
-func (s *testServer) buy(t *testing.T, token string, item string) *http.Response {
-	t.Helper()
-	req, err := http.NewRequest(http.MethodGet, s.url("/api/buy/"+item), nil)
-	require.NoError(t, err)
-	req.Header.Set("Authorization", "Bearer "+token)
+// RecomputeBalance - в памяти отдельного журнала нет, coins и есть баланс, поэтому расхождений не бывает.
+func (s *memoryStorage) RecomputeBalance(ctx context.Context, username string) (dto.BalanceRecomputeDTO, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	resp, err := http.DefaultClient.Do(req)
-	require.NoError(t, err)
-	return resp
+	for _, user := range s.users {
+		if user.username == username {
+			return dto.BalanceRecomputeDTO{Username: username, Cached: user.coins, Ledger: user.coins}, nil
+		}
+	}
+
+	return dto.BalanceRecomputeDTO{}, repository.ErrUserNotFound
 }
 
-func (s *testServer) userIDByUsername(username string) uuid.UUID {
-	s.storage.mu.Lock()
-	defer s.storage.mu.Unlock()
-	for id, user := range s.storage.users {
+func (s *memoryStorage) setRole(username, role string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, user := range s.users {
 		if user.username == username {
-			return id
+			user.role = role
 		}
 	}
-	return uuid.Nil
 }
 
-func TestAuthAndInfoFlow(t *testing.T) {
-	srv := newTestServer(t)
-	defer srv.close()
+func (s *memoryStorage) ReserveIdempotencyKey(ctx context.Context, userID, key, requestHash string,
+	ttl time.Duration) (models.IdempotencyKey, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	token, refresh := srv.login(t, "alice", "password123")
+	id := userID + "/" + key
+	if record, ok := s.idempotency[id]; ok && time.Since(record.CreatedAt) < ttl {
+		return record, false, nil
+	}
+
+	record := models.IdempotencyKey{UserID: userID, Key: key, RequestHash: requestHash, CreatedAt: time.Now()}
+	s.idempotency[id] = record
+	return record, true, nil
+}
+
+func (s *memoryStorage) CompleteIdempotencyKey(ctx context.Context, userID, key string, statusCode int,
+	body []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record := s.idempotency[userID+"/"+key]
+	record.StatusCode = statusCode
+	record.ResponseBody = body
+	s.idempotency[userID+"/"+key] = record
+	return nil
+}
+
+func (s *memoryStorage) ReleaseIdempotencyKey(ctx context.Context, userID, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.idempotency[userID+"/"+key].StatusCode == 0 {
+		delete(s.idempotency, userID+"/"+key)
+	}
+	return nil
+}
+
+func (s *memoryStorage) Close() error { return nil }
+
+type memoryRedis struct {
+	mu           sync.Mutex
+	store        map[string]string
+	families     map[string][]string
+	userFamilies map[string][]string
+	denylist     map[string]bool
+	revokedAt    map[string]int64
+	resets       map[string]string
+	failures     map[string]int64
+	locks        map[string]time.Time
+}
+
+func newMemoryRedis() *memoryRedis {
+	return &memoryRedis{
+		store:        make(map[string]string),
+		families:     make(map[string][]string),
+		userFamilies: make(map[string][]string),
+		denylist:     make(map[string]bool),
+		revokedAt:    make(map[string]int64),
+		resets:       make(map[string]string),
+		failures:     make(map[string]int64),
+		locks:        make(map[string]time.Time),
+	}
+}
+
+func (r *memoryRedis) StoreRefreshToken(userID, family, refreshToken string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.store[refreshToken] = userID
+	r.families[family] = append(r.families[family], refreshToken)
+	r.userFamilies[userID] = append(r.userFamilies[userID], family)
+	return nil
+}
+
+func (r *memoryRedis) ConsumeRefreshToken(refreshToken string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	userID, ok := r.store[refreshToken]
+	if !ok {
+		return "", repository.ErrTokenNotFound
+	}
+	delete(r.store, refreshToken)
+	return userID, nil
+}
+
+func (r *memoryRedis) RevokeRefreshFamily(family string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, token := range r.families[family] {
+		delete(r.store, token)
+	}
+	delete(r.families, family)
+	return nil
+}
+
+func (r *memoryRedis) DenyAccessToken(jti string, ttl time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.denylist[jti] = true
+	return nil
+}
+
+func (r *memoryRedis) RevokeUserTokens(userID string) error {
+	r.mu.Lock()
+	families := r.userFamilies[userID]
+	delete(r.userFamilies, userID)
+	r.revokedAt[userID] = time.Now().Unix()
+	r.mu.Unlock()
+
+	for _, family := range families {
+		_ = r.RevokeRefreshFamily(family)
+	}
+	return nil
+}
+
+func (r *memoryRedis) IsAccessTokenRevoked(userID, jti string, issuedAt time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.denylist[jti] {
+		return true, nil
+	}
+	revokedAt, ok := r.revokedAt[userID]
+	return ok && issuedAt.Unix() < revokedAt, nil
+}
+
+func (r *memoryRedis) StorePasswordResetToken(tokenHash, userID string, ttl time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.resets[tokenHash] = userID
+	return nil
+}
+
+func (r *memoryRedis) ConsumePasswordResetToken(tokenHash string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	userID, ok := r.resets[tokenHash]
+	if !ok {
+		return "", repository.ErrTokenNotFound
+	}
+	delete(r.resets, tokenHash)
+	return userID, nil
+}
+
+func (r *memoryRedis) LoginLockTTL(key string) (time.Duration, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return max(time.Until(r.locks[key]), 0), nil
+}
+
+func (r *memoryRedis) RegisterLoginFailure(key string, window time.Duration) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failures[key]++
+	return r.failures[key], nil
+}
+
+func (r *memoryRedis) LockLogin(key string, ttl time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.locks[key] = time.Now().Add(ttl)
+	return nil
+}
+
+func (r *memoryRedis) ResetLoginFailures(key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.failures, key)
+	return nil
+}
+
+// memoryMailer запоминает последнее письмо каждому адресату.
+type memoryMailer struct {
+	mu    sync.Mutex
+	inbox map[string]string
+}
+
+func (m *memoryMailer) Send(ctx context.Context, to, subject, body string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inbox[to] = body
+	return nil
+}
+
+func (m *memoryMailer) last(to string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.inbox[to]
+}
+
+type testServer struct {
+	server  *httptest.Server
+	storage *memoryStorage
+	jwtGen  *jwt.Generator
+	mailer  *memoryMailer
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+	storage := newMemoryStorage()
+	redisStorage := newMemoryRedis()
+	jwtGen := jwt.NewGenerator("secret", time.Minute, 24*time.Hour)
+	passwordHasher, err := hasher.NewBcrypt(bcrypt.MinCost)
+	require.NoError(t, err)
+
+	log := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	authService := services.NewAuthService(log, storage, redisStorage, jwtGen, passwordHasher, true)
+	merchService := services.NewMerchService(log, storage, time.Minute)
+	userService := services.NewUserService(log, storage, merchService)
+	adminService := services.NewAdminService(log, storage)
+	mailer := &memoryMailer{inbox: make(map[string]string)}
+	accountService := services.NewAccountService(log, storage, redisStorage, mailer, passwordHasher, time.Minute)
+
+	loginLimiter := services.NewLoginLimiter(log, redisStorage, authService, services.LoginLimiterConfig{
+		MaxAttempts:      3,
+		MaxAttemptsPerIP: 100,
+		BaseLockout:      time.Minute,
+		MaxLockout:       time.Hour,
+		Window:           time.Hour,
+	})
+
+	authHandler := handlers.NewAuthHandler(log, authService, loginLimiter)
+	userHandler := handlers.NewUserHandler(log, userService)
+	accountHandler := handlers.NewAccountHandler(log, accountService)
+	adminHandler := handlers.NewAdminHandler(log, adminService)
+	merchHandler := handlers.NewMerchHandler(log, merchService)
+	catalogHandler := handlers.NewCatalogHandler(log, services.NewCatalogService(log, storage, merchService))
+	purchaseHandler := handlers.NewPurchaseHandler(log, services.NewPurchaseService(log, storage, time.Hour, merchService))
+
+	authMiddleware := middlewares.NewAuthMiddleware(jwtGen, redisStorage)
+	idempotencyMiddleware := middlewares.NewIdempotencyMiddleware(log, storage, time.Hour)
+	keysHandler := handlers.NewKeysHandler(jwtGen)
+	router := routes.InitRoutes(authHandler, userHandler, accountHandler, adminHandler, merchHandler, catalogHandler,
+		purchaseHandler, keysHandler, authMiddleware, idempotencyMiddleware, config.APIConfig{AllowGetBuy: true})
+
+	return &testServer{server: httptest.NewServer(router), storage: storage, jwtGen: jwtGen, mailer: mailer}
+}
+
+func (s *testServer) close() {
+	s.server.Close()
+}
+
+func (s *testServer) url(path string) string {
+	return s.server.URL + path
+}
+
+func (s *testServer) login(t *testing.T, username, password string) (token string, refresh string) {
+	t.Helper()
+	body := map[string]string{"username": username, "password": password}
+	payload, err := json.Marshal(body)
+	require.NoError(t, err)
+
+	resp, err := http.Post(s.url("/api/auth"), "application/json", bytes.NewReader(payload))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var parsed struct {
+		Token        string `json:"token"`
+		RefreshToken string `json:"refreshToken"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&parsed)
+	require.NoError(t, err)
+
+	return parsed.Token, parsed.RefreshToken
+}
+
+func (s *testServer) refresh(t *testing.T, refreshToken string) *http.Response {
+	t.Helper()
+	payload, err := json.Marshal(dto.RefreshRequest{RefreshToken: refreshToken})
+	require.NoError(t, err)
+
+	resp, err := http.Post(s.url("/api/auth/refresh"), "application/json", bytes.NewReader(payload))
+	require.NoError(t, err)
+	return resp
+}
+
+func (s *testServer) postWithToken(t *testing.T, path, token string, body any) *http.Response {
+	t.Helper()
+	return s.requestWithToken(t, http.MethodPost, path, token, body)
+}
+
+func (s *testServer) requestWithToken(t *testing.T, method, path, token string, body any) *http.Response {
+	t.Helper()
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		require.NoError(t, err)
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequest(method, s.url(path), reader)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	return resp
+}
+
+func (s *testServer) getWithToken(t *testing.T, path, token string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, s.url(path), nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	return resp
+}
+
+func (s *testServer) getTransactions(t *testing.T, token, query string) dto.TransactionsResponse {
+	t.Helper()
+	resp := s.getWithToken(t, "/api/transactions?"+query, token)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var page dto.TransactionsResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&page))
+	return page
+}
+
+func (s *testServer) infoStatus(t *testing.T, token string) int {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, s.url("/api/info"), nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func (s *testServer) getInfo(t *testing.T, token string) dto.InfoResponse {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, s.url("/api/info"), nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var info dto.InfoResponse
+	err = json.NewDecoder(resp.Body).Decode(&info)
+	require.NoError(t, err)
+	return info
+}
+
+func (s *testServer) transferCoins(t *testing.T, token string, toUser string, amount int) *http.Response {
+	t.Helper()
+	return s.transferCoinsWithKey(t, token, "", toUser, amount)
+}
+
+func (s *testServer) transferCoinsWithKey(t *testing.T, token, idempotencyKey, toUser string,
+	amount int) *http.Response {
+	t.Helper()
+	request := dto.SendCoinRequest{ToUser: toUser, Amount: amount}
+	payload, err := json.Marshal(request)
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodPost, s.url("/api/sendCoin"), bytes.NewReader(payload))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	if idempotencyKey != "" {
+		req.Header.Set(middlewares.IdempotencyKeyHeader, idempotencyKey)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	return resp
+}
+
+func (s *testServer) buy(t *testing.T, token string, item string) *http.Response {
+	t.Helper()
+	return s.buyWithKey(t, token, "", item)
+}
+
+func (s *testServer) buyWithKey(t *testing.T, token, idempotencyKey, item string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, s.url("/api/buy/"+item), nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+token)
+	if idempotencyKey != "" {
+		req.Header.Set(middlewares.IdempotencyKeyHeader, idempotencyKey)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	return resp
+}
+
+func (s *testServer) userIDByUsername(username string) uuid.UUID {
+	s.storage.mu.Lock()
+	defer s.storage.mu.Unlock()
+	for id, user := range s.storage.users {
+		if user.username == username {
+			return id
+		}
+	}
+	return uuid.Nil
+}
+
+func errorCode(t *testing.T, resp *http.Response) string {
+	t.Helper()
+	defer resp.Body.Close()
+
+	var body dto.ErrorResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	return body.Code
+}
+
+func TestAuthAndInfoFlow(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.close()
+
+	token, refresh := srv.login(t, "alice", "password123")
 	require.NotEmpty(t, token)
 	require.NotEmpty(t, refresh)
 
-	info := srv.getInfo(t, token)
-	require.Equal(t, 100000, info.Coins)
-	require.Len(t, info.Inventory, 0)
-	require.Len(t, info.CoinHistory.Received, 0)
-	require.Len(t, info.CoinHistory.Sent, 0)
+	info := srv.getInfo(t, token)
+	require.Equal(t, 100000, info.Coins)
+	require.Len(t, info.Inventory, 0)
+	require.Len(t, info.CoinHistory.Received, 0)
+	require.Len(t, info.CoinHistory.Sent, 0)
+}
+
+func TestTransferAndPurchaseFlow(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.close()
+
+	aliceToken, _ := srv.login(t, "alice", "password123")
+	bobToken, _ := srv.login(t, "bob", "password456")
+
+	resp := srv.transferCoins(t, aliceToken, "bob", 5000)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+
+	resp = srv.buy(t, bobToken, "cup")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+
+	aliceInfo := srv.getInfo(t, aliceToken)
+	require.Equal(t, 95000, aliceInfo.Coins)
+	require.Len(t, aliceInfo.CoinHistory.Sent, 1)
+	require.Equal(t, 5000, aliceInfo.CoinHistory.Sent[0].Amount)
+
+	bobInfo := srv.getInfo(t, bobToken)
+	require.Equal(t, 103000, bobInfo.Coins)
+	require.Len(t, bobInfo.Inventory, 1)
+	require.Equal(t, "cup", bobInfo.Inventory[0].Merch)
+	require.Len(t, bobInfo.CoinHistory.Received, 1)
+	require.Equal(t, 5000, bobInfo.CoinHistory.Received[0].Amount)
+}
+
+func TestRefreshRotatesTokensAndDetectsReuse(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.close()
+
+	_, refresh := srv.login(t, "alice", "password123")
+
+	resp := srv.refresh(t, refresh)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var parsed struct {
+		Token        string `json:"token"`
+		RefreshToken string `json:"refreshToken"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&parsed))
+	resp.Body.Close()
+	require.NotEmpty(t, parsed.Token)
+	require.NotEqual(t, refresh, parsed.RefreshToken)
+
+	info := srv.getInfo(t, parsed.Token)
+	require.Equal(t, 100000, info.Coins)
+
+	// повторное использование старого токена отзывает всю цепочку, включая только что выданный
+	resp = srv.refresh(t, refresh)
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	resp.Body.Close()
+
+	resp = srv.refresh(t, parsed.RefreshToken)
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	resp.Body.Close()
+}
+
+func TestLogoutRevokesAccessAndRefreshTokens(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.close()
+
+	token, refresh := srv.login(t, "alice", "password123")
+
+	resp := srv.postWithToken(t, "/api/auth/logout", token, dto.LogoutRequest{RefreshToken: refresh})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+
+	require.Equal(t, http.StatusUnauthorized, srv.infoStatus(t, token))
+
+	resp = srv.refresh(t, refresh)
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	resp.Body.Close()
+}
+
+func TestLogoutAllRevokesEverySession(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.close()
+
+	laptopToken, laptopRefresh := srv.login(t, "alice", "password123")
+	phoneToken, _ := srv.login(t, "alice", "password123")
+
+	// iat имеет секундную точность: дожидаемся следующей секунды, чтобы токены были строго старше отзыва
+	time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)))
+
+	resp := srv.postWithToken(t, "/api/auth/logout-all", phoneToken, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+
+	require.Equal(t, http.StatusUnauthorized, srv.infoStatus(t, phoneToken))
+
+	resp = srv.refresh(t, laptopRefresh)
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	resp.Body.Close()
+
+	require.Equal(t, http.StatusUnauthorized, srv.infoStatus(t, laptopToken))
+
+	newToken, _ := srv.login(t, "alice", "password123")
+	require.Equal(t, http.StatusOK, srv.infoStatus(t, newToken))
+}
+
+func TestRefreshTokenIsRejectedOnProtectedRoutes(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.close()
+
+	token, refresh := srv.login(t, "alice", "password123")
+
+	require.Equal(t, http.StatusOK, srv.infoStatus(t, token))
+	require.Equal(t, http.StatusUnauthorized, srv.infoStatus(t, refresh))
+}
+
+func TestJWKSEndpointIsPublic(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.close()
+
+	resp, err := http.Get(srv.url("/.well-known/jwks.json"))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var jwks jwt.JWKS
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&jwks))
+	require.NotNil(t, jwks.Keys)
+}
+
+func TestRegisterCreatesUserWithEmail(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.close()
+
+	register := func(body dto.RegisterRequest) *http.Response {
+		payload, err := json.Marshal(body)
+		require.NoError(t, err)
+		resp, err := http.Post(srv.url("/api/register"), "application/json", bytes.NewReader(payload))
+		require.NoError(t, err)
+		return resp
+	}
+
+	resp := register(dto.RegisterRequest{Username: "alice", Email: "alice@example.com", Password: "password123"})
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	resp.Body.Close()
+
+	token, _ := srv.login(t, "alice", "password123")
+	require.Equal(t, 100000, srv.getInfo(t, token).Coins)
+
+	emailToken, _ := srv.login(t, "Alice@Example.com", "password123")
+	require.Equal(t, 100000, srv.getInfo(t, emailToken).Coins)
+
+	resp = register(dto.RegisterRequest{Username: "alice2", Email: "alice@example.com", Password: "password123"})
+	require.Equal(t, http.StatusConflict, resp.StatusCode)
+	resp.Body.Close()
+
+	resp = register(dto.RegisterRequest{Username: "bob", Email: "bob-at-example", Password: "password123"})
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp.Body.Close()
+}
+
+func TestChangePasswordRevokesSessions(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.close()
+
+	token, refresh := srv.login(t, "alice", "password123")
+
+	// отзыв действует на токены, выпущенные в предыдущие секунды
+	time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)))
+
+	resp := srv.postWithToken(t, "/api/account/password", token,
+		dto.ChangePasswordRequest{OldPassword: "wrongPassword", NewPassword: "newPassword123"})
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp.Body.Close()
+
+	resp = srv.postWithToken(t, "/api/account/password", token,
+		dto.ChangePasswordRequest{OldPassword: "password123", NewPassword: ""})
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	var body map[string]string
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	resp.Body.Close()
+	require.Equal(t, middlewares.ErrEmptyField.Error(), body["message"])
+
+	resp = srv.postWithToken(t, "/api/account/password", token,
+		dto.ChangePasswordRequest{OldPassword: "password123", NewPassword: "newPassword123"})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+
+	require.Equal(t, http.StatusUnauthorized, srv.infoStatus(t, token))
+	resp = srv.refresh(t, refresh)
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	resp.Body.Close()
+
+	newToken, _ := srv.login(t, "alice", "newPassword123")
+	require.Equal(t, http.StatusOK, srv.infoStatus(t, newToken))
+}
+
+func TestPasswordResetFlow(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.close()
+
+	post := func(path string, body any) *http.Response {
+		payload, err := json.Marshal(body)
+		require.NoError(t, err)
+		resp, err := http.Post(srv.url(path), "application/json", bytes.NewReader(payload))
+		require.NoError(t, err)
+		return resp
+	}
+
+	resp := post("/api/register", dto.RegisterRequest{Username: "alice", Email: "alice@example.com",
+		Password: "password123"})
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	resp.Body.Close()
+
+	resp = post("/api/auth/password/forgot", dto.PasswordResetRequest{Email: "ghost@example.com"})
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	resp.Body.Close()
+
+	resp = post("/api/auth/password/forgot", dto.PasswordResetRequest{Email: "alice@example.com"})
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	resp.Body.Close()
+
+	mail := srv.mailer.last("alice@example.com")
+	require.NotEmpty(t, mail)
+	resetToken := strings.Fields(strings.SplitN(mail, ": ", 2)[1])[0]
+
+	resp = post("/api/auth/password/reset", dto.PasswordResetConfirmRequest{Token: resetToken,
+		NewPassword: "newPassword123"})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+
+	resp = post("/api/auth/password/reset", dto.PasswordResetConfirmRequest{Token: resetToken,
+		NewPassword: "anotherPassword"})
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp.Body.Close()
+
+	resp = post("/api/auth", dto.AuthRequest{Username: "alice", Password: "password123"})
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	resp.Body.Close()
+
+	token, _ := srv.login(t, "alice", "newPassword123")
+	require.Equal(t, http.StatusOK, srv.infoStatus(t, token))
+}
+
+func TestLoginLockoutAfterRepeatedFailures(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.close()
+
+	srv.login(t, "alice", "password123")
+
+	attempt := func(password string) *http.Response {
+		payload, err := json.Marshal(dto.AuthRequest{Username: "alice", Password: password})
+		require.NoError(t, err)
+		resp, err := http.Post(srv.url("/api/auth"), "application/json", bytes.NewReader(payload))
+		require.NoError(t, err)
+		return resp
+	}
+
+	for i := 0; i < 3; i++ {
+		resp := attempt("wrongPassword")
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		resp.Body.Close()
+	}
+
+	resp := attempt("password123")
+	require.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	require.Equal(t, "60", resp.Header.Get("Retry-After"))
+	resp.Body.Close()
+}
+
+func TestLoginLockoutSharedBetweenUsernameAndEmail(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.close()
+
+	payload, err := json.Marshal(dto.RegisterRequest{Username: "alice", Email: "alice@example.com",
+		Password: "password123"})
+	require.NoError(t, err)
+	resp, err := http.Post(srv.url("/api/register"), "application/json", bytes.NewReader(payload))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	resp.Body.Close()
+
+	attempt := func(login, password string) *http.Response {
+		payload, err := json.Marshal(dto.AuthRequest{Username: login, Password: password})
+		require.NoError(t, err)
+		resp, err := http.Post(srv.url("/api/auth"), "application/json", bytes.NewReader(payload))
+		require.NoError(t, err)
+		return resp
+	}
+
+	for _, login := range []string{"alice", "alice@example.com", "alice"} {
+		resp := attempt(login, "wrongPassword")
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		resp.Body.Close()
+	}
+
+	for _, login := range []string{"alice", "alice@example.com"} {
+		resp := attempt(login, "password123")
+		require.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+		resp.Body.Close()
+	}
+}
+
+func TestAdminRoutesRequireAdminRole(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.close()
+
+	userToken, _ := srv.login(t, "alice", "password123")
+	bobToken, _ := srv.login(t, "bob", "password123")
+
+	adjust := func(token string, username string, amount int) *http.Response {
+		return srv.postWithToken(t, "/api/admin/users/"+username+"/balance", token,
+			dto.AdjustBalanceRequest{Amount: amount, Reason: "support ticket"})
+	}
+
+	resp := adjust(userToken, "bob", 500)
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	resp.Body.Close()
+
+	srv.storage.setRole("alice", models.RoleAdmin)
+	adminToken, _ := srv.login(t, "alice", "password123")
+
+	resp = adjust(adminToken, "bob", 500)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var adjusted dto.AdjustBalanceResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&adjusted))
+	resp.Body.Close()
+	require.Equal(t, 100500, adjusted.Coins)
+	require.Equal(t, 100500, srv.getInfo(t, bobToken).Coins)
+
+	resp = adjust(adminToken, "bob", -200000)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp.Body.Close()
+
+	resp = adjust(adminToken, "ghost", 100)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp.Body.Close()
+
+	resp = srv.postWithToken(t, "/api/admin/users/bob/balance/recompute", bobToken, nil)
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	resp.Body.Close()
+
+	resp = srv.postWithToken(t, "/api/admin/users/bob/balance/recompute", adminToken, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var recomputed dto.BalanceRecomputeDTO
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&recomputed))
+	resp.Body.Close()
+	require.Equal(t, dto.BalanceRecomputeDTO{Username: "bob", Cached: 100500, Ledger: 100500}, recomputed)
+}
+
+func TestTransferRejectsUnknownRecipientAndSelfTransfer(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.close()
+
+	token, _ := srv.login(t, "alice", "password123")
+
+	resp := srv.transferCoins(t, token, "ghost", 100)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	require.Equal(t, "recipient_not_found", errorCode(t, resp))
+
+	resp = srv.transferCoins(t, token, "alice", 100)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	require.Equal(t, "self_transfer", errorCode(t, resp))
+
+	require.Equal(t, 100000, srv.getInfo(t, token).Coins)
+}
+
+func TestPurchaseErrorsHaveStableCodes(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.close()
+
+	token, _ := srv.login(t, "alice", "password123")
+
+	resp := srv.buy(t, token, "spaceship")
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	require.Equal(t, "item_not_found", errorCode(t, resp))
+
+	resp = srv.transferCoins(t, token, "alice2", 1)
+	require.Equal(t, "recipient_not_found", errorCode(t, resp))
+
+	srv.login(t, "bob", "password123")
+	resp = srv.transferCoins(t, token, "bob", 100001)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	require.Equal(t, "insufficient_funds", errorCode(t, resp))
 }
 
-func TestTransferAndPurchaseFlow(t *testing.T) {
+func TestIdempotencyKeyPreventsDuplicateTransfersAndPurchases(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.close()
+
+	aliceToken, _ := srv.login(t, "alice", "password123")
+	bobToken, _ := srv.login(t, "bob", "password456")
+
+	for i := 0; i < 2; i++ {
+		resp := srv.transferCoinsWithKey(t, aliceToken, "transfer-1", "bob", 5000)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, i == 1, resp.Header.Get(middlewares.IdempotentReplayedHeader) == "true")
+		resp.Body.Close()
+	}
+
+	var purchases []string
+	for i := 0; i < 2; i++ {
+		resp := srv.buyWithKey(t, aliceToken, "buy-1", "cup")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		resp.Body.Close()
+		purchases = append(purchases, string(body))
+	}
+	require.Equal(t, purchases[0], purchases[1], "повтор должен вернуть исходный ответ")
+
+	aliceInfo := srv.getInfo(t, aliceToken)
+	require.Equal(t, 100000-5000-2000, aliceInfo.Coins)
+	require.Len(t, aliceInfo.Inventory, 1)
+	require.Equal(t, 1, aliceInfo.Inventory[0].Amount)
+	require.Equal(t, 105000, srv.getInfo(t, bobToken).Coins)
+
+	// ключи разных пользователей не пересекаются
+	resp := srv.transferCoinsWithKey(t, bobToken, "transfer-1", "alice", 5000)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Empty(t, resp.Header.Get(middlewares.IdempotentReplayedHeader))
+	resp.Body.Close()
+}
+
+func TestIdempotencyKeyReuseWithDifferentPayloadIsRejected(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.close()
+
+	token, _ := srv.login(t, "alice", "password123")
+	srv.login(t, "bob", "password456")
+
+	resp := srv.transferCoinsWithKey(t, token, "key-1", "bob", 100)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+
+	resp = srv.transferCoinsWithKey(t, token, "key-1", "bob", 200)
+	require.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+	require.Equal(t, "idempotency_key_reused", errorCode(t, resp))
+
+	resp = srv.buyWithKey(t, token, "key-1", "cup")
+	require.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+	require.Equal(t, "idempotency_key_reused", errorCode(t, resp))
+
+	require.Equal(t, 100000-100, srv.getInfo(t, token).Coins)
+}
+
+func TestIdempotencyKeyIsReleasedWhenRequestFails(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.close()
+
+	token, _ := srv.login(t, "alice", "password123")
+
+	resp := srv.transferCoinsWithKey(t, token, "retry-me", "bob", 100)
+	require.Equal(t, "recipient_not_found", errorCode(t, resp))
+
+	srv.login(t, "bob", "password456")
+
+	resp = srv.transferCoinsWithKey(t, token, "retry-me", "bob", 100)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Empty(t, resp.Header.Get(middlewares.IdempotentReplayedHeader))
+	resp.Body.Close()
+
+	resp = srv.transferCoinsWithKey(t, token, "bad key", "bob", 100)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	require.Equal(t, "invalid_idempotency_key", errorCode(t, resp))
+}
+
+func TestTransactionsHistoryIsPaginated(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.close()
+
+	aliceToken, _ := srv.login(t, "alice", "password123")
+	bobToken, _ := srv.login(t, "bob", "password456")
+	srv.login(t, "carol", "password789")
+
+	for _, amount := range []int{100, 200, 300} {
+		resp := srv.transferCoins(t, aliceToken, "bob", amount)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		resp.Body.Close()
+	}
+	resp := srv.transferCoins(t, bobToken, "alice", 50)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+	resp = srv.transferCoins(t, aliceToken, "carol", 10)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+
+	var amounts []int
+	query := "limit=2"
+	for pages := 0; ; pages++ {
+		require.Less(t, pages, 3)
+		page := srv.getTransactions(t, aliceToken, query)
+		for _, tr := range page.Transactions {
+			amounts = append(amounts, tr.Amount)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		query = "limit=2&cursor=" + page.NextCursor
+	}
+	require.Equal(t, []int{10, 50, 300, 200, 100}, amounts, "история отдается от новых переводов к старым")
+
+	received := srv.getTransactions(t, aliceToken, "direction=received")
+	require.Len(t, received.Transactions, 1)
+	require.Equal(t, "bob", received.Transactions[0].Counterparty)
+	require.Equal(t, dto.DirectionReceived, received.Transactions[0].Direction)
+	require.Empty(t, received.NextCursor)
+
+	sent := srv.getTransactions(t, aliceToken, "direction=sent&from=2000-01-01")
+	require.Len(t, sent.Transactions, 4)
+	require.Equal(t, "carol", sent.Transactions[0].Counterparty)
+
+	future := srv.getTransactions(t, aliceToken, "from="+time.Now().Add(time.Hour).UTC().Format(time.RFC3339))
+	require.Empty(t, future.Transactions)
+
+	resp = srv.getWithToken(t, "/api/transactions?direction=both", aliceToken)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	require.Equal(t, "invalid_direction", errorCode(t, resp))
+}
+
+func TestInfoCoinHistoryUsesUsernames(t *testing.T) {
 	srv := newTestServer(t)
 	defer srv.close()
 
 	aliceToken, _ := srv.login(t, "alice", "password123")
 	bobToken, _ := srv.login(t, "bob", "password456")
 
-	aliceID := srv.userIDByUsername("alice")
-	bobID := srv.userIDByUsername("bob")
+	resp := srv.transferCoins(t, aliceToken, "bob", 700)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+
+	resp = srv.getWithToken(t, "/api/info", bobToken)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var raw struct {
+		CoinHistory struct {
+			Received []map[string]any `json:"received"`
+			Sent     []map[string]any `json:"sent"`
+		} `json:"coin_history"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&raw))
+
+	require.Equal(t, []map[string]any{{"fromUser": "alice", "amount": float64(700)}}, raw.CoinHistory.Received)
+	require.NotNil(t, raw.CoinHistory.Sent, "пустая история отдается как [], а не null")
+	require.Empty(t, raw.CoinHistory.Sent)
+}
+
+func TestMerchCatalogIsPublic(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.close()
+
+	resp, err := http.Get(srv.url("/api/merch"))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var catalog dto.MerchListResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&catalog))
+	resp.Body.Close()
+	require.Len(t, catalog.Items, 10)
+	require.Equal(t, "book", catalog.Items[0].Name)
+
+	resp, err = http.Get(srv.url("/api/merch/pink-hoody"))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var item dto.MerchDTO
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&item))
+	resp.Body.Close()
+	require.Equal(t, dto.MerchDTO{Name: "pink-hoody", Price: 50000, Available: true}, item)
+
+	resp, err = http.Get(srv.url("/api/merch/spaceship"))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	require.Equal(t, "item_not_found", errorCode(t, resp))
+}
+
+func TestAdminCatalogManagement(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.close()
+
+	srv.login(t, "admin", "password123")
+	srv.storage.setRole("admin", models.RoleAdmin)
+	adminToken, _ := srv.login(t, "admin", "password123")
+	userToken, _ := srv.login(t, "alice", "password123")
+
+	catalogItem := func(name string) (int, dto.MerchDTO) {
+		resp, err := http.Get(srv.url("/api/merch/" + name))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		var item dto.MerchDTO
+		if resp.StatusCode == http.StatusOK {
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&item))
+		}
+		return resp.StatusCode, item
+	}
+
+	resp := srv.postWithToken(t, "/api/admin/merch", userToken, dto.CreateMerchRequest{Name: "sticker", Price: 500})
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	resp.Body.Close()
+
+	resp = srv.postWithToken(t, "/api/admin/merch", adminToken,
+		dto.CreateMerchRequest{Name: "sticker", Price: 500, Description: "Набор стикеров"})
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	resp.Body.Close()
+
+	status, item := catalogItem("sticker")
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, dto.MerchDTO{Name: "sticker", Price: 500, Available: true, Description: "Набор стикеров"}, item)
+
+	resp = srv.postWithToken(t, "/api/admin/merch", adminToken, dto.CreateMerchRequest{Name: "sticker", Price: 100})
+	require.Equal(t, http.StatusConflict, resp.StatusCode)
+	require.Equal(t, "item_already_exists", errorCode(t, resp))
+
+	resp = srv.buy(t, userToken, "sticker")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+
+	newName, newPrice := "sticker-pack", 700
+	resp = srv.requestWithToken(t, http.MethodPatch, "/api/admin/merch/sticker", adminToken,
+		dto.UpdateMerchRequest{Name: &newName, Price: &newPrice})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+
+	status, _ = catalogItem("sticker")
+	require.Equal(t, http.StatusNotFound, status, "кеш каталога сбрасывается после изменения")
+	status, item = catalogItem("sticker-pack")
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, 700, item.Price)
+	require.Len(t, srv.storage.priceChanges, 2)
+	require.Equal(t, 500, srv.storage.priceChanges[1].oldPrice)
+
+	inventory := srv.getInfo(t, userToken).Inventory
+	require.Equal(t, []dto.PurchaseDTO{{Merch: "sticker-pack", Amount: 1}}, inventory)
+
+	resp = srv.postWithToken(t, "/api/admin/merch/sticker-pack/archive", adminToken, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+
+	status, _ = catalogItem("sticker-pack")
+	require.Equal(t, http.StatusNotFound, status)
+	resp = srv.buy(t, userToken, "sticker-pack")
+	require.Equal(t, "item_not_found", errorCode(t, resp))
+	require.Len(t, srv.getInfo(t, userToken).Inventory, 1, "архивация не удаляет историю покупок")
+
+	resp = srv.requestWithToken(t, http.MethodPatch, "/api/admin/merch/sticker-pack", adminToken,
+		dto.UpdateMerchRequest{Price: &newPrice})
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp.Body.Close()
+
+	resp = srv.postWithToken(t, "/api/admin/merch/sticker-pack/restore", adminToken, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+
+	status, _ = catalogItem("sticker-pack")
+	require.Equal(t, http.StatusOK, status)
+}
+
+func TestMerchStockAndPurchaseLimits(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.close()
+
+	srv.login(t, "admin", "password123")
+	srv.storage.setRole("admin", models.RoleAdmin)
+	adminToken, _ := srv.login(t, "admin", "password123")
+	aliceToken, _ := srv.login(t, "alice", "password123")
+	bobToken, _ := srv.login(t, "bob", "password123")
+
+	setLimits := func(limits dto.MerchLimits) dto.MerchDTO {
+		resp := srv.requestWithToken(t, http.MethodPut, "/api/admin/merch/cup/limits", adminToken, limits)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var item dto.MerchDTO
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&item))
+		return item
+	}
+
+	stock, perUser := 2, 1
+	item := setLimits(dto.MerchLimits{Stock: &stock, PerUserLimit: &perUser})
+	require.True(t, item.Available)
 
-	resp := srv.transferCoins(t, aliceToken, aliceID, bobID, 5000)
+	resp := srv.buy(t, aliceToken, "cup")
 	require.Equal(t, http.StatusOK, resp.StatusCode)
 	resp.Body.Close()
 
+	resp = srv.buy(t, aliceToken, "cup")
+	require.Equal(t, "purchase_limit_reached", errorCode(t, resp))
+
 	resp = srv.buy(t, bobToken, "cup")
 	require.Equal(t, http.StatusOK, resp.StatusCode)
 	resp.Body.Close()
 
-	aliceInfo := srv.getInfo(t, aliceToken)
-	require.Equal(t, 95000, aliceInfo.Coins)
-	require.Len(t, aliceInfo.CoinHistory.Sent, 1)
-	require.Equal(t, 5000, aliceInfo.CoinHistory.Sent[0].TotalAmount)
+	srv.login(t, "carol", "password123")
+	carolToken, _ := srv.login(t, "carol", "password123")
+	resp = srv.buy(t, carolToken, "cup")
+	require.Equal(t, "out_of_stock", errorCode(t, resp))
+	require.Equal(t, 100000-2000, srv.getInfo(t, aliceToken).Coins, "отказ по лимиту не списывает монеты")
 
-	bobInfo := srv.getInfo(t, bobToken)
-	require.Equal(t, 103000, bobInfo.Coins)
-	require.Len(t, bobInfo.Inventory, 1)
-	require.Equal(t, "cup", bobInfo.Inventory[0].Merch)
-	require.Len(t, bobInfo.CoinHistory.Received, 1)
-	require.Equal(t, 5000, bobInfo.CoinHistory.Received[0].TotalAmount)
+	startsAt := time.Now().Add(time.Hour)
+	item = setLimits(dto.MerchLimits{SaleStartsAt: &startsAt})
+	require.False(t, item.Available)
+	require.Nil(t, item.Stock)
+
+	resp = srv.buy(t, carolToken, "cup")
+	require.Equal(t, "sale_closed", errorCode(t, resp))
+
+	resp = srv.requestWithToken(t, http.MethodPut, "/api/admin/merch/cup/limits", adminToken,
+		dto.MerchLimits{SaleStartsAt: &startsAt, SaleEndsAt: &startsAt})
+	require.Equal(t, "invalid_sale_window", errorCode(t, resp))
+}
+
+func TestCheckoutIsAllOrNothing(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.close()
+
+	token, _ := srv.login(t, "alice", "password123")
+
+	resp := srv.postWithToken(t, "/api/checkout", token, dto.CheckoutRequest{Items: []dto.CheckoutItem{
+		{Item: "pen", Quantity: 2}, {Item: "spaceship", Quantity: 1},
+	}})
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	require.Equal(t, "item_not_found", errorCode(t, resp))
+
+	info := srv.getInfo(t, token)
+	require.Equal(t, 100000, info.Coins)
+	require.Empty(t, info.Inventory)
+
+	resp = srv.postWithToken(t, "/api/checkout", token, dto.CheckoutRequest{Items: []dto.CheckoutItem{
+		{Item: "pen", Quantity: 2}, {Item: "cup", Quantity: 1},
+	}})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var checkout dto.CheckoutResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&checkout))
+	resp.Body.Close()
+	require.Equal(t, 4000, checkout.Total)
+
+	info = srv.getInfo(t, token)
+	require.Equal(t, 96000, info.Coins)
+	require.ElementsMatch(t, []dto.PurchaseDTO{{Merch: "pen", Amount: 2}, {Merch: "cup", Amount: 1}}, info.Inventory)
+
+	resp = srv.postWithToken(t, "/api/checkout", token, dto.CheckoutRequest{})
+	require.Equal(t, "empty_cart", errorCode(t, resp))
+}
+
+func TestGetBuyIsDeprecatedAlias(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.close()
+
+	token, _ := srv.login(t, "alice", "password123")
+	before := deprecatedCount("GET /api/buy/:item")
+
+	resp := srv.requestWithToken(t, http.MethodGet, "/api/buy/pen", token, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "true", resp.Header.Get("Deprecation"))
+	resp.Body.Close()
+
+	resp = srv.buy(t, token, "pen")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Empty(t, resp.Header.Get("Deprecation"))
+	resp.Body.Close()
+
+	require.Equal(t, before+1, deprecatedCount("GET /api/buy/:item"))
+	require.Equal(t, []dto.PurchaseDTO{{Merch: "pen", Amount: 2}}, srv.getInfo(t, token).Inventory)
+}
+
+func deprecatedCount(route string) int64 {
+	if counter, ok := middlewares.DeprecatedRequests.Get(route).(*expvar.Int); ok {
+		return counter.Value()
+	}
+	return 0
+}
+
+func TestReturnPurchaseRefundsAndRestoresStock(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.close()
+
+	srv.login(t, "admin", "password123")
+	srv.storage.setRole("admin", models.RoleAdmin)
+	adminToken, _ := srv.login(t, "admin", "password123")
+	token, _ := srv.login(t, "alice", "password123")
+
+	stock := 1
+	resp := srv.requestWithToken(t, http.MethodPut, "/api/admin/merch/cup/limits", adminToken,
+		dto.MerchLimits{Stock: &stock})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+
+	resp = srv.buy(t, token, "cup")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+
+	resp = srv.getWithToken(t, "/api/purchases", token)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var list dto.PurchasesResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&list))
+	resp.Body.Close()
+	require.Len(t, list.Purchases, 1)
+	require.Equal(t, 2000, list.Purchases[0].Price)
+
+	// цена после покупки не влияет на сумму возврата
+	newPrice := 9000
+	resp = srv.requestWithToken(t, http.MethodPatch, "/api/admin/merch/cup", adminToken,
+		dto.UpdateMerchRequest{Price: &newPrice})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+
+	returnPath := "/api/purchases/" + list.Purchases[0].ID.String() + "/return"
+	resp = srv.postWithToken(t, returnPath, token, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var returned dto.PurchaseRecordDTO
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&returned))
+	resp.Body.Close()
+	require.NotNil(t, returned.ReturnedAt)
+
+	info := srv.getInfo(t, token)
+	require.Equal(t, 100000, info.Coins)
+	require.Equal(t, []dto.PurchaseDTO{{Merch: "cup", Amount: 0, Returned: 1}}, info.Inventory)
+
+	resp = srv.postWithToken(t, returnPath, token, nil)
+	require.Equal(t, http.StatusConflict, resp.StatusCode)
+	require.Equal(t, "purchase_already_returned", errorCode(t, resp))
+
+	bobToken, _ := srv.login(t, "bob", "password123")
+	resp = srv.postWithToken(t, returnPath, bobToken, nil)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	require.Equal(t, "purchase_not_found", errorCode(t, resp))
+
+	resp = srv.buy(t, bobToken, "cup")
+	require.Equal(t, http.StatusOK, resp.StatusCode, "возврат вернул товар на склад")
+	resp.Body.Close()
+}
+
+func TestGiftAppearsInBothUsersInfo(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.close()
+
+	aliceToken, _ := srv.login(t, "alice", "password123")
+	bobToken, _ := srv.login(t, "bob", "password123")
+
+	resp := srv.postWithToken(t, "/api/gift", aliceToken,
+		dto.GiftRequest{ToUser: "bob", Item: "t-shirt", Message: "С днем рождения!"})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+
+	alice := srv.getInfo(t, aliceToken)
+	require.Equal(t, 100000-8000, alice.Coins)
+	require.Empty(t, alice.Inventory)
+	require.Len(t, alice.Gifts.Sent, 1)
+	require.Equal(t, "bob", alice.Gifts.Sent[0].ToUser)
+	require.Empty(t, alice.Gifts.Received)
+
+	bob := srv.getInfo(t, bobToken)
+	require.Equal(t, 100000, bob.Coins)
+	require.Equal(t, []dto.PurchaseDTO{{Merch: "t-shirt", Amount: 1}}, bob.Inventory)
+	require.Len(t, bob.Gifts.Received, 1)
+	require.Equal(t, "alice", bob.Gifts.Received[0].FromUser)
+	require.Equal(t, "С днем рождения!", bob.Gifts.Received[0].Message)
+
+	resp = srv.getWithToken(t, "/api/purchases", bobToken)
+	var list dto.PurchasesResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&list))
+	resp.Body.Close()
+	resp = srv.postWithToken(t, "/api/purchases/"+list.Purchases[0].ID.String()+"/return", bobToken, nil)
+	require.Equal(t, "gift_not_returnable", errorCode(t, resp))
+
+	resp = srv.postWithToken(t, "/api/gift", aliceToken, dto.GiftRequest{ToUser: "alice", Item: "pen"})
+	require.Equal(t, "self_gift", errorCode(t, resp))
+
+	resp = srv.postWithToken(t, "/api/gift", aliceToken, dto.GiftRequest{ToUser: "nobody", Item: "pen"})
+	require.Equal(t, "recipient_not_found", errorCode(t, resp))
+}
+
+func TestConcurrentGiftsRespectRecipientLimit(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.close()
+
+	srv.login(t, "admin", "password123")
+	srv.storage.setRole("admin", models.RoleAdmin)
+	adminToken, _ := srv.login(t, "admin", "password123")
+	bobToken, _ := srv.login(t, "bob", "password123")
+
+	limit := 1
+	resp := srv.requestWithToken(t, http.MethodPut, "/api/admin/merch/socks/limits", adminToken,
+		dto.MerchLimits{PerUserLimit: &limit})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+
+	const senders = 5
+	tokens := make([]string, senders)
+	for i := range tokens {
+		tokens[i], _ = srv.login(t, fmt.Sprintf("sender%d", i), "password123")
+	}
+
+	payload, err := json.Marshal(dto.GiftRequest{ToUser: "bob", Item: "socks"})
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	statuses := make(chan int, senders)
+	for _, token := range tokens {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, err := http.NewRequest(http.MethodPost, srv.url("/api/gift"), bytes.NewReader(payload))
+			if err != nil {
+				statuses <- 0
+				return
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+			req.Header.Set("Content-Type", "application/json")
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				statuses <- 0
+				return
+			}
+			resp.Body.Close()
+			statuses <- resp.StatusCode
+		}()
+	}
+	wg.Wait()
+	close(statuses)
+
+	counts := map[int]int{}
+	for status := range statuses {
+		counts[status]++
+	}
+	require.Equal(t, map[int]int{http.StatusOK: 1, http.StatusBadRequest: senders - 1}, counts)
+	require.Equal(t, []dto.PurchaseDTO{{Merch: "socks", Amount: 1}}, srv.getInfo(t, bobToken).Inventory)
+}
+
+func TestCatalogShowsStockChangesImmediately(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.close()
+
+	srv.login(t, "admin", "password123")
+	srv.storage.setRole("admin", models.RoleAdmin)
+	adminToken, _ := srv.login(t, "admin", "password123")
+	token, _ := srv.login(t, "alice", "password123")
+
+	catalogItem := func() dto.MerchDTO {
+		resp, err := http.Get(srv.url("/api/merch/cup"))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var item dto.MerchDTO
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&item))
+		return item
+	}
+
+	stock := 1
+	resp := srv.requestWithToken(t, http.MethodPut, "/api/admin/merch/cup/limits", adminToken,
+		dto.MerchLimits{Stock: &stock})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+	require.True(t, catalogItem().Available)
+
+	// кеш каталога живет минуту, но покупка сбрасывает его сразу
+	resp = srv.buy(t, token, "cup")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+
+	item := catalogItem()
+	require.Equal(t, 0, *item.Stock)
+	require.False(t, item.Available)
+
+	resp = srv.getWithToken(t, "/api/purchases", token)
+	var list dto.PurchasesResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&list))
+	resp.Body.Close()
+
+	resp = srv.postWithToken(t, "/api/purchases/"+list.Purchases[0].ID.String()+"/return", token, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+
+	item = catalogItem()
+	require.Equal(t, 1, *item.Stock)
+	require.True(t, item.Available)
 }
@@ -0,0 +1,67 @@
+package integration
+
+import (
+	"avito-shop/internal/domain/dto"
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestOpposingCheckoutsPostgres одновременно покупает корзины [A, B] и [B, A] товаров с остатком. Без блокировки
+// строк товаров в одном порядке такие корзины списывают остатки навстречу друг другу и падают с 40P01.
+func TestOpposingCheckoutsPostgres(t *testing.T) {
+	storage := newPostgresStorage(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	_, ids := createPostgresUsers(ctx, t, storage, "checkout-buyer", 2)
+
+	const initialStock = 1000
+	items := make([]string, 2)
+	for i := range items {
+		stock := initialStock
+		items[i] = fmt.Sprintf("checkout-item%d-%s", i, ids[0].String()[:8])
+		_, err := storage.CreateMerchItem(ctx, ids[0], dto.MerchDTO{Name: items[i], Price: 1, Available: true,
+			MerchLimits: dto.MerchLimits{Stock: &stock}})
+		require.NoError(t, err)
+	}
+
+	carts := [][]dto.CheckoutItem{
+		{{Item: items[0], Quantity: 1}, {Item: items[1], Quantity: 1}},
+		{{Item: items[1], Quantity: 1}, {Item: items[0], Quantity: 1}},
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*stressWorkers*stressTransfers)
+	for i, cart := range carts {
+		for w := 0; w < stressWorkers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < stressTransfers; j++ {
+					if _, err := storage.Checkout(ctx, ids[i], cart); err != nil {
+						errs <- err
+					}
+				}
+			}()
+		}
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	sold := 2 * stressWorkers * stressTransfers
+	for _, item := range items {
+		stock := merchStock(ctx, t, storage, item)
+		require.NotNil(t, stock)
+		require.Equal(t, initialStock-sold, *stock, item)
+	}
+}
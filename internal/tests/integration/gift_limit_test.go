@@ -0,0 +1,55 @@
+package integration
+
+import (
+	"avito-shop/internal/domain/dto"
+	"avito-shop/internal/repository"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestConcurrentGiftsRespectLimitPostgres дарит одному получателю товар с лимитом 1 от нескольких отправителей
+// одновременно.
+func TestConcurrentGiftsRespectLimitPostgres(t *testing.T) {
+	storage := newPostgresStorage(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	const senders = 8
+	_, ids := createPostgresUsers(ctx, t, storage, "gift-sender", senders)
+	recipients, _ := createPostgresUsers(ctx, t, storage, "gift-recipient", 1)
+	recipient := recipients[0]
+
+	limit := 1
+	item := "gift-limit-" + ids[0].String()[:8]
+	_, err := storage.CreateMerchItem(ctx, ids[0], dto.MerchDTO{Name: item, Price: 100, Available: true,
+		MerchLimits: dto.MerchLimits{PerUserLimit: &limit}})
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, senders)
+	for _, id := range ids {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- storage.GiftItem(ctx, id, recipient, item, "")
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	delivered := 0
+	for err := range errs {
+		if err == nil {
+			delivered++
+			continue
+		}
+		require.True(t, errors.Is(err, repository.ErrPurchaseLimitReached), err.Error())
+	}
+	require.Equal(t, 1, delivered)
+}
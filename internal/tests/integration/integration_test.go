@@ -2,12 +2,15 @@ package integration
 
 import (
 	"avito-shop/internal/domain/dto"
+	"avito-shop/internal/domain/models"
+	"avito-shop/internal/lib/hasher"
 	"avito-shop/internal/lib/jwt"
 	"avito-shop/internal/repository"
 	"avito-shop/internal/services"
 	"context"
-	"fmt"
 	"log/slog"
+	"sort"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -27,21 +30,33 @@ type memoryStorage struct {
 
 type userRecord struct {
 	username string
+	email    string
 	password []byte
 	coins    int
+	role     string
 }
 
 type purchaseRecord struct {
-	userID uuid.UUID
-	merch  string
+	userID   uuid.UUID
+	merch    string
+	gift     bool
+	giftedBy uuid.UUID
+	message  string
 }
 
 type transactionRecord struct {
-	from   uuid.UUID
-	to     uuid.UUID
-	amount int
+	id        uuid.UUID
+	from      uuid.UUID
+	to        uuid.UUID
+	amount    int
+	createdAt time.Time
 }
 
+// catalogCache - в этом хранилище нет каталога товаров, поэтому сбрасывать нечего.
+type catalogCache struct{}
+
+func (catalogCache) InvalidateCache() {}
+
 func newMemoryStorage() *memoryStorage {
 	return &memoryStorage{
 		users:       make(map[uuid.UUID]*userRecord),
@@ -72,7 +87,7 @@ func (s *memoryStorage) reset() {
 	s.transactions = nil
 }
 
-func (s *memoryStorage) SaveUser(ctx context.Context, username string, passHash []byte) error {
+func (s *memoryStorage) SaveUser(ctx context.Context, username, email string, passHash []byte) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -80,11 +95,15 @@ func (s *memoryStorage) SaveUser(ctx context.Context, username string, passHash
 		if user.username == username {
 			return repository.ErrUserAlreadyExists
 		}
+		if email != "" && user.email == email {
+			return repository.ErrEmailAlreadyExists
+		}
 	}
 
 	id := uuid.New()
 	s.users[id] = &userRecord{
 		username: username,
+		email:    email,
 		password: passHash,
 		coins:    100000,
 	}
@@ -99,6 +118,9 @@ func (s *memoryStorage) LoginUser(ctx context.Context, inputType, input string)
 		if inputType == "username" && user.username == input {
 			return id.String(), user.password, nil
 		}
+		if inputType == "email" && user.email != "" && strings.EqualFold(user.email, input) {
+			return id.String(), user.password, nil
+		}
 	}
 
 	return "", nil, repository.ErrUserNotFound
@@ -116,6 +138,36 @@ func (s *memoryStorage) CheckUsernameIsAvailable(ctx context.Context, username s
 	return true, nil
 }
 
+func (s *memoryStorage) UpdatePassword(ctx context.Context, userID uuid.UUID, passHash []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[userID]
+	if !ok {
+		return repository.ErrUserNotFound
+	}
+	user.password = passHash
+	return nil
+}
+
+func (s *memoryStorage) GetUserRole(ctx context.Context, userID string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return "", repository.ErrUserNotFound
+	}
+	user, ok := s.users[id]
+	if !ok {
+		return "", repository.ErrUserNotFound
+	}
+	if user.role == "" {
+		return models.RoleUser, nil
+	}
+	return user.role, nil
+}
+
 func (s *memoryStorage) GetUserById(ctx context.Context, userID uuid.UUID) (dto.UserDTO, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -163,19 +215,73 @@ func (s *memoryStorage) GetCoinTransactions(ctx context.Context, userID uuid.UUI
 		}
 	}
 
-	toDTO := func(m map[uuid.UUID]int) []dto.CoinTransactionDTO {
-		var res []dto.CoinTransactionDTO
-		for id, total := range m {
-			user := s.users[id]
-			res = append(res, dto.CoinTransactionDTO{Username: user.username, TotalAmount: total})
+	history := dto.TransactionDTO{Received: []dto.ReceivedCoinsDTO{}, Sent: []dto.SentCoinsDTO{}}
+	for id, total := range received {
+		history.Received = append(history.Received, dto.ReceivedCoinsDTO{FromUser: s.username(id), Amount: total})
+	}
+	for id, total := range sent {
+		history.Sent = append(history.Sent, dto.SentCoinsDTO{ToUser: s.username(id), Amount: total})
+	}
+
+	return history, nil
+}
+
+func (s *memoryStorage) username(id uuid.UUID) string {
+	if user, ok := s.users[id]; ok {
+		return user.username
+	}
+	return dto.DeletedUsername
+}
+
+func (s *memoryStorage) GetTransfers(ctx context.Context, userID uuid.UUID,
+	filter dto.TransferFilter) ([]dto.TransferDTO, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var transfers []dto.TransferDTO
+	for _, tx := range s.transactions {
+		transfer := dto.TransferDTO{ID: tx.id, Amount: tx.amount, CreatedAt: tx.createdAt}
+		switch {
+		case tx.from == userID && filter.Direction != dto.DirectionReceived:
+			transfer.Direction, transfer.Counterparty = dto.DirectionSent, s.username(tx.to)
+		case tx.to == userID && filter.Direction != dto.DirectionSent:
+			transfer.Direction, transfer.Counterparty = dto.DirectionReceived, s.username(tx.from)
+		default:
+			continue
+		}
+
+		if !filter.From.IsZero() && tx.createdAt.Before(filter.From) {
+			continue
 		}
-		return res
+		if !filter.To.IsZero() && !tx.createdAt.Before(filter.To) {
+			continue
+		}
+		if !filter.BeforeCreatedAt.IsZero() && !transferBefore(transfer, filter.BeforeCreatedAt, filter.BeforeID) {
+			continue
+		}
+
+		transfers = append(transfers, transfer)
 	}
 
-	return dto.TransactionDTO{Received: toDTO(received), Sent: toDTO(sent)}, nil
+	sort.Slice(transfers, func(i, j int) bool {
+		return transferBefore(transfers[j], transfers[i].CreatedAt, transfers[i].ID)
+	})
+
+	if len(transfers) > filter.Limit {
+		transfers = transfers[:filter.Limit]
+	}
+	return transfers, nil
 }
 
-func (s *memoryStorage) TransferCoins(ctx context.Context, fromUserID, toUserID uuid.UUID, amount int) error {
+// transferBefore повторяет сравнение (created_at, id) < (createdAt, id) из Postgres
+func transferBefore(t dto.TransferDTO, createdAt time.Time, id uuid.UUID) bool {
+	if !t.CreatedAt.Equal(createdAt) {
+		return t.CreatedAt.Before(createdAt)
+	}
+	return t.ID.String() < id.String()
+}
+
+func (s *memoryStorage) TransferCoins(ctx context.Context, fromUserID uuid.UUID, toUsername string, amount int) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -183,18 +289,30 @@ func (s *memoryStorage) TransferCoins(ctx context.Context, fromUserID, toUserID
 	if !ok {
 		return repository.ErrUserNotFound
 	}
-	toUser, ok := s.users[toUserID]
-	if !ok {
-		return repository.ErrUserNotFound
+
+	var toUserID uuid.UUID
+	var toUser *userRecord
+	for id, user := range s.users {
+		if user.username == toUsername {
+			toUserID, toUser = id, user
+		}
+	}
+	if toUser == nil {
+		return repository.ErrRecipientNotFound
+	}
+	if toUserID == fromUserID {
+		return repository.ErrSelfTransfer
 	}
 
 	if fromUser.coins < amount {
-		return fmt.Errorf("insufficient funds")
+		return repository.ErrInsufficientFunds
 	}
 
 	fromUser.coins -= amount
 	toUser.coins += amount
-	s.transactions = append(s.transactions, transactionRecord{from: fromUserID, to: toUserID, amount: amount})
+	s.transactions = append(s.transactions, transactionRecord{
+		id: uuid.New(), from: fromUserID, to: toUserID, amount: amount, createdAt: time.Now(),
+	})
 	return nil
 }
 
@@ -208,10 +326,10 @@ func (s *memoryStorage) BuyItem(ctx context.Context, userID uuid.UUID, item stri
 	}
 	price, ok := s.merchPrices[item]
 	if !ok {
-		return fmt.Errorf("item not found")
+		return repository.ErrItemNotFound
 	}
 	if user.coins < price {
-		return fmt.Errorf("insufficient funds")
+		return repository.ErrInsufficientFunds
 	}
 
 	user.coins -= price
@@ -219,24 +337,181 @@ func (s *memoryStorage) BuyItem(ctx context.Context, userID uuid.UUID, item stri
 	return nil
 }
 
+func (s *memoryStorage) GiftItem(ctx context.Context, fromUserID uuid.UUID, toUsername, item, message string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fromUser, ok := s.users[fromUserID]
+	if !ok {
+		return repository.ErrUserNotFound
+	}
+	toUserID, ok := s.userID(toUsername)
+	if !ok {
+		return repository.ErrRecipientNotFound
+	}
+	if toUserID == fromUserID {
+		return repository.ErrSelfGift
+	}
+	price, ok := s.merchPrices[item]
+	if !ok {
+		return repository.ErrItemNotFound
+	}
+	if fromUser.coins < price {
+		return repository.ErrInsufficientFunds
+	}
+
+	fromUser.coins -= price
+	s.purchases = append(s.purchases, purchaseRecord{
+		userID: toUserID, merch: item, gift: true, giftedBy: fromUserID, message: message,
+	})
+	return nil
+}
+
+func (s *memoryStorage) GetGifts(ctx context.Context, userID uuid.UUID) (dto.GiftHistoryDTO, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	gifts := dto.GiftHistoryDTO{Received: []dto.ReceivedGiftDTO{}, Sent: []dto.SentGiftDTO{}}
+	for i := len(s.purchases) - 1; i >= 0; i-- {
+		purchase := s.purchases[i]
+		switch {
+		case !purchase.gift:
+		case purchase.userID == userID:
+			gifts.Received = append(gifts.Received, dto.ReceivedGiftDTO{
+				FromUser: s.username(purchase.giftedBy), Merch: purchase.merch, Message: purchase.message,
+			})
+		case purchase.giftedBy == userID:
+			gifts.Sent = append(gifts.Sent, dto.SentGiftDTO{
+				ToUser: s.username(purchase.userID), Merch: purchase.merch, Message: purchase.message,
+			})
+		}
+	}
+	return gifts, nil
+}
+
+func (s *memoryStorage) userID(username string) (uuid.UUID, bool) {
+	for id, user := range s.users {
+		if user.username == username {
+			return id, true
+		}
+	}
+	return uuid.Nil, false
+}
+
+func (s *memoryStorage) Checkout(ctx context.Context, userID uuid.UUID,
+	items []dto.CheckoutItem) ([]dto.CheckoutLineDTO, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[userID]
+	if !ok {
+		return nil, repository.ErrUserNotFound
+	}
+
+	lines := make([]dto.CheckoutLineDTO, 0, len(items))
+	total := 0
+	for _, item := range items {
+		price, ok := s.merchPrices[item.Item]
+		if !ok {
+			return nil, repository.ErrItemNotFound
+		}
+		total += price * item.Quantity
+		lines = append(lines, dto.CheckoutLineDTO{Item: item.Item, Quantity: item.Quantity, Price: price})
+	}
+	if user.coins < total {
+		return nil, repository.ErrInsufficientFunds
+	}
+
+	user.coins -= total
+	for _, item := range items {
+		for i := 0; i < item.Quantity; i++ {
+			s.purchases = append(s.purchases, purchaseRecord{userID: userID, merch: item.Item})
+		}
+	}
+	return lines, nil
+}
+
 func (s *memoryStorage) Close() error { return nil }
 
 type memoryRedis struct {
-	mu    sync.Mutex
-	store map[string]string
+	mu           sync.Mutex
+	store        map[string]string
+	families     map[string][]string
+	userFamilies map[string][]string
+	denylist     map[string]bool
+	revokedAt    map[string]int64
 }
 
 func newMemoryRedis() *memoryRedis {
-	return &memoryRedis{store: make(map[string]string)}
+	return &memoryRedis{
+		store:        make(map[string]string),
+		families:     make(map[string][]string),
+		userFamilies: make(map[string][]string),
+		denylist:     make(map[string]bool),
+		revokedAt:    make(map[string]int64),
+	}
 }
 
-func (r *memoryRedis) StoreRefreshToken(userID, refreshToken string) error {
+func (r *memoryRedis) StoreRefreshToken(userID, family, refreshToken string) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	r.store[refreshToken] = userID
+	r.families[family] = append(r.families[family], refreshToken)
+	r.userFamilies[userID] = append(r.userFamilies[userID], family)
+	return nil
+}
+
+func (r *memoryRedis) ConsumeRefreshToken(refreshToken string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	userID, ok := r.store[refreshToken]
+	if !ok {
+		return "", repository.ErrTokenNotFound
+	}
+	delete(r.store, refreshToken)
+	return userID, nil
+}
+
+func (r *memoryRedis) RevokeRefreshFamily(family string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, token := range r.families[family] {
+		delete(r.store, token)
+	}
+	delete(r.families, family)
 	return nil
 }
 
+func (r *memoryRedis) DenyAccessToken(jti string, ttl time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.denylist[jti] = true
+	return nil
+}
+
+func (r *memoryRedis) RevokeUserTokens(userID string) error {
+	r.mu.Lock()
+	families := r.userFamilies[userID]
+	delete(r.userFamilies, userID)
+	r.revokedAt[userID] = time.Now().Unix()
+	r.mu.Unlock()
+
+	for _, family := range families {
+		_ = r.RevokeRefreshFamily(family)
+	}
+	return nil
+}
+
+func (r *memoryRedis) IsAccessTokenRevoked(userID, jti string, issuedAt time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.denylist[jti] {
+		return true, nil
+	}
+	revokedAt, ok := r.revokedAt[userID]
+	return ok && issuedAt.Unix() < revokedAt, nil
+}
+
 type IntegrationTestSuite struct {
 	suite.Suite
 	ctx          context.Context
@@ -245,6 +520,7 @@ type IntegrationTestSuite struct {
 	authService  *services.AuthService
 	userService  *services.UserService
 	jwtGen       *jwt.Generator
+	hasher       services.PasswordHasher
 }
 
 func TestIntegrationSuite(t *testing.T) {
@@ -256,18 +532,21 @@ func (s *IntegrationTestSuite) SetupSuite() {
 	s.storage = newMemoryStorage()
 	s.redisStorage = newMemoryRedis()
 	s.jwtGen = jwt.NewGenerator("secret", time.Minute, 24*time.Hour)
+	passwordHasher, err := hasher.NewBcrypt(bcrypt.MinCost)
+	s.Require().NoError(err)
+	s.hasher = passwordHasher
 
 	log := slog.Default()
-	s.authService = services.NewAuthService(log, s.storage, s.redisStorage, s.jwtGen)
-	s.userService = services.NewUserService(log, s.storage)
+	s.authService = services.NewAuthService(log, s.storage, s.redisStorage, s.jwtGen, s.hasher, true)
+	s.userService = services.NewUserService(log, s.storage, catalogCache{})
 }
 
 func (s *IntegrationTestSuite) SetupTest() {
 	s.storage.reset()
 	s.redisStorage = newMemoryRedis()
 	log := slog.Default()
-	s.authService = services.NewAuthService(log, s.storage, s.redisStorage, s.jwtGen)
-	s.userService = services.NewUserService(log, s.storage)
+	s.authService = services.NewAuthService(log, s.storage, s.redisStorage, s.jwtGen, s.hasher, true)
+	s.userService = services.NewUserService(log, s.storage, catalogCache{})
 }
 
 func (s *IntegrationTestSuite) TestAuthLoginCreatesUserAndStoresRefreshToken() {
@@ -296,13 +575,13 @@ func (s *IntegrationTestSuite) TestAuthLoginWithWrongPassword() {
 
 func (s *IntegrationTestSuite) TestUserInfoAggregatesPurchasesAndTransactions() {
 	userID := s.createUser("user1", 100000, "pass")
-	toUser := s.createUser("receiver", 100000, "pass")
+	s.createUser("receiver", 100000, "pass")
 	fromUser := s.createUser("sender", 100000, "pass")
 
-	err := s.userService.TransferCoins(s.ctx, userID, toUser, 3000)
+	err := s.userService.TransferCoins(s.ctx, userID, "receiver", 3000)
 	s.Require().NoError(err)
 
-	err = s.userService.TransferCoins(s.ctx, fromUser, userID, 5000)
+	err = s.userService.TransferCoins(s.ctx, fromUser, "user1", 5000)
 	s.Require().NoError(err)
 
 	err = s.userService.BuyItem(s.ctx, userID, "cup")
@@ -317,17 +596,37 @@ func (s *IntegrationTestSuite) TestUserInfoAggregatesPurchasesAndTransactions()
 	s.Equal(1, info.Inventory[0].Amount)
 
 	s.Len(info.CoinHistory.Sent, 1)
-	s.Equal(3000, info.CoinHistory.Sent[0].TotalAmount)
+	s.Equal("receiver", info.CoinHistory.Sent[0].ToUser)
+	s.Equal(3000, info.CoinHistory.Sent[0].Amount)
 
 	s.Len(info.CoinHistory.Received, 1)
-	s.Equal(5000, info.CoinHistory.Received[0].TotalAmount)
+	s.Equal("sender", info.CoinHistory.Received[0].FromUser)
+	s.Equal(5000, info.CoinHistory.Received[0].Amount)
+}
+
+func (s *IntegrationTestSuite) TestCoinHistoryShowsPlaceholderForDeletedUsers() {
+	userID := s.createUser("survivor", 100000, "pass")
+	goneID := s.createUser("gone", 100000, "pass")
+
+	s.Require().NoError(s.userService.TransferCoins(s.ctx, userID, "gone", 100))
+	s.Require().NoError(s.userService.TransferCoins(s.ctx, goneID, "survivor", 40))
+
+	s.storage.mu.Lock()
+	delete(s.storage.users, goneID)
+	s.storage.mu.Unlock()
+
+	history, err := s.userService.GetCoinTransactions(s.ctx, userID)
+	s.Require().NoError(err)
+
+	s.Equal([]dto.SentCoinsDTO{{ToUser: dto.DeletedUsername, Amount: 100}}, history.Sent)
+	s.Equal([]dto.ReceivedCoinsDTO{{FromUser: dto.DeletedUsername, Amount: 40}}, history.Received)
 }
 
 func (s *IntegrationTestSuite) TestTransferCoinsUpdatesBalancesAndTransactions() {
 	fromUser := s.createUser("from", 10000, "pass")
 	toUser := s.createUser("to", 2000, "pass")
 
-	err := s.userService.TransferCoins(s.ctx, fromUser, toUser, 3500)
+	err := s.userService.TransferCoins(s.ctx, fromUser, "to", 3500)
 	s.Require().NoError(err)
 
 	fromInfo, err := s.userService.GetUserInfo(s.ctx, fromUser)
@@ -341,7 +640,21 @@ func (s *IntegrationTestSuite) TestTransferCoinsUpdatesBalancesAndTransactions()
 	history, err := s.userService.GetCoinTransactions(s.ctx, fromUser)
 	s.Require().NoError(err)
 	s.Len(history.Sent, 1)
-	s.Equal(3500, history.Sent[0].TotalAmount)
+	s.Equal(3500, history.Sent[0].Amount)
+}
+
+func (s *IntegrationTestSuite) TestTransferCoinsRejectsUnknownRecipientAndSelfTransfer() {
+	fromUser := s.createUser("from", 10000, "pass")
+
+	err := s.userService.TransferCoins(s.ctx, fromUser, "ghost", 100)
+	s.ErrorIs(err, repository.ErrRecipientNotFound)
+
+	err = s.userService.TransferCoins(s.ctx, fromUser, "from", 100)
+	s.ErrorIs(err, repository.ErrSelfTransfer)
+
+	info, err := s.userService.GetUserInfo(s.ctx, fromUser)
+	s.Require().NoError(err)
+	s.Equal(10000, info.Coins)
 }
 
 func (s *IntegrationTestSuite) TestBuyItemFailsWithInsufficientFunds() {
@@ -356,6 +669,60 @@ func (s *IntegrationTestSuite) TestBuyItemFailsWithInsufficientFunds() {
 	s.Empty(info.Inventory)
 }
 
+func (s *IntegrationTestSuite) TestCheckoutBuysAllItemsAtOnce() {
+	userID := s.createUser("shopper", 10000, "pass")
+
+	resp, err := s.userService.Checkout(s.ctx, userID, dto.CheckoutRequest{Items: []dto.CheckoutItem{
+		{Item: "pen", Quantity: 2}, {Item: "cup", Quantity: 1}, {Item: "pen", Quantity: 3},
+	}})
+	s.Require().NoError(err)
+	s.Equal(7000, resp.Total)
+	s.Equal([]dto.CheckoutLineDTO{{Item: "pen", Quantity: 5, Price: 1000}, {Item: "cup", Quantity: 1, Price: 2000}},
+		resp.Items)
+
+	info, err := s.userService.GetUserInfo(s.ctx, userID)
+	s.Require().NoError(err)
+	s.Equal(3000, info.Coins)
+	s.ElementsMatch([]dto.PurchaseDTO{{Merch: "pen", Amount: 5}, {Merch: "cup", Amount: 1}}, info.Inventory)
+}
+
+func (s *IntegrationTestSuite) TestCheckoutFailsAsAWhole() {
+	userID := s.createUser("shopper", 3000, "pass")
+
+	_, err := s.userService.Checkout(s.ctx, userID, dto.CheckoutRequest{Items: []dto.CheckoutItem{
+		{Item: "pen", Quantity: 1}, {Item: "cup", Quantity: 1}, {Item: "pen", Quantity: 1},
+	}})
+	s.Require().ErrorIs(err, repository.ErrInsufficientFunds)
+
+	info, err := s.userService.GetUserInfo(s.ctx, userID)
+	s.Require().NoError(err)
+	s.Equal(3000, info.Coins)
+	s.Empty(info.Inventory)
+}
+
+func (s *IntegrationTestSuite) TestGiftGoesToRecipientInventory() {
+	senderID := s.createUser("sender", 10000, "pass")
+	recipientID := s.createUser("recipient", 0, "pass")
+
+	err := s.userService.GiftItem(s.ctx, senderID, dto.GiftRequest{ToUser: "recipient", Item: "cup", Message: "спасибо"})
+	s.Require().NoError(err)
+
+	sender, err := s.userService.GetUserInfo(s.ctx, senderID)
+	s.Require().NoError(err)
+	s.Equal(8000, sender.Coins)
+	s.Empty(sender.Inventory)
+	s.Equal([]dto.SentGiftDTO{{ToUser: "recipient", Merch: "cup", Message: "спасибо"}}, sender.Gifts.Sent)
+
+	recipient, err := s.userService.GetUserInfo(s.ctx, recipientID)
+	s.Require().NoError(err)
+	s.Equal(0, recipient.Coins)
+	s.Equal([]dto.PurchaseDTO{{Merch: "cup", Amount: 1}}, recipient.Inventory)
+	s.Equal([]dto.ReceivedGiftDTO{{FromUser: "sender", Merch: "cup", Message: "спасибо"}}, recipient.Gifts.Received)
+
+	err = s.userService.GiftItem(s.ctx, senderID, dto.GiftRequest{ToUser: "sender", Item: "cup"})
+	s.ErrorIs(err, repository.ErrSelfGift)
+}
+
 func (s *IntegrationTestSuite) createUser(username string, coins int, password string) uuid.UUID {
 	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	s.Require().NoError(err)
@@ -0,0 +1,152 @@
+package integration
+
+import (
+	"avito-shop/internal/domain/dto"
+	"avito-shop/internal/repository/postgres"
+	"avito-shop/internal/services"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+)
+
+const startingGrant = 100000
+
+// TestLedgerMatchesBalancesPostgres проводит покупку, перевод и возврат на настоящей базе и проверяет, что журнал
+// проводок совпадает с users.coins, а сверка находит расхождение, внесенное в обход журнала, и исправляет его.
+func TestLedgerMatchesBalancesPostgres(t *testing.T) {
+	storage := newPostgresStorage(t)
+	pool := newPostgresPool(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	names, ids := createPostgresUsers(ctx, t, storage, "ledger-user", 2)
+	buyer, recipient := ids[0], ids[1]
+
+	item := "ledger-item-" + buyer.String()[:8]
+	stock := 5
+	_, err := storage.CreateMerchItem(ctx, buyer, dto.MerchDTO{Name: item, Price: 100, Available: true,
+		MerchLimits: dto.MerchLimits{Stock: &stock}})
+	require.NoError(t, err)
+
+	require.NoError(t, storage.BuyItem(ctx, buyer, item))
+	require.NoError(t, storage.BuyItem(ctx, buyer, item))
+	require.NoError(t, storage.TransferCoins(ctx, buyer, names[1], 250))
+
+	history, err := storage.GetPurchaseHistory(ctx, buyer)
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	_, err = storage.ReturnPurchase(ctx, buyer, history[0].ID, time.Hour)
+	require.NoError(t, err)
+
+	want := map[uuid.UUID]int{buyer: startingGrant - 100 - 250, recipient: startingGrant + 250}
+	for i, id := range ids {
+		user, err := storage.GetUserById(ctx, id)
+		require.NoError(t, err)
+		require.Equal(t, want[id], user.Coins, names[i])
+
+		balance, err := storage.RecomputeBalance(ctx, names[i])
+		require.NoError(t, err)
+		require.Equal(t, balance.Cached, balance.Ledger, names[i])
+	}
+
+	checks := balanceChecks(ctx, t, storage, ids...)
+	for _, id := range ids {
+		require.False(t, checks[id].HasDrift(), "unexpected drift for %s: %+v", id, checks[id])
+	}
+
+	// монеты, начисленные в обход журнала и истории операций
+	_, err = pool.Exec(ctx, "UPDATE users SET coins = coins + 7 WHERE id = $1", buyer)
+	require.NoError(t, err)
+
+	reconcile := services.NewReconcileService(slog.New(slog.NewTextHandler(io.Discard, nil)), storage, startingGrant)
+	report, err := reconcile.Reconcile(ctx, false, "")
+	require.NoError(t, err)
+
+	var drift *dto.BalanceDriftDTO
+	for i := range report.Drifts {
+		if report.Drifts[i].UserID == buyer {
+			drift = &report.Drifts[i]
+		}
+	}
+	require.NotNil(t, drift, "reconcile must report the introduced drift")
+	require.Equal(t, 7, drift.Drift)
+	require.Equal(t, 7, drift.LedgerDrift)
+	require.Equal(t, want[buyer], drift.Ledger)
+
+	_, err = storage.CorrectBalance(ctx, buyer, startingGrant, "integration test")
+	require.NoError(t, err)
+
+	corrected := balanceChecks(ctx, t, storage, buyer)[buyer]
+	require.False(t, corrected.HasDrift(), "drift remains after correction: %+v", corrected)
+	require.Equal(t, want[buyer], corrected.Coins)
+}
+
+// TestLedgerRejectsUnbalancedEntryPostgres проверяет отложенный триггер ledger_postings_balanced: проводка,
+// сумма строк которой не равна нулю, откатывается при коммите.
+func TestLedgerRejectsUnbalancedEntryPostgres(t *testing.T) {
+	storage := newPostgresStorage(t)
+	pool := newPostgresPool(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	_, ids := createPostgresUsers(ctx, t, storage, "ledger-unbalanced", 1)
+
+	tx, err := pool.Begin(ctx)
+	require.NoError(t, err)
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	var entryID uuid.UUID
+	require.NoError(t, tx.QueryRow(ctx,
+		"INSERT INTO ledger_entries (kind, description) VALUES ('grant', 'unbalanced') RETURNING id").Scan(&entryID))
+	_, err = tx.Exec(ctx, `INSERT INTO ledger_postings (entry_id, account_id, amount)
+		SELECT $1, id, 5 FROM ledger_accounts WHERE user_id = $2`, entryID, ids[0])
+	require.NoError(t, err, "the balance check is deferred until commit")
+
+	err = tx.Commit(ctx)
+
+	var pgErr *pgconn.PgError
+	require.True(t, errors.As(err, &pgErr), "commit must fail, got %v", err)
+	require.Equal(t, "23514", pgErr.Code)
+
+	// журнал в целом сбалансирован: выпущенные монеты равны сумме остальных счетов
+	var total int64
+	require.NoError(t, pool.QueryRow(ctx, "SELECT COALESCE(SUM(amount), 0) FROM ledger_postings").Scan(&total))
+	require.Zero(t, total)
+
+	var openings int
+	require.NoError(t, pool.QueryRow(ctx, "SELECT COUNT(*) FROM ledger_entries WHERE kind = 'opening'").Scan(&openings))
+	require.LessOrEqual(t, openings, 1)
+}
+
+// balanceChecks возвращает результат сверки для указанных пользователей.
+func balanceChecks(ctx context.Context, t *testing.T, storage *postgres.Storage,
+	ids ...uuid.UUID) map[uuid.UUID]dto.BalanceDriftDTO {
+	t.Helper()
+
+	checks, err := storage.GetBalanceChecks(ctx, startingGrant)
+	require.NoError(t, err)
+
+	wanted := make(map[uuid.UUID]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+
+	result := make(map[uuid.UUID]dto.BalanceDriftDTO, len(ids))
+	for _, check := range checks {
+		if wanted[check.UserID] {
+			result[check.UserID] = check
+		}
+	}
+	require.Len(t, result, len(ids))
+
+	return result
+}
@@ -0,0 +1,79 @@
+package integration
+
+import (
+	"avito-shop/internal/repository/postgres"
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+)
+
+// newPostgresStorage подключается к базе из TEST_POSTGRES_CONN с примененными миграциями, например к контейнеру
+// из docker-compose, и пропускает тест, если переменная не задана.
+func newPostgresStorage(t *testing.T) *postgres.Storage {
+	t.Helper()
+
+	conn := os.Getenv("TEST_POSTGRES_CONN")
+	if conn == "" {
+		t.Skip("TEST_POSTGRES_CONN is not set")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	storage, err := postgres.NewPostgres(ctx, conn)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = storage.Close() })
+
+	return storage
+}
+
+// newPostgresPool открывает отдельное подключение к той же базе, чтобы тест мог изменить данные в обход Storage.
+func newPostgresPool(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+
+	pool, err := pgxpool.New(context.Background(), os.Getenv("TEST_POSTGRES_CONN"))
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+
+	return pool
+}
+
+// createPostgresUsers создает n пользователей со случайным суффиксом в имени и возвращает их имена и id.
+func createPostgresUsers(ctx context.Context, t *testing.T, storage *postgres.Storage, prefix string,
+	n int) ([]string, []uuid.UUID) {
+	t.Helper()
+
+	suffix := uuid.NewString()[:8]
+	names, ids := make([]string, n), make([]uuid.UUID, n)
+	for i := range names {
+		names[i] = fmt.Sprintf("%s%d-%s", prefix, i, suffix)
+		require.NoError(t, storage.SaveUser(ctx, names[i], "", []byte("hash")))
+		id, _, err := storage.LoginUser(ctx, "username", names[i])
+		require.NoError(t, err)
+		ids[i] = uuid.MustParse(id)
+	}
+
+	return names, ids
+}
+
+// merchStock возвращает остаток товара из каталога, nil - остаток не ведется.
+func merchStock(ctx context.Context, t *testing.T, storage *postgres.Storage, name string) *int {
+	t.Helper()
+
+	items, err := storage.GetMerchItems(ctx)
+	require.NoError(t, err)
+	for _, item := range items {
+		if item.Name == name {
+			return item.Stock
+		}
+	}
+
+	require.Failf(t, "item not found", "item %s is not in the catalog", name)
+	return nil
+}
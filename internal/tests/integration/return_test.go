@@ -0,0 +1,58 @@
+package integration
+
+import (
+	"avito-shop/internal/domain/dto"
+	"avito-shop/internal/repository"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestReturnRestoresStockPostgres возвращает покупку последней штуки товара с лимитом 1: остаток восстанавливается,
+// монеты возвращаются, а возвращенная покупка больше не учитывается в лимите.
+func TestReturnRestoresStockPostgres(t *testing.T) {
+	storage := newPostgresStorage(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	_, ids := createPostgresUsers(ctx, t, storage, "return-buyer", 2)
+	buyer, other := ids[0], ids[1]
+
+	stock, limit := 1, 1
+	item := "return-item-" + buyer.String()[:8]
+	_, err := storage.CreateMerchItem(ctx, buyer, dto.MerchDTO{Name: item, Price: 40, Available: true,
+		MerchLimits: dto.MerchLimits{Stock: &stock, PerUserLimit: &limit}})
+	require.NoError(t, err)
+
+	require.NoError(t, storage.BuyItem(ctx, buyer, item))
+	require.ErrorIs(t, storage.BuyItem(ctx, other, item), repository.ErrOutOfStock)
+
+	history, err := storage.GetPurchaseHistory(ctx, buyer)
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+
+	returned, err := storage.ReturnPurchase(ctx, buyer, history[0].ID, time.Hour)
+	require.NoError(t, err)
+	require.NotNil(t, returned.ReturnedAt)
+
+	_, err = storage.ReturnPurchase(ctx, buyer, history[0].ID, time.Hour)
+	require.ErrorIs(t, err, repository.ErrPurchaseAlreadyReturned)
+
+	left := merchStock(ctx, t, storage, item)
+	require.NotNil(t, left)
+	require.Equal(t, 1, *left)
+
+	user, err := storage.GetUserById(ctx, buyer)
+	require.NoError(t, err)
+	require.Equal(t, startingGrant, user.Coins)
+
+	// возвращенная покупка не занимает лимит, а восстановленная штука снова продается
+	require.NoError(t, storage.BuyItem(ctx, buyer, item))
+
+	left = merchStock(ctx, t, storage, item)
+	require.NotNil(t, left)
+	require.Zero(t, *left)
+}
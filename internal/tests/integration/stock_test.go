@@ -0,0 +1,98 @@
+package integration
+
+import (
+	"avito-shop/internal/domain/dto"
+	"avito-shop/internal/repository"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestStockSellsOutPostgres одновременно покупает товар с остатком 2 у нескольких пользователей: остаток
+// списывается условием stock >= quantity в базе, поэтому продаются ровно две штуки.
+func TestStockSellsOutPostgres(t *testing.T) {
+	storage := newPostgresStorage(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	const buyers = 6
+	_, ids := createPostgresUsers(ctx, t, storage, "stock-buyer", buyers)
+
+	stock := 2
+	item := "stock-item-" + ids[0].String()[:8]
+	_, err := storage.CreateMerchItem(ctx, ids[0], dto.MerchDTO{Name: item, Price: 10, Available: true,
+		MerchLimits: dto.MerchLimits{Stock: &stock}})
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, buyers)
+	for _, id := range ids {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- storage.BuyItem(ctx, id, item)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	sold := 0
+	for err := range errs {
+		if err == nil {
+			sold++
+			continue
+		}
+		require.True(t, errors.Is(err, repository.ErrOutOfStock), err.Error())
+	}
+	require.Equal(t, stock, sold)
+
+	left := merchStock(ctx, t, storage, item)
+	require.NotNil(t, left)
+	require.Zero(t, *left)
+
+	// покупатель, которому не досталось товара, не потерял монет
+	for _, id := range ids {
+		user, err := storage.GetUserById(ctx, id)
+		require.NoError(t, err)
+		require.Contains(t, []int{startingGrant, startingGrant - 10}, user.Coins)
+	}
+}
+
+// TestPerUserLimitPostgres проверяет лимит на пользователя, который считается запросом к purchases в транзакции
+// покупки: как для отдельных покупок, так и для количества в корзине.
+func TestPerUserLimitPostgres(t *testing.T) {
+	storage := newPostgresStorage(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	_, ids := createPostgresUsers(ctx, t, storage, "limit-buyer", 2)
+
+	limit := 2
+	item := "limit-item-" + ids[0].String()[:8]
+	_, err := storage.CreateMerchItem(ctx, ids[0], dto.MerchDTO{Name: item, Price: 10, Available: true,
+		MerchLimits: dto.MerchLimits{PerUserLimit: &limit}})
+	require.NoError(t, err)
+
+	require.NoError(t, storage.BuyItem(ctx, ids[0], item))
+	require.NoError(t, storage.BuyItem(ctx, ids[0], item))
+	require.ErrorIs(t, storage.BuyItem(ctx, ids[0], item), repository.ErrPurchaseLimitReached)
+
+	_, err = storage.Checkout(ctx, ids[1], []dto.CheckoutItem{{Item: item, Quantity: limit + 1}})
+	require.ErrorIs(t, err, repository.ErrPurchaseLimitReached)
+
+	// лимит считается по каждому пользователю отдельно
+	_, err = storage.Checkout(ctx, ids[1], []dto.CheckoutItem{{Item: item, Quantity: limit}})
+	require.NoError(t, err)
+
+	for _, id := range ids {
+		user, err := storage.GetUserById(ctx, id)
+		require.NoError(t, err)
+		require.Equal(t, startingGrant-limit*10, user.Coins)
+	}
+}
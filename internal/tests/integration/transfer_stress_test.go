@@ -0,0 +1,114 @@
+package integration
+
+import (
+	"avito-shop/internal/services"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	stressWorkers   = 8  // горутин на каждое направление перевода
+	stressTransfers = 25 // переводов на горутину
+)
+
+// stressStorage - хранилище, на котором проверяются встречные переводы.
+type stressStorage interface {
+	services.UserRepository
+	SaveUser(ctx context.Context, username, email string, passHash []byte) error
+	LoginUser(ctx context.Context, inputType, input string) (string, []byte, error)
+}
+
+// TestOpposingTransfersInMemory проверяет только сервисный слой и сохранение монет: memoryStorage выполняет каждый
+// вызов под одним мьютексом, поэтому взаимная блокировка в нем невозможна и порядок блокировки строк здесь
+// не проверяется. Его проверяет TestOpposingTransfersPostgres, который выполняется в CI на контейнере Postgres.
+func TestOpposingTransfersInMemory(t *testing.T) {
+	hammerOpposingTransfers(t, newMemoryStorage())
+}
+
+// TestOpposingTransfersPostgres проверяет порядок блокировки строк users на настоящей базе. Тест создает двух
+// пользователей со случайными именами.
+func TestOpposingTransfersPostgres(t *testing.T) {
+	storage := newPostgresStorage(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	alice, bob := hammerOpposingTransfers(t, storage)
+
+	// кеш users.coins должен совпадать с журналом проводок после всех переводов
+	for _, username := range []string{alice, bob} {
+		balance, err := storage.RecomputeBalance(ctx, username)
+		require.NoError(t, err)
+		require.Equal(t, balance.Ledger, balance.Cached, username)
+	}
+}
+
+// hammerOpposingTransfers одновременно переводит монеты A→B и B→A и проверяет, что ни один перевод не упал
+// и монеты не появились и не пропали. Возвращает имена созданных пользователей.
+func hammerOpposingTransfers(t *testing.T, storage stressStorage) (string, string) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	suffix := uuid.NewString()[:8]
+	alice, bob := "stress-alice-"+suffix, "stress-bob-"+suffix
+
+	ids := make(map[string]uuid.UUID, 2)
+	for _, username := range []string{alice, bob} {
+		require.NoError(t, storage.SaveUser(ctx, username, "", []byte("hash")))
+		id, _, err := storage.LoginUser(ctx, "username", username)
+		require.NoError(t, err)
+		ids[username] = uuid.MustParse(id)
+	}
+
+	before := make(map[string]int, 2)
+	for username, id := range ids {
+		user, err := storage.GetUserById(ctx, id)
+		require.NoError(t, err)
+		before[username] = user.Coins
+	}
+
+	service := services.NewUserService(slog.New(slog.NewTextHandler(os.Stderr,
+		&slog.HandlerOptions{Level: slog.LevelWarn})), storage, catalogCache{})
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*stressWorkers*stressTransfers)
+	for _, pair := range [][2]string{{alice, bob}, {bob, alice}} {
+		from, to := pair[0], pair[1]
+		for i := 0; i < stressWorkers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < stressTransfers; j++ {
+					if err := service.TransferCoins(ctx, ids[from], to, 1); err != nil {
+						errs <- fmt.Errorf("%s -> %s: %w", from, to, err)
+					}
+				}
+			}()
+		}
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	// каждый отправил и получил одинаковое число монет
+	for username, id := range ids {
+		user, err := storage.GetUserById(ctx, id)
+		require.NoError(t, err)
+		require.Equal(t, before[username], user.Coins, username)
+	}
+
+	return alice, bob
+}
@@ -0,0 +1,55 @@
+package mocks
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"time"
+)
+
+type AccountRepositoryMock struct {
+	mock.Mock
+}
+
+func (m *AccountRepositoryMock) LoginUser(ctx context.Context, inputType, input string) (string, []byte, error) {
+	args := m.Called(ctx, inputType, input)
+	return args.String(0), args.Get(1).([]byte), args.Error(2)
+}
+
+func (m *AccountRepositoryMock) GetPasswordHash(ctx context.Context, userID uuid.UUID) ([]byte, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]byte), args.Error(1)
+}
+
+func (m *AccountRepositoryMock) UpdatePassword(ctx context.Context, userID uuid.UUID, passHash []byte) error {
+	args := m.Called(ctx, userID, passHash)
+	return args.Error(0)
+}
+
+type AccountTokenStoreMock struct {
+	mock.Mock
+}
+
+func (m *AccountTokenStoreMock) StorePasswordResetToken(tokenHash, userID string, ttl time.Duration) error {
+	args := m.Called(tokenHash, userID, ttl)
+	return args.Error(0)
+}
+
+func (m *AccountTokenStoreMock) ConsumePasswordResetToken(tokenHash string) (string, error) {
+	args := m.Called(tokenHash)
+	return args.String(0), args.Error(1)
+}
+
+func (m *AccountTokenStoreMock) RevokeUserTokens(userID string) error {
+	args := m.Called(userID)
+	return args.Error(0)
+}
+
+type MailerMock struct {
+	mock.Mock
+}
+
+func (m *MailerMock) Send(ctx context.Context, to, subject, body string) error {
+	args := m.Called(ctx, to, subject, body)
+	return args.Error(0)
+}
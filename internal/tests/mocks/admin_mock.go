@@ -0,0 +1,23 @@
+package mocks
+
+import (
+	"avito-shop/internal/domain/dto"
+	"context"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+)
+
+type AdminRepositoryMock struct {
+	mock.Mock
+}
+
+func (m *AdminRepositoryMock) AdjustBalance(ctx context.Context, adminID uuid.UUID, username string, amount int,
+	reason string) (int, error) {
+	args := m.Called(ctx, adminID, username, amount, reason)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *AdminRepositoryMock) RecomputeBalance(ctx context.Context, username string) (dto.BalanceRecomputeDTO, error) {
+	args := m.Called(ctx, username)
+	return args.Get(0).(dto.BalanceRecomputeDTO), args.Error(1)
+}
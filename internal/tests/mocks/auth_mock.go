@@ -2,6 +2,7 @@ package mocks
 
 import (
 	"context"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -9,8 +10,8 @@ type AuthRepositoryMock struct {
 	mock.Mock
 }
 
-func (m *AuthRepositoryMock) SaveUser(ctx context.Context, login string, password []byte) error {
-	args := m.Called(ctx, login, password)
+func (m *AuthRepositoryMock) SaveUser(ctx context.Context, login, email string, password []byte) error {
+	args := m.Called(ctx, login, email, password)
 	return args.Error(0)
 }
 
@@ -23,3 +24,13 @@ func (m *AuthRepositoryMock) CheckUsernameIsAvailable(ctx context.Context, login
 	args := m.Called(ctx, login)
 	return args.Bool(0), args.Error(1)
 }
+
+func (m *AuthRepositoryMock) UpdatePassword(ctx context.Context, userID uuid.UUID, passHash []byte) error {
+	args := m.Called(ctx, userID, passHash)
+	return args.Error(0)
+}
+
+func (m *AuthRepositoryMock) GetUserRole(ctx context.Context, userID string) (string, error) {
+	args := m.Called(ctx, userID)
+	return args.String(0), args.Error(1)
+}
@@ -0,0 +1,44 @@
+package mocks
+
+import (
+	"avito-shop/internal/domain/dto"
+	"context"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+)
+
+type CatalogRepositoryMock struct {
+	mock.Mock
+}
+
+func (m *CatalogRepositoryMock) CreateMerchItem(ctx context.Context, adminID uuid.UUID,
+	item dto.MerchDTO) (dto.MerchDTO, error) {
+	args := m.Called(ctx, adminID, item)
+	return args.Get(0).(dto.MerchDTO), args.Error(1)
+}
+
+func (m *CatalogRepositoryMock) UpdateMerchItem(ctx context.Context, adminID uuid.UUID, name string,
+	update dto.UpdateMerchRequest) (dto.MerchDTO, error) {
+	args := m.Called(ctx, adminID, name, update)
+	return args.Get(0).(dto.MerchDTO), args.Error(1)
+}
+
+func (m *CatalogRepositoryMock) SetMerchItemArchived(ctx context.Context, name string,
+	archived bool) (dto.MerchDTO, error) {
+	args := m.Called(ctx, name, archived)
+	return args.Get(0).(dto.MerchDTO), args.Error(1)
+}
+
+func (m *CatalogRepositoryMock) SetMerchItemLimits(ctx context.Context, name string,
+	limits dto.MerchLimits) (dto.MerchDTO, error) {
+	args := m.Called(ctx, name, limits)
+	return args.Get(0).(dto.MerchDTO), args.Error(1)
+}
+
+type CatalogCacheMock struct {
+	mock.Mock
+}
+
+func (m *CatalogCacheMock) InvalidateCache() {
+	m.Called()
+}
@@ -0,0 +1,29 @@
+package mocks
+
+import (
+	"avito-shop/internal/domain/models"
+	"context"
+	"github.com/stretchr/testify/mock"
+	"time"
+)
+
+type IdempotencyStoreMock struct {
+	mock.Mock
+}
+
+func (m *IdempotencyStoreMock) ReserveIdempotencyKey(ctx context.Context, userID, key, requestHash string,
+	ttl time.Duration) (models.IdempotencyKey, bool, error) {
+	args := m.Called(ctx, userID, key, requestHash, ttl)
+	return args.Get(0).(models.IdempotencyKey), args.Bool(1), args.Error(2)
+}
+
+func (m *IdempotencyStoreMock) CompleteIdempotencyKey(ctx context.Context, userID, key string, statusCode int,
+	body []byte) error {
+	args := m.Called(ctx, userID, key, statusCode, body)
+	return args.Error(0)
+}
+
+func (m *IdempotencyStoreMock) ReleaseIdempotencyKey(ctx context.Context, userID, key string) error {
+	args := m.Called(ctx, userID, key)
+	return args.Error(0)
+}
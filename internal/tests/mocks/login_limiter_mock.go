@@ -0,0 +1,40 @@
+package mocks
+
+import (
+	"context"
+	"github.com/stretchr/testify/mock"
+	"time"
+)
+
+type LoginAttemptStoreMock struct {
+	mock.Mock
+}
+
+func (m *LoginAttemptStoreMock) LoginLockTTL(key string) (time.Duration, error) {
+	args := m.Called(key)
+	return args.Get(0).(time.Duration), args.Error(1)
+}
+
+func (m *LoginAttemptStoreMock) RegisterLoginFailure(key string, window time.Duration) (int64, error) {
+	args := m.Called(key, window)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *LoginAttemptStoreMock) LockLogin(key string, ttl time.Duration) error {
+	args := m.Called(key, ttl)
+	return args.Error(0)
+}
+
+func (m *LoginAttemptStoreMock) ResetLoginFailures(key string) error {
+	args := m.Called(key)
+	return args.Error(0)
+}
+
+type LoginIdentityResolverMock struct {
+	mock.Mock
+}
+
+func (m *LoginIdentityResolverMock) ResolveLoginIdentity(ctx context.Context, login string) (string, error) {
+	args := m.Called(ctx, login)
+	return args.String(0), args.Error(1)
+}
@@ -0,0 +1,16 @@
+package mocks
+
+import (
+	"avito-shop/internal/domain/dto"
+	"context"
+	"github.com/stretchr/testify/mock"
+)
+
+type MerchRepositoryMock struct {
+	mock.Mock
+}
+
+func (m *MerchRepositoryMock) GetMerchItems(ctx context.Context) ([]dto.MerchDTO, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]dto.MerchDTO), args.Error(1)
+}
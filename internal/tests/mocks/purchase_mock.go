@@ -0,0 +1,24 @@
+package mocks
+
+import (
+	"avito-shop/internal/domain/dto"
+	"context"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"time"
+)
+
+type PurchaseRepositoryMock struct {
+	mock.Mock
+}
+
+func (m *PurchaseRepositoryMock) GetPurchaseHistory(ctx context.Context, userID uuid.UUID) ([]dto.PurchaseRecordDTO, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]dto.PurchaseRecordDTO), args.Error(1)
+}
+
+func (m *PurchaseRepositoryMock) ReturnPurchase(ctx context.Context, userID, purchaseID uuid.UUID,
+	window time.Duration) (dto.PurchaseRecordDTO, error) {
+	args := m.Called(ctx, userID, purchaseID, window)
+	return args.Get(0).(dto.PurchaseRecordDTO), args.Error(1)
+}
@@ -0,0 +1,23 @@
+package mocks
+
+import (
+	"avito-shop/internal/domain/dto"
+	"context"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+)
+
+type ReconcileRepositoryMock struct {
+	mock.Mock
+}
+
+func (m *ReconcileRepositoryMock) GetBalanceChecks(ctx context.Context, startingGrant int) ([]dto.BalanceDriftDTO, error) {
+	args := m.Called(ctx, startingGrant)
+	return args.Get(0).([]dto.BalanceDriftDTO), args.Error(1)
+}
+
+func (m *ReconcileRepositoryMock) CorrectBalance(ctx context.Context, userID uuid.UUID, startingGrant int,
+	reason string) (dto.BalanceDriftDTO, error) {
+	args := m.Called(ctx, userID, startingGrant, reason)
+	return args.Get(0).(dto.BalanceDriftDTO), args.Error(1)
+}
@@ -1,12 +1,35 @@
 package mocks
 
-import "github.com/stretchr/testify/mock"
+import (
+	"github.com/stretchr/testify/mock"
+	"time"
+)
 
 type RedisClientMock struct {
 	mock.Mock
 }
 
-func (m *RedisClientMock) StoreRefreshToken(userID, refreshToken string) error {
-	args := m.Called(userID, refreshToken)
+func (m *RedisClientMock) StoreRefreshToken(userID, family, refreshToken string) error {
+	args := m.Called(userID, family, refreshToken)
+	return args.Error(0)
+}
+
+func (m *RedisClientMock) ConsumeRefreshToken(refreshToken string) (string, error) {
+	args := m.Called(refreshToken)
+	return args.String(0), args.Error(1)
+}
+
+func (m *RedisClientMock) RevokeRefreshFamily(family string) error {
+	args := m.Called(family)
+	return args.Error(0)
+}
+
+func (m *RedisClientMock) DenyAccessToken(jti string, ttl time.Duration) error {
+	args := m.Called(jti, ttl)
+	return args.Error(0)
+}
+
+func (m *RedisClientMock) RevokeUserTokens(userID string) error {
+	args := m.Called(userID)
 	return args.Error(0)
 }
@@ -26,8 +26,14 @@ func (m *UserRepositoryMock) GetCoinTransactions(ctx context.Context, userID uui
 	return args.Get(0).(dto.TransactionDTO), args.Error(1)
 }
 
-func (m *UserRepositoryMock) TransferCoins(ctx context.Context, fromUserID, toUserID uuid.UUID, amount int) error {
-	args := m.Called(ctx, fromUserID, toUserID, amount)
+func (m *UserRepositoryMock) GetTransfers(ctx context.Context, userID uuid.UUID,
+	filter dto.TransferFilter) ([]dto.TransferDTO, error) {
+	args := m.Called(ctx, userID, filter)
+	return args.Get(0).([]dto.TransferDTO), args.Error(1)
+}
+
+func (m *UserRepositoryMock) TransferCoins(ctx context.Context, fromUserID uuid.UUID, toUsername string, amount int) error {
+	args := m.Called(ctx, fromUserID, toUsername, amount)
 	return args.Error(0)
 }
 
@@ -35,3 +41,20 @@ func (m *UserRepositoryMock) BuyItem(ctx context.Context, userID uuid.UUID, item
 	args := m.Called(ctx, userID, item)
 	return args.Error(0)
 }
+
+func (m *UserRepositoryMock) GiftItem(ctx context.Context, fromUserID uuid.UUID, toUsername, item,
+	message string) error {
+	args := m.Called(ctx, fromUserID, toUsername, item, message)
+	return args.Error(0)
+}
+
+func (m *UserRepositoryMock) GetGifts(ctx context.Context, userID uuid.UUID) (dto.GiftHistoryDTO, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).(dto.GiftHistoryDTO), args.Error(1)
+}
+
+func (m *UserRepositoryMock) Checkout(ctx context.Context, userID uuid.UUID,
+	items []dto.CheckoutItem) ([]dto.CheckoutLineDTO, error) {
+	args := m.Called(ctx, userID, items)
+	return args.Get(0).([]dto.CheckoutLineDTO), args.Error(1)
+}
@@ -0,0 +1,145 @@
+package unit
+
+import (
+	"avito-shop/internal/repository"
+	"avito-shop/internal/services"
+	"avito-shop/internal/tests/mocks"
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"log/slog"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestAccountService_ChangePassword_UpdatesHashAndRevokesSessions(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	userID := uuid.New()
+
+	repo := new(mocks.AccountRepositoryMock)
+	tokens := new(mocks.AccountTokenStoreMock)
+	mailer := new(mocks.MailerMock)
+	service := services.NewAccountService(slog.Default(), repo, tokens, mailer, newTestHasher(t), time.Minute)
+
+	storedHash, err := bcrypt.GenerateFromPassword([]byte("oldPassword"), bcrypt.MinCost)
+	require.NoError(t, err)
+	repo.On("GetPasswordHash", ctx, userID).
+		Return(storedHash, nil).Once()
+	repo.On("UpdatePassword", ctx, userID, mockHashedPassword("newPassword")).
+		Return(nil).Once()
+	tokens.On("RevokeUserTokens", userID.String()).
+		Return(nil).Once()
+
+	// Act
+	err = service.ChangePassword(ctx, userID, "oldPassword", "newPassword")
+
+	// Assert
+	require.NoError(t, err)
+	repo.AssertExpectations(t)
+	tokens.AssertExpectations(t)
+}
+
+func TestAccountService_ChangePassword_RejectsWrongOldPassword(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	userID := uuid.New()
+
+	repo := new(mocks.AccountRepositoryMock)
+	tokens := new(mocks.AccountTokenStoreMock)
+	mailer := new(mocks.MailerMock)
+	service := services.NewAccountService(slog.Default(), repo, tokens, mailer, newTestHasher(t), time.Minute)
+
+	storedHash, err := bcrypt.GenerateFromPassword([]byte("oldPassword"), bcrypt.MinCost)
+	require.NoError(t, err)
+	repo.On("GetPasswordHash", ctx, userID).
+		Return(storedHash, nil).Once()
+
+	// Act
+	err = service.ChangePassword(ctx, userID, "wrongPassword", "newPassword")
+
+	// Assert
+	assert.ErrorIs(t, err, services.ErrInvalidCredentials)
+	repo.AssertNotCalled(t, "UpdatePassword", mock.Anything, mock.Anything, mock.Anything)
+	tokens.AssertNotCalled(t, "RevokeUserTokens", mock.Anything)
+}
+
+func TestAccountService_RequestPasswordReset_MailsSingleUseToken(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	userID := uuid.New()
+
+	repo := new(mocks.AccountRepositoryMock)
+	tokens := new(mocks.AccountTokenStoreMock)
+	mailer := new(mocks.MailerMock)
+	service := services.NewAccountService(slog.Default(), repo, tokens, mailer, newTestHasher(t), 15*time.Minute)
+
+	var storedHash string
+	repo.On("LoginUser", ctx, "email", "alice@example.com").
+		Return(userID.String(), []byte("hash"), nil).Once()
+	tokens.On("StorePasswordResetToken", mock.Anything, userID.String(), 15*time.Minute).
+		Run(func(args mock.Arguments) { storedHash = args.String(0) }).
+		Return(nil).Once()
+	var body string
+	mailer.On("Send", ctx, "alice@example.com", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { body = args.String(3) }).
+		Return(nil).Once()
+
+	// Act
+	err := service.RequestPasswordReset(ctx, "Alice@Example.com")
+
+	// Assert
+	require.NoError(t, err)
+	token := regexp.MustCompile(`пароля: (\S+)`).FindStringSubmatch(body)
+	require.Len(t, token, 2)
+	assert.NotContains(t, storedHash, token[1], "в хранилище должен попадать только хеш токена")
+	repo.AssertExpectations(t)
+	tokens.AssertExpectations(t)
+	mailer.AssertExpectations(t)
+}
+
+func TestAccountService_RequestPasswordReset_IgnoresUnknownEmail(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+
+	repo := new(mocks.AccountRepositoryMock)
+	tokens := new(mocks.AccountTokenStoreMock)
+	mailer := new(mocks.MailerMock)
+	service := services.NewAccountService(slog.Default(), repo, tokens, mailer, newTestHasher(t), time.Minute)
+
+	repo.On("LoginUser", ctx, "email", "ghost@example.com").
+		Return("", []byte{}, repository.ErrUserNotFound).Once()
+
+	// Act
+	err := service.RequestPasswordReset(ctx, "ghost@example.com")
+
+	// Assert
+	require.NoError(t, err)
+	mailer.AssertNotCalled(t, "Send", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestAccountService_ResetPassword_RejectsUsedToken(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+
+	repo := new(mocks.AccountRepositoryMock)
+	tokens := new(mocks.AccountTokenStoreMock)
+	mailer := new(mocks.MailerMock)
+	service := services.NewAccountService(slog.Default(), repo, tokens, mailer, newTestHasher(t), time.Minute)
+
+	tokens.On("ConsumePasswordResetToken", mock.Anything).
+		Return("", repository.ErrTokenNotFound).Once()
+
+	// Act
+	err := service.ResetPassword(ctx, "used-token", "newPassword")
+
+	// Assert
+	assert.ErrorIs(t, err, services.ErrInvalidResetToken)
+	repo.AssertNotCalled(t, "UpdatePassword", mock.Anything, mock.Anything, mock.Anything)
+}
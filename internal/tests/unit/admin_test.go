@@ -0,0 +1,111 @@
+package unit
+
+import (
+	"avito-shop/internal/domain/dto"
+	"avito-shop/internal/repository"
+	"avito-shop/internal/services"
+	"avito-shop/internal/tests/mocks"
+	"context"
+	"testing"
+
+	"log/slog"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminService_AdjustBalance_ReturnsNewBalance(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	adminID := uuid.New()
+
+	repo := new(mocks.AdminRepositoryMock)
+	service := services.NewAdminService(slog.Default(), repo)
+
+	repo.On("AdjustBalance", ctx, adminID, "bob", -300, "refund reversal").
+		Return(700, nil).Once()
+
+	// Act
+	coins, err := service.AdjustBalance(ctx, adminID, "bob", -300, "  refund reversal ")
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, 700, coins)
+	repo.AssertExpectations(t)
+}
+
+func TestAdminService_AdjustBalance_ValidatesInput(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	adminID := uuid.New()
+
+	repo := new(mocks.AdminRepositoryMock)
+	service := services.NewAdminService(slog.Default(), repo)
+
+	// Act
+	_, zeroErr := service.AdjustBalance(ctx, adminID, "bob", 0, "reason")
+	_, reasonErr := service.AdjustBalance(ctx, adminID, "bob", 100, " ")
+
+	// Assert
+	assert.ErrorIs(t, zeroErr, services.ErrInvalidAdjustment)
+	assert.ErrorIs(t, reasonErr, services.ErrReasonRequired)
+	repo.AssertNotCalled(t, "AdjustBalance", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestAdminService_AdjustBalance_PropagatesRepositoryErrors(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	adminID := uuid.New()
+
+	repo := new(mocks.AdminRepositoryMock)
+	service := services.NewAdminService(slog.Default(), repo)
+
+	repo.On("AdjustBalance", ctx, adminID, "bob", -5000, "penalty").
+		Return(0, repository.ErrNegativeBalance).Once()
+
+	// Act
+	_, err := service.AdjustBalance(ctx, adminID, "bob", -5000, "penalty")
+
+	// Assert
+	assert.ErrorIs(t, err, repository.ErrNegativeBalance)
+}
+
+func TestAdminService_RecomputeBalance_ReturnsLedgerBalance(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	adminID := uuid.New()
+
+	repo := new(mocks.AdminRepositoryMock)
+	service := services.NewAdminService(slog.Default(), repo)
+
+	expected := dto.BalanceRecomputeDTO{Username: "bob", Cached: 1200, Ledger: 1000}
+	repo.On("RecomputeBalance", ctx, "bob").Return(expected, nil).Once()
+
+	// Act
+	result, err := service.RecomputeBalance(ctx, adminID, "bob")
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, expected, result)
+	repo.AssertExpectations(t)
+}
+
+func TestAdminService_RecomputeBalance_PropagatesRepositoryErrors(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	adminID := uuid.New()
+
+	repo := new(mocks.AdminRepositoryMock)
+	service := services.NewAdminService(slog.Default(), repo)
+
+	repo.On("RecomputeBalance", ctx, "ghost").
+		Return(dto.BalanceRecomputeDTO{}, repository.ErrUserNotFound).Once()
+
+	// Act
+	_, err := service.RecomputeBalance(ctx, adminID, "ghost")
+
+	// Assert
+	assert.ErrorIs(t, err, repository.ErrUserNotFound)
+}
@@ -1,6 +1,7 @@
 package unit
 
 import (
+	"avito-shop/internal/lib/hasher"
 	"avito-shop/internal/lib/jwt"
 	"avito-shop/internal/middlewares"
 	"avito-shop/internal/repository"
@@ -9,9 +10,11 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"log/slog"
 
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -27,17 +30,19 @@ func TestAuthService_Login_RegistersNewUserAndStoresTokens(t *testing.T) {
 	authRepo := new(mocks.AuthRepositoryMock)
 	redisMock := new(mocks.RedisClientMock)
 	jwtGen := jwt.NewGenerator("secret", 0, 0)
-	service := services.NewAuthService(slog.Default(), authRepo, redisMock, jwtGen)
+	service := services.NewAuthService(slog.Default(), authRepo, redisMock, jwtGen, newTestHasher(t), true)
 
 	authRepo.On("LoginUser", ctx, "username", username).
 		Return("", []byte{}, repository.ErrUserNotFound).Once()
-	authRepo.On("SaveUser", ctx, username, mockHashedPassword(password)).
+	authRepo.On("SaveUser", ctx, username, "", mockHashedPassword(password)).
 		Return(nil).Once()
 	storedHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	require.NoError(t, err)
 	authRepo.On("LoginUser", ctx, "username", username).
 		Return("user-id", storedHash, nil).Once()
-	redisMock.On("StoreRefreshToken", "user-id", mock.Anything).
+	authRepo.On("GetUserRole", ctx, "user-id").
+		Return("user", nil).Once()
+	redisMock.On("StoreRefreshToken", "user-id", mock.Anything, mock.Anything).
 		Return(nil).Once()
 
 	// Act
@@ -60,7 +65,7 @@ func TestAuthService_Login_ReturnsInvalidCredentialsForWrongPassword(t *testing.
 	authRepo := new(mocks.AuthRepositoryMock)
 	redisMock := new(mocks.RedisClientMock)
 	jwtGen := jwt.NewGenerator("secret", 0, 0)
-	service := services.NewAuthService(slog.Default(), authRepo, redisMock, jwtGen)
+	service := services.NewAuthService(slog.Default(), authRepo, redisMock, jwtGen, newTestHasher(t), true)
 
 	storedHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	require.NoError(t, err)
@@ -86,7 +91,7 @@ func TestAuthService_Login_PropagatesRepositoryErrors(t *testing.T) {
 	authRepo := new(mocks.AuthRepositoryMock)
 	redisMock := new(mocks.RedisClientMock)
 	jwtGen := jwt.NewGenerator("secret", 0, 0)
-	service := services.NewAuthService(slog.Default(), authRepo, redisMock, jwtGen)
+	service := services.NewAuthService(slog.Default(), authRepo, redisMock, jwtGen, newTestHasher(t), true)
 
 	loginErr := errors.New("db failure")
 	authRepo.On("LoginUser", ctx, "username", username).
@@ -112,14 +117,16 @@ func TestAuthService_Login_ReturnsErrorWhenRefreshTokenStorageFails(t *testing.T
 	authRepo := new(mocks.AuthRepositoryMock)
 	redisMock := new(mocks.RedisClientMock)
 	jwtGen := jwt.NewGenerator("secret", 0, 0)
-	service := services.NewAuthService(slog.Default(), authRepo, redisMock, jwtGen)
+	service := services.NewAuthService(slog.Default(), authRepo, redisMock, jwtGen, newTestHasher(t), true)
 
 	storedHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	require.NoError(t, err)
 	authRepo.On("LoginUser", ctx, "username", username).
 		Return("user-id", storedHash, nil).Once()
+	authRepo.On("GetUserRole", ctx, "user-id").
+		Return("user", nil).Once()
 	redisErr := errors.New("redis down")
-	redisMock.On("StoreRefreshToken", "user-id", mock.Anything).
+	redisMock.On("StoreRefreshToken", "user-id", mock.Anything, mock.Anything).
 		Return(redisErr).Once()
 
 	// Act
@@ -138,7 +145,7 @@ func TestAuthService_Login_ReturnsErrorForInvalidInput(t *testing.T) {
 	authRepo := new(mocks.AuthRepositoryMock)
 	redisMock := new(mocks.RedisClientMock)
 	jwtGen := jwt.NewGenerator("secret", 0, 0)
-	service := services.NewAuthService(slog.Default(), authRepo, redisMock, jwtGen)
+	service := services.NewAuthService(slog.Default(), authRepo, redisMock, jwtGen, newTestHasher(t), true)
 
 	// Act
 	access, refresh, err := service.Login(context.Background(), "", "short")
@@ -148,7 +155,340 @@ func TestAuthService_Login_ReturnsErrorForInvalidInput(t *testing.T) {
 	assert.Empty(t, access)
 	assert.Empty(t, refresh)
 	authRepo.AssertNotCalled(t, "LoginUser", mock.Anything, mock.Anything, mock.Anything)
-	redisMock.AssertNotCalled(t, "StoreRefreshToken", mock.Anything, mock.Anything)
+	redisMock.AssertNotCalled(t, "StoreRefreshToken", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestAuthService_Login_DoesNotRegisterWhenAutoRegistrationDisabled(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	username := "typo-user"
+
+	authRepo := new(mocks.AuthRepositoryMock)
+	redisMock := new(mocks.RedisClientMock)
+	jwtGen := jwt.NewGenerator("secret", 0, 0)
+	service := services.NewAuthService(slog.Default(), authRepo, redisMock, jwtGen, newTestHasher(t), false)
+
+	authRepo.On("LoginUser", ctx, "username", username).
+		Return("", []byte{}, repository.ErrUserNotFound).Once()
+
+	// Act
+	access, refresh, err := service.Login(ctx, username, "password123")
+
+	// Assert
+	assert.ErrorIs(t, err, services.ErrInvalidCredentials)
+	assert.Empty(t, access)
+	assert.Empty(t, refresh)
+	authRepo.AssertExpectations(t)
+	authRepo.AssertNotCalled(t, "SaveUser", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestAuthService_Login_LooksUpByEmail(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	email := "Alice@Example.com"
+	password := "password123"
+
+	authRepo := new(mocks.AuthRepositoryMock)
+	redisMock := new(mocks.RedisClientMock)
+	jwtGen := jwt.NewGenerator("secret", time.Minute, time.Hour)
+	service := services.NewAuthService(slog.Default(), authRepo, redisMock, jwtGen, newTestHasher(t), true)
+
+	storedHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	require.NoError(t, err)
+	authRepo.On("LoginUser", ctx, "email", email).
+		Return("user-id", storedHash, nil).Once()
+	authRepo.On("GetUserRole", ctx, "user-id").
+		Return("user", nil).Once()
+	redisMock.On("StoreRefreshToken", "user-id", mock.Anything, mock.Anything).
+		Return(nil).Once()
+
+	// Act
+	access, _, err := service.Login(ctx, email, password)
+
+	// Assert
+	require.NoError(t, err)
+	assert.NotEmpty(t, access)
+	authRepo.AssertExpectations(t)
+}
+
+func TestAuthService_Login_DoesNotAutoRegisterUnknownEmail(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	email := "ghost@example.com"
+
+	authRepo := new(mocks.AuthRepositoryMock)
+	redisMock := new(mocks.RedisClientMock)
+	jwtGen := jwt.NewGenerator("secret", 0, 0)
+	service := services.NewAuthService(slog.Default(), authRepo, redisMock, jwtGen, newTestHasher(t), true)
+
+	authRepo.On("LoginUser", ctx, "email", email).
+		Return("", []byte{}, repository.ErrUserNotFound).Once()
+	authRepo.On("LoginUser", ctx, "username", email).
+		Return("", []byte{}, repository.ErrUserNotFound).Once()
+
+	// Act
+	_, _, err := service.Login(ctx, email, "password123")
+
+	// Assert
+	assert.ErrorIs(t, err, services.ErrInvalidCredentials)
+	authRepo.AssertExpectations(t)
+	authRepo.AssertNotCalled(t, "SaveUser", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestAuthService_Login_FallsBackToEmailLikeUsername(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	login := "Foo@Bar.com"
+	password := "password123"
+
+	authRepo := new(mocks.AuthRepositoryMock)
+	redisMock := new(mocks.RedisClientMock)
+	jwtGen := jwt.NewGenerator("secret", time.Minute, time.Hour)
+	service := services.NewAuthService(slog.Default(), authRepo, redisMock, jwtGen, newTestHasher(t), true)
+
+	storedHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	require.NoError(t, err)
+	authRepo.On("LoginUser", ctx, "email", login).
+		Return("", []byte{}, repository.ErrUserNotFound).Once()
+	authRepo.On("LoginUser", ctx, "username", login).
+		Return("user-id", storedHash, nil).Once()
+	authRepo.On("GetUserRole", ctx, "user-id").
+		Return("user", nil).Once()
+	redisMock.On("StoreRefreshToken", "user-id", mock.Anything, mock.Anything).
+		Return(nil).Once()
+
+	// Act
+	access, _, err := service.Login(ctx, login, password)
+
+	// Assert
+	require.NoError(t, err)
+	assert.NotEmpty(t, access)
+	authRepo.AssertExpectations(t)
+	authRepo.AssertNotCalled(t, "SaveUser", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestAuthService_ResolveLoginIdentity_ResolvesUsernameAndEmailToSameID(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+
+	authRepo := new(mocks.AuthRepositoryMock)
+	jwtGen := jwt.NewGenerator("secret", time.Minute, time.Hour)
+	service := services.NewAuthService(slog.Default(), authRepo, new(mocks.RedisClientMock), jwtGen,
+		newTestHasher(t), true)
+
+	authRepo.On("LoginUser", ctx, "username", "alice").
+		Return("user-id", []byte{}, nil).Once()
+	authRepo.On("LoginUser", ctx, "email", "Alice@Example.com").
+		Return("user-id", []byte{}, nil).Once()
+	authRepo.On("LoginUser", ctx, "username", "ghost").
+		Return("", []byte{}, repository.ErrUserNotFound).Once()
+
+	// Act
+	byUsername, usernameErr := service.ResolveLoginIdentity(ctx, "alice")
+	byEmail, emailErr := service.ResolveLoginIdentity(ctx, "Alice@Example.com")
+	_, unknownErr := service.ResolveLoginIdentity(ctx, "ghost")
+
+	// Assert
+	require.NoError(t, usernameErr)
+	require.NoError(t, emailErr)
+	assert.Equal(t, "user-id", byUsername)
+	assert.Equal(t, byUsername, byEmail)
+	assert.ErrorIs(t, unknownErr, repository.ErrUserNotFound)
+	authRepo.AssertExpectations(t)
+}
+
+func TestAuthService_Register_SavesUserWithNormalizedEmail(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	username := "newuser"
+	password := "strongPass"
+
+	authRepo := new(mocks.AuthRepositoryMock)
+	redisMock := new(mocks.RedisClientMock)
+	jwtGen := jwt.NewGenerator("secret", time.Minute, time.Hour)
+	service := services.NewAuthService(slog.Default(), authRepo, redisMock, jwtGen, newTestHasher(t), false)
+
+	authRepo.On("SaveUser", ctx, username, "newuser@example.com", mockHashedPassword(password)).
+		Return(nil).Once()
+	authRepo.On("LoginUser", ctx, "username", username).
+		Return("user-id", []byte("hash"), nil).Once()
+	authRepo.On("GetUserRole", ctx, "user-id").
+		Return("user", nil).Once()
+	redisMock.On("StoreRefreshToken", "user-id", mock.Anything, mock.Anything).
+		Return(nil).Once()
+
+	// Act
+	access, refresh, err := service.Register(ctx, username, " NewUser@Example.com ", password)
+
+	// Assert
+	require.NoError(t, err)
+	assert.NotEmpty(t, access)
+	assert.NotEmpty(t, refresh)
+	authRepo.AssertExpectations(t)
+	redisMock.AssertExpectations(t)
+}
+
+func TestAuthService_Register_ValidatesInput(t *testing.T) {
+	// Arrange
+	authRepo := new(mocks.AuthRepositoryMock)
+	redisMock := new(mocks.RedisClientMock)
+	jwtGen := jwt.NewGenerator("secret", 0, 0)
+	service := services.NewAuthService(slog.Default(), authRepo, redisMock, jwtGen, newTestHasher(t), true)
+
+	// Act
+	_, _, emailErr := service.Register(context.Background(), "newuser", "not-an-email", "strongPass")
+	_, _, passErr := service.Register(context.Background(), "newuser", "user@example.com", "short")
+	_, _, loginErr := service.Register(context.Background(), "other@example.com", "user@example.com", "strongPass")
+	_, _, upperLoginErr := service.Register(context.Background(), "Other@Example.com", "user@example.com", "strongPass")
+
+	// Assert
+	assert.ErrorIs(t, emailErr, middlewares.ErrInvalidEmail)
+	assert.ErrorIs(t, passErr, middlewares.ErrPasswordTooShort)
+	assert.ErrorIs(t, loginErr, middlewares.ErrLoginIsEmail)
+	assert.ErrorIs(t, upperLoginErr, middlewares.ErrLoginIsEmail)
+	authRepo.AssertNotCalled(t, "SaveUser", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestAuthService_Register_ReturnsConflictForTakenEmail(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+
+	authRepo := new(mocks.AuthRepositoryMock)
+	redisMock := new(mocks.RedisClientMock)
+	jwtGen := jwt.NewGenerator("secret", 0, 0)
+	service := services.NewAuthService(slog.Default(), authRepo, redisMock, jwtGen, newTestHasher(t), true)
+
+	authRepo.On("SaveUser", ctx, "newuser", "taken@example.com", mock.Anything).
+		Return(repository.ErrEmailAlreadyExists).Once()
+
+	// Act
+	_, _, err := service.Register(ctx, "newuser", "taken@example.com", "strongPass")
+
+	// Assert
+	assert.ErrorIs(t, err, services.ErrEmailAlreadyExists)
+	authRepo.AssertExpectations(t)
+}
+
+func TestAuthService_Refresh_RotatesRefreshToken(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+
+	authRepo := new(mocks.AuthRepositoryMock)
+	redisMock := new(mocks.RedisClientMock)
+	jwtGen := jwt.NewGenerator("secret", time.Minute, time.Hour)
+	service := services.NewAuthService(slog.Default(), authRepo, redisMock, jwtGen, newTestHasher(t), true)
+
+	_, oldRefresh, err := jwtGen.GeneratePair("user-id", "family-id", "user")
+	require.NoError(t, err)
+	redisMock.On("ConsumeRefreshToken", oldRefresh).
+		Return("user-id", nil).Once()
+	authRepo.On("GetUserRole", ctx, "user-id").
+		Return("user", nil).Once()
+	redisMock.On("StoreRefreshToken", "user-id", "family-id", mock.Anything).
+		Return(nil).Once()
+
+	// Act
+	access, refresh, err := service.Refresh(ctx, oldRefresh)
+
+	// Assert
+	require.NoError(t, err)
+	assert.NotEmpty(t, access)
+	assert.NotEqual(t, oldRefresh, refresh)
+	redisMock.AssertExpectations(t)
+}
+
+func TestAuthService_Refresh_RevokesFamilyOnReuse(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+
+	authRepo := new(mocks.AuthRepositoryMock)
+	redisMock := new(mocks.RedisClientMock)
+	jwtGen := jwt.NewGenerator("secret", time.Minute, time.Hour)
+	service := services.NewAuthService(slog.Default(), authRepo, redisMock, jwtGen, newTestHasher(t), true)
+
+	_, usedRefresh, err := jwtGen.GeneratePair("user-id", "family-id", "user")
+	require.NoError(t, err)
+	redisMock.On("ConsumeRefreshToken", usedRefresh).
+		Return("", repository.ErrTokenNotFound).Once()
+	redisMock.On("RevokeRefreshFamily", "family-id").
+		Return(nil).Once()
+
+	// Act
+	access, refresh, err := service.Refresh(ctx, usedRefresh)
+
+	// Assert
+	assert.ErrorIs(t, err, services.ErrRefreshTokenReused)
+	assert.Empty(t, access)
+	assert.Empty(t, refresh)
+	redisMock.AssertExpectations(t)
+	redisMock.AssertNotCalled(t, "StoreRefreshToken", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestAuthService_Refresh_RejectsAccessToken(t *testing.T) {
+	// Arrange
+	authRepo := new(mocks.AuthRepositoryMock)
+	redisMock := new(mocks.RedisClientMock)
+	jwtGen := jwt.NewGenerator("secret", time.Minute, time.Hour)
+	service := services.NewAuthService(slog.Default(), authRepo, redisMock, jwtGen, newTestHasher(t), true)
+
+	access, _, err := jwtGen.GeneratePair("user-id", "family-id", "user")
+	require.NoError(t, err)
+
+	// Act
+	_, _, err = service.Refresh(context.Background(), access)
+
+	// Assert
+	assert.ErrorIs(t, err, services.ErrInvalidRefreshToken)
+	redisMock.AssertNotCalled(t, "ConsumeRefreshToken", mock.Anything)
+}
+
+func TestAuthService_Logout_RevokesAccessTokenAndRefreshFamily(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+
+	authRepo := new(mocks.AuthRepositoryMock)
+	redisMock := new(mocks.RedisClientMock)
+	jwtGen := jwt.NewGenerator("secret", time.Minute, time.Hour)
+	service := services.NewAuthService(slog.Default(), authRepo, redisMock, jwtGen, newTestHasher(t), true)
+
+	_, refresh, err := jwtGen.GeneratePair("user-id", "family-id", "user")
+	require.NoError(t, err)
+	redisMock.On("DenyAccessToken", "access-jti", mock.AnythingOfType("time.Duration")).
+		Return(nil).Once()
+	redisMock.On("RevokeRefreshFamily", "family-id").
+		Return(nil).Once()
+
+	// Act
+	err = service.Logout(ctx, "user-id", "access-jti", time.Now().Add(time.Minute), refresh)
+
+	// Assert
+	require.NoError(t, err)
+	redisMock.AssertExpectations(t)
+}
+
+func TestAuthService_Logout_RejectsForeignRefreshToken(t *testing.T) {
+	// Arrange
+	authRepo := new(mocks.AuthRepositoryMock)
+	redisMock := new(mocks.RedisClientMock)
+	jwtGen := jwt.NewGenerator("secret", time.Minute, time.Hour)
+	service := services.NewAuthService(slog.Default(), authRepo, redisMock, jwtGen, newTestHasher(t), true)
+
+	_, refresh, err := jwtGen.GeneratePair("other-user", "family-id", "user")
+	require.NoError(t, err)
+
+	// Act
+	err = service.Logout(context.Background(), "user-id", "access-jti", time.Now().Add(time.Minute), refresh)
+
+	// Assert
+	assert.ErrorIs(t, err, services.ErrInvalidRefreshToken)
+	redisMock.AssertNotCalled(t, "RevokeRefreshFamily", mock.Anything)
+}
+
+func newTestHasher(t *testing.T) services.PasswordHasher {
+	t.Helper()
+	h, err := hasher.NewBcrypt(bcrypt.MinCost)
+	require.NoError(t, err)
+	return h
 }
 
 func mockHashedPassword(password string) interface{} {
@@ -156,3 +496,101 @@ func mockHashedPassword(password string) interface{} {
 		return bcrypt.CompareHashAndPassword(hash, []byte(password)) == nil
 	})
 }
+
+func TestAuthService_Login_RehashesOutdatedPasswordHash(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	userID := uuid.New()
+	username := "existing"
+	password := "correctPass"
+
+	authRepo := new(mocks.AuthRepositoryMock)
+	redisMock := new(mocks.RedisClientMock)
+	jwtGen := jwt.NewGenerator("secret", time.Minute, time.Hour)
+	argonHasher, err := hasher.NewArgon2id(hasher.Argon2idParams{Memory: 1024, Iterations: 1, Parallelism: 1})
+	require.NoError(t, err)
+	service := services.NewAuthService(slog.Default(), authRepo, redisMock, jwtGen, argonHasher, true)
+
+	storedHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	require.NoError(t, err)
+	authRepo.On("LoginUser", ctx, "username", username).
+		Return(userID.String(), storedHash, nil).Once()
+	authRepo.On("UpdatePassword", ctx, userID, mock.MatchedBy(func(hash []byte) bool {
+		return !argonHasher.NeedsRehash(hash) && argonHasher.Compare(hash, password) == nil
+	})).Return(nil).Once()
+	authRepo.On("GetUserRole", ctx, userID.String()).
+		Return("user", nil).Once()
+	redisMock.On("StoreRefreshToken", userID.String(), mock.Anything, mock.Anything).
+		Return(nil).Once()
+
+	// Act
+	_, _, err = service.Login(ctx, username, password)
+
+	// Assert
+	require.NoError(t, err)
+	authRepo.AssertExpectations(t)
+}
+
+func TestAuthService_Login_SucceedsWhenRehashFails(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	userID := uuid.New()
+	username := "existing"
+	password := "correctPass"
+
+	authRepo := new(mocks.AuthRepositoryMock)
+	redisMock := new(mocks.RedisClientMock)
+	jwtGen := jwt.NewGenerator("secret", time.Minute, time.Hour)
+	strongHasher, err := hasher.NewBcrypt(bcrypt.MinCost + 1)
+	require.NoError(t, err)
+	service := services.NewAuthService(slog.Default(), authRepo, redisMock, jwtGen, strongHasher, true)
+
+	storedHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	require.NoError(t, err)
+	authRepo.On("LoginUser", ctx, "username", username).
+		Return(userID.String(), storedHash, nil).Once()
+	authRepo.On("UpdatePassword", ctx, userID, mock.Anything).
+		Return(errors.New("db is down")).Once()
+	authRepo.On("GetUserRole", ctx, userID.String()).
+		Return("user", nil).Once()
+	redisMock.On("StoreRefreshToken", userID.String(), mock.Anything, mock.Anything).
+		Return(nil).Once()
+
+	// Act
+	access, _, err := service.Login(ctx, username, password)
+
+	// Assert
+	require.NoError(t, err)
+	assert.NotEmpty(t, access)
+	authRepo.AssertExpectations(t)
+}
+
+func TestAuthService_Login_EmbedsRoleInAccessToken(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	username := "admin"
+	password := "password123"
+
+	authRepo := new(mocks.AuthRepositoryMock)
+	redisMock := new(mocks.RedisClientMock)
+	jwtGen := jwt.NewGenerator("secret", time.Minute, time.Hour)
+	service := services.NewAuthService(slog.Default(), authRepo, redisMock, jwtGen, newTestHasher(t), true)
+
+	storedHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	require.NoError(t, err)
+	authRepo.On("LoginUser", ctx, "username", username).
+		Return("user-id", storedHash, nil).Once()
+	authRepo.On("GetUserRole", ctx, "user-id").
+		Return("admin", nil).Once()
+	redisMock.On("StoreRefreshToken", "user-id", mock.Anything, mock.Anything).
+		Return(nil).Once()
+
+	// Act
+	access, _, err := service.Login(ctx, username, password)
+
+	// Assert
+	require.NoError(t, err)
+	claims, err := jwtGen.ParseAccess(access)
+	require.NoError(t, err)
+	assert.Equal(t, "admin", claims.Role)
+}
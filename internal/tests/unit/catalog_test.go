@@ -0,0 +1,136 @@
+package unit
+
+import (
+	"avito-shop/internal/domain/dto"
+	"avito-shop/internal/repository"
+	"avito-shop/internal/services"
+	"avito-shop/internal/tests/mocks"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCatalogService_CreateItem_DefaultsToAvailableAndInvalidatesCache(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	adminID := uuid.New()
+	want := dto.MerchDTO{Name: "sticker", Price: 500, Available: true, Description: "Набор стикеров"}
+
+	repo := new(mocks.CatalogRepositoryMock)
+	repo.On("CreateMerchItem", ctx, adminID, want).Return(want, nil).Once()
+	cache := new(mocks.CatalogCacheMock)
+	cache.On("InvalidateCache").Once()
+
+	service := services.NewCatalogService(slog.Default(), repo, cache)
+
+	// Act
+	item, err := service.CreateItem(ctx, adminID, dto.CreateMerchRequest{
+		Name: "sticker", Price: 500, Description: " Набор стикеров ",
+	})
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, want, item)
+	repo.AssertExpectations(t)
+	cache.AssertExpectations(t)
+}
+
+func TestCatalogService_ValidatesInput(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	repo := new(mocks.CatalogRepositoryMock)
+	cache := new(mocks.CatalogCacheMock)
+	service := services.NewCatalogService(slog.Default(), repo, cache)
+	zero := 0
+	spaced := "new name"
+
+	// Act
+	_, emptyName := service.CreateItem(ctx, uuid.New(), dto.CreateMerchRequest{Price: 100})
+	_, slashName := service.CreateItem(ctx, uuid.New(), dto.CreateMerchRequest{Name: "a/b", Price: 100})
+	_, longName := service.CreateItem(ctx, uuid.New(), dto.CreateMerchRequest{Name: strings.Repeat("x", 51), Price: 100})
+	_, freeItem := service.CreateItem(ctx, uuid.New(), dto.CreateMerchRequest{Name: "free"})
+	_, emptyUpdate := service.UpdateItem(ctx, uuid.New(), "cup", dto.UpdateMerchRequest{})
+	_, zeroPrice := service.UpdateItem(ctx, uuid.New(), "cup", dto.UpdateMerchRequest{Price: &zero})
+	_, badRename := service.UpdateItem(ctx, uuid.New(), "cup", dto.UpdateMerchRequest{Name: &spaced})
+
+	// Assert
+	assert.ErrorIs(t, emptyName, services.ErrInvalidMerchName)
+	assert.ErrorIs(t, slashName, services.ErrInvalidMerchName)
+	assert.ErrorIs(t, longName, services.ErrInvalidMerchName)
+	assert.ErrorIs(t, freeItem, services.ErrInvalidPrice)
+	assert.ErrorIs(t, emptyUpdate, services.ErrEmptyUpdate)
+	assert.ErrorIs(t, zeroPrice, services.ErrInvalidPrice)
+	assert.ErrorIs(t, badRename, services.ErrInvalidMerchName)
+	repo.AssertNotCalled(t, "CreateMerchItem", mock.Anything, mock.Anything, mock.Anything)
+	repo.AssertNotCalled(t, "UpdateMerchItem", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	cache.AssertNotCalled(t, "InvalidateCache")
+}
+
+func TestCatalogService_UnknownItemIsNotFound(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	price := 700
+
+	repo := new(mocks.CatalogRepositoryMock)
+	repo.On("UpdateMerchItem", ctx, mock.Anything, "ghost", mock.Anything).
+		Return(dto.MerchDTO{}, repository.ErrItemNotFound).Once()
+	repo.On("SetMerchItemArchived", ctx, "ghost", true).
+		Return(dto.MerchDTO{}, repository.ErrItemNotFound).Once()
+	cache := new(mocks.CatalogCacheMock)
+
+	service := services.NewCatalogService(slog.Default(), repo, cache)
+
+	// Act
+	_, updateErr := service.UpdateItem(ctx, uuid.New(), "ghost", dto.UpdateMerchRequest{Price: &price})
+	_, archiveErr := service.ArchiveItem(ctx, uuid.New(), "ghost")
+
+	// Assert
+	assert.ErrorIs(t, updateErr, services.ErrMerchNotFound)
+	assert.ErrorIs(t, archiveErr, services.ErrMerchNotFound)
+	cache.AssertNotCalled(t, "InvalidateCache")
+}
+
+func TestCatalogService_SetLimits_ValidatesAndInvalidatesCache(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	stock, negative, zero := 10, -1, 0
+	startsAt := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	endsAt := startsAt.Add(24 * time.Hour)
+	limits := dto.MerchLimits{Stock: &stock, SaleStartsAt: &startsAt, SaleEndsAt: &endsAt}
+
+	repo := new(mocks.CatalogRepositoryMock)
+	repo.On("SetMerchItemLimits", ctx, "cup", limits).
+		Return(dto.MerchDTO{Name: "cup", MerchLimits: limits}, nil).Once()
+	cache := new(mocks.CatalogCacheMock)
+	cache.On("InvalidateCache").Once()
+
+	service := services.NewCatalogService(slog.Default(), repo, cache)
+
+	// Act
+	item, err := service.SetLimits(ctx, uuid.New(), "cup", limits)
+	_, negativeStock := service.SetLimits(ctx, uuid.New(), "cup", dto.MerchLimits{Stock: &negative})
+	_, zeroLimit := service.SetLimits(ctx, uuid.New(), "cup", dto.MerchLimits{PerUserLimit: &zero})
+	_, reversedWindow := service.SetLimits(ctx, uuid.New(), "cup",
+		dto.MerchLimits{SaleStartsAt: &endsAt, SaleEndsAt: &startsAt})
+	_, createErr := service.CreateItem(ctx, uuid.New(), dto.CreateMerchRequest{
+		Name: "badge", Price: 100, MerchLimits: dto.MerchLimits{Stock: &negative},
+	})
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, limits, item.MerchLimits)
+	assert.ErrorIs(t, negativeStock, services.ErrInvalidStock)
+	assert.ErrorIs(t, zeroLimit, services.ErrInvalidPurchaseLimit)
+	assert.ErrorIs(t, reversedWindow, services.ErrInvalidSaleWindow)
+	assert.ErrorIs(t, createErr, services.ErrInvalidStock)
+	repo.AssertExpectations(t)
+	repo.AssertNotCalled(t, "CreateMerchItem", mock.Anything, mock.Anything, mock.Anything)
+	cache.AssertExpectations(t)
+}
@@ -0,0 +1,69 @@
+package unit
+
+import (
+	"avito-shop/internal/domain/dto"
+	"avito-shop/internal/handlers"
+	"avito-shop/internal/middlewares"
+	"avito-shop/internal/repository"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func serveError(t *testing.T, err error) (int, dto.ErrorResponse) {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middlewares.ErrorHandler(handlers.ErrorMappings...))
+	router.GET("/", func(c *gin.Context) {
+		_ = c.Error(err)
+	})
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	var body dto.ErrorResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	return rec.Code, body
+}
+
+func TestErrorHandler_MapsWrappedSentinelErrors(t *testing.T) {
+	cases := []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{repository.ErrInsufficientFunds, http.StatusBadRequest, "insufficient_funds"},
+		{repository.ErrItemNotFound, http.StatusBadRequest, "item_not_found"},
+		{repository.ErrRecipientNotFound, http.StatusBadRequest, "recipient_not_found"},
+		{repository.ErrSelfTransfer, http.StatusBadRequest, "self_transfer"},
+		{repository.ErrUserNotFound, http.StatusNotFound, "user_not_found"},
+	}
+
+	for _, tc := range cases {
+		wrapped := fmt.Errorf("services.UserService.BuyItem: %w",
+			fmt.Errorf("storage.Postgres.BuyItem: %w", tc.err))
+
+		status, body := serveError(t, wrapped)
+
+		assert.Equal(t, tc.status, status)
+		assert.Equal(t, tc.code, body.Code)
+		assert.Equal(t, tc.err.Error(), body.Errors, "внутренние op не должны попадать в ответ")
+	}
+}
+
+func TestErrorHandler_HidesUnknownErrors(t *testing.T) {
+	status, body := serveError(t, errors.New("storage.Postgres.GetUserById: connection refused"))
+
+	assert.Equal(t, http.StatusInternalServerError, status)
+	assert.Equal(t, middlewares.CodeInternalError, body.Code)
+	assert.NotContains(t, body.Errors, "connection refused")
+}
@@ -0,0 +1,68 @@
+package unit
+
+import (
+	"avito-shop/internal/lib/hasher"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var testArgon2idParams = hasher.Argon2idParams{Memory: 1024, Iterations: 1, Parallelism: 1}
+
+func TestArgon2idHasher_HashAndCompare(t *testing.T) {
+	h, err := hasher.NewArgon2id(testArgon2idParams)
+	require.NoError(t, err)
+
+	hash, err := h.Hash("password123")
+	require.NoError(t, err)
+	assert.Contains(t, string(hash), "$argon2id$v=19$m=1024,t=1,p=1$")
+
+	require.NoError(t, h.Compare(hash, "password123"))
+	assert.ErrorIs(t, h.Compare(hash, "wrongPassword"), hasher.ErrMismatch)
+	assert.False(t, h.NeedsRehash(hash))
+}
+
+func TestHasher_ComparesHashesOfOtherAlgorithm(t *testing.T) {
+	bcryptHasher, err := hasher.NewBcrypt(bcrypt.MinCost)
+	require.NoError(t, err)
+	argonHasher, err := hasher.NewArgon2id(testArgon2idParams)
+	require.NoError(t, err)
+
+	bcryptHash, err := bcryptHasher.Hash("password123")
+	require.NoError(t, err)
+	argonHash, err := argonHasher.Hash("password123")
+	require.NoError(t, err)
+
+	require.NoError(t, argonHasher.Compare(bcryptHash, "password123"))
+	require.NoError(t, bcryptHasher.Compare(argonHash, "password123"))
+	assert.ErrorIs(t, bcryptHasher.Compare([]byte("plain"), "plain"), hasher.ErrUnknownFormat)
+}
+
+func TestHasher_NeedsRehash(t *testing.T) {
+	weakBcrypt, err := hasher.NewBcrypt(bcrypt.MinCost)
+	require.NoError(t, err)
+	strongBcrypt, err := hasher.NewBcrypt(bcrypt.MinCost + 1)
+	require.NoError(t, err)
+	argonHasher, err := hasher.NewArgon2id(testArgon2idParams)
+	require.NoError(t, err)
+	strongerArgon, err := hasher.NewArgon2id(hasher.Argon2idParams{Memory: 2048, Iterations: 1, Parallelism: 1})
+	require.NoError(t, err)
+
+	weakHash, err := weakBcrypt.Hash("password123")
+	require.NoError(t, err)
+	argonHash, err := argonHasher.Hash("password123")
+	require.NoError(t, err)
+
+	assert.True(t, strongBcrypt.NeedsRehash(weakHash), "bcrypt с меньшей стоимостью")
+	assert.False(t, weakBcrypt.NeedsRehash(weakHash))
+	assert.True(t, argonHasher.NeedsRehash(weakHash), "смена алгоритма")
+	assert.True(t, weakBcrypt.NeedsRehash(argonHash), "смена алгоритма")
+	assert.True(t, strongerArgon.NeedsRehash(argonHash), "argon2id с другими параметрами")
+}
+
+func TestHasher_NewRejectsUnknownAlgorithm(t *testing.T) {
+	_, err := hasher.New(hasher.Config{Algorithm: "md5"})
+	assert.ErrorIs(t, err, hasher.ErrUnknownAlgorithm)
+}
@@ -0,0 +1,183 @@
+package unit
+
+import (
+	"avito-shop/internal/domain/dto"
+	"avito-shop/internal/domain/models"
+	"avito-shop/internal/handlers"
+	"avito-shop/internal/middlewares"
+	"avito-shop/internal/tests/mocks"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const testIdempotencyTTL = time.Hour
+
+func newIdempotencyRouter(store *mocks.IdempotencyStoreMock, handler gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middlewares.ErrorHandler(handlers.ErrorMappings...))
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", "user-1")
+	})
+
+	idempotency := middlewares.NewIdempotencyMiddleware(slog.Default(), store, testIdempotencyTTL)
+	router.POST("/send", idempotency.Handle(), handler)
+	return router
+}
+
+func serveIdempotent(router *gin.Engine, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/send", strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(middlewares.IdempotencyKeyHeader, key)
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestIdempotencyMiddleware_StoresSuccessfulResponse(t *testing.T) {
+	// Arrange
+	store := new(mocks.IdempotencyStoreMock)
+	store.On("ReserveIdempotencyKey", mock.Anything, "user-1", "key-1", mock.AnythingOfType("string"), testIdempotencyTTL).
+		Return(models.IdempotencyKey{}, true, nil).Once()
+	store.On("CompleteIdempotencyKey", mock.Anything, "user-1", "key-1", http.StatusCreated, []byte(`{"ok":true}`)).
+		Return(nil).Once()
+
+	router := newIdempotencyRouter(store, func(c *gin.Context) {
+		c.Data(http.StatusCreated, "application/json", []byte(`{"ok":true}`))
+	})
+
+	// Act
+	rec := serveIdempotent(router, "key-1", `{"amount":1}`)
+
+	// Assert
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	store.AssertExpectations(t)
+}
+
+func TestIdempotencyMiddleware_ReplaysStoredResponse(t *testing.T) {
+	// Arrange
+	store := new(mocks.IdempotencyStoreMock)
+	handlerCalls := 0
+	router := newIdempotencyRouter(store, func(c *gin.Context) {
+		handlerCalls++
+		c.Status(http.StatusOK)
+	})
+
+	var firstHash string
+	store.On("ReserveIdempotencyKey", mock.Anything, "user-1", "key-1", mock.AnythingOfType("string"), testIdempotencyTTL).
+		Run(func(args mock.Arguments) { firstHash = args.String(3) }).
+		Return(models.IdempotencyKey{}, true, nil).Once()
+	store.On("CompleteIdempotencyKey", mock.Anything, "user-1", "key-1", http.StatusOK, mock.Anything).
+		Return(nil).Once()
+	serveIdempotent(router, "key-1", `{"amount":1}`)
+
+	store.On("ReserveIdempotencyKey", mock.Anything, "user-1", "key-1", firstHash, testIdempotencyTTL).
+		Return(models.IdempotencyKey{RequestHash: firstHash, StatusCode: http.StatusOK, ResponseBody: []byte(`{"done":1}`)},
+			false, nil).Once()
+
+	// Act
+	rec := serveIdempotent(router, "key-1", `{"amount":1}`)
+
+	// Assert
+	assert.Equal(t, 1, handlerCalls)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `{"done":1}`, rec.Body.String())
+	assert.Equal(t, "true", rec.Header().Get(middlewares.IdempotentReplayedHeader))
+	store.AssertExpectations(t)
+}
+
+func TestIdempotencyMiddleware_RejectsInFlightAndConflictingRequests(t *testing.T) {
+	body := `{"amount":1}`
+	sum := sha256.Sum256([]byte("POST\n/send\n" + body))
+	hash := hex.EncodeToString(sum[:])
+
+	cases := []struct {
+		name   string
+		record models.IdempotencyKey
+		status int
+		code   string
+	}{
+		{"in flight", models.IdempotencyKey{RequestHash: hash}, http.StatusConflict, "idempotency_key_in_use"},
+		{"other payload", models.IdempotencyKey{RequestHash: "other", StatusCode: http.StatusOK},
+			http.StatusUnprocessableEntity, "idempotency_key_reused"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			store := new(mocks.IdempotencyStoreMock)
+			store.On("ReserveIdempotencyKey", mock.Anything, "user-1", "key-1", hash, testIdempotencyTTL).
+				Return(tc.record, false, nil).Once()
+
+			router := newIdempotencyRouter(store, func(c *gin.Context) {
+				t.Error("handler must not be called")
+			})
+
+			// Act
+			rec := serveIdempotent(router, "key-1", body)
+
+			// Assert
+			require.Equal(t, tc.status, rec.Code)
+			var resp dto.ErrorResponse
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			assert.Equal(t, tc.code, resp.Code)
+			store.AssertExpectations(t)
+		})
+	}
+}
+
+func TestIdempotencyMiddleware_ReleasesKeyWhenHandlerFails(t *testing.T) {
+	// Arrange
+	store := new(mocks.IdempotencyStoreMock)
+	store.On("ReserveIdempotencyKey", mock.Anything, "user-1", "key-1", mock.AnythingOfType("string"), testIdempotencyTTL).
+		Return(models.IdempotencyKey{}, true, nil).Once()
+	store.On("ReleaseIdempotencyKey", mock.Anything, "user-1", "key-1").
+		Return(nil).Once()
+
+	router := newIdempotencyRouter(store, func(c *gin.Context) {
+		_ = c.Error(errors.New("boom"))
+	})
+
+	// Act
+	rec := serveIdempotent(router, "key-1", `{}`)
+
+	// Assert
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	store.AssertExpectations(t)
+	store.AssertNotCalled(t, "CompleteIdempotencyKey", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestIdempotencyMiddleware_PassesThroughWithoutKey(t *testing.T) {
+	// Arrange
+	store := new(mocks.IdempotencyStoreMock)
+	router := newIdempotencyRouter(store, func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	// Act
+	rec := serveIdempotent(router, "", `{}`)
+	invalid := serveIdempotent(router, strings.Repeat("k", 256), `{}`)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, http.StatusBadRequest, invalid.Code)
+	var body dto.ErrorResponse
+	require.NoError(t, json.Unmarshal(invalid.Body.Bytes(), &body))
+	assert.Equal(t, "invalid_idempotency_key", body.Code)
+	store.AssertNotCalled(t, "ReserveIdempotencyKey", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
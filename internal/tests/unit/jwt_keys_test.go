@@ -0,0 +1,235 @@
+package unit
+
+import (
+	"avito-shop/internal/lib/jwt"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeySet_SignsWithNewestActiveKeyAndVerifiesRotatedTokens(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+	writeRSAKey(t, dir, "2026-01", time.Now().Add(-time.Hour))
+
+	keys, err := jwt.LoadKeySet(dir, time.Hour)
+	require.NoError(t, err)
+	jwtGen := jwt.NewGenerator("", time.Minute, time.Hour, jwt.WithKeySet(keys))
+
+	oldAccess, _, err := jwtGen.GeneratePair("user-id", "family-id", "user")
+	require.NoError(t, err)
+
+	writeEd25519Key(t, dir, "2026-02", time.Now().Add(-time.Minute))
+	require.NoError(t, keys.Reload())
+
+	// Act
+	newAccess, _, err := jwtGen.GeneratePair("user-id", "family-id", "user")
+	require.NoError(t, err)
+	_, oldErr := jwtGen.ParseAccess(oldAccess)
+	_, newErr := jwtGen.ParseAccess(newAccess)
+	published, err := keys.SigningKey(time.Now().Add(jwt.JWKSMaxAge))
+	require.NoError(t, err)
+
+	// Assert
+	assert.NoError(t, oldErr)
+	assert.NoError(t, newErr)
+	assert.Equal(t, "2026-01", tokenKid(t, newAccess), "new key must not sign before JWKS caches expire")
+	assert.Equal(t, "2026-02", published.ID)
+
+	jwks := jwtGen.JWKS()
+	require.Len(t, jwks.Keys, 2)
+	assert.Equal(t, "RSA", jwks.Keys[0].Kty)
+	assert.Equal(t, "RS256", jwks.Keys[0].Alg)
+	assert.Equal(t, "OKP", jwks.Keys[1].Kty)
+	assert.Equal(t, "EdDSA", jwks.Keys[1].Alg)
+}
+
+func TestKeySet_ScheduledKeyIsNotUsedBeforeActivation(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+	writeEd25519Key(t, dir, "current", time.Now().Add(-time.Hour))
+	writeEd25519Key(t, dir, "next", time.Now().Add(time.Hour))
+
+	keys, err := jwt.LoadKeySet(dir, time.Hour)
+	require.NoError(t, err)
+
+	// Act
+	active, err := keys.SigningKey(time.Now())
+	require.NoError(t, err)
+	later, err := keys.SigningKey(time.Now().Add(2 * time.Hour))
+	require.NoError(t, err)
+
+	// Assert
+	assert.Equal(t, "current", active.ID)
+	assert.Equal(t, "next", later.ID)
+	assert.Len(t, keys.JWKS().Keys, 2)
+}
+
+func TestKeySet_ActivationIgnoresFileModificationTime(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+	writeEd25519Key(t, dir, "current", time.Now().Add(-time.Hour))
+	writeEd25519Key(t, dir, "next", time.Now().Add(time.Hour))
+
+	// cp и rsync могут выставить файлам любое время модификации
+	for _, kid := range []string{"current", "next"} {
+		path := filepath.Join(dir, kid+".pem")
+		require.NoError(t, os.Chtimes(path, time.Now().Add(-48*time.Hour), time.Now().Add(-48*time.Hour)))
+	}
+
+	keys, err := jwt.LoadKeySet(dir, time.Hour)
+	require.NoError(t, err)
+
+	// Act
+	active, err := keys.SigningKey(time.Now())
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, "current", active.ID)
+}
+
+func TestKeySet_RejectsInvalidActivationTime(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+	writeEd25519Key(t, dir, "current", time.Now())
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "current.active"), []byte("tomorrow"), 0o600))
+
+	// Act
+	_, err := jwt.LoadKeySet(dir, time.Hour)
+
+	// Assert
+	assert.Error(t, err)
+}
+
+func TestKeySet_RemovedKeyStillVerifiesWithinRetention(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+	writeEd25519Key(t, dir, "old", time.Now().Add(-time.Hour))
+
+	keys, err := jwt.LoadKeySet(dir, time.Hour)
+	require.NoError(t, err)
+	jwtGen := jwt.NewGenerator("", time.Minute, time.Hour, jwt.WithKeySet(keys))
+
+	access, _, err := jwtGen.GeneratePair("user-id", "family-id", "user")
+	require.NoError(t, err)
+
+	writeEd25519Key(t, dir, "new", time.Now().Add(-time.Minute))
+	require.NoError(t, os.Remove(filepath.Join(dir, "old.pem")))
+	require.NoError(t, keys.Reload())
+
+	// Act
+	_, err = jwtGen.ParseAccess(access)
+	active, signErr := keys.SigningKey(time.Now())
+
+	// Assert
+	assert.NoError(t, err)
+	require.NoError(t, signErr)
+	assert.Equal(t, "new", active.ID)
+}
+
+func TestGenerator_AcceptsLegacyHMACTokensAfterSwitchingToKeySet(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+	writeRSAKey(t, dir, "rsa", time.Now().Add(-time.Minute))
+
+	keys, err := jwt.LoadKeySet(dir, time.Hour)
+	require.NoError(t, err)
+
+	legacy := jwt.NewGenerator("secret", time.Minute, time.Hour)
+	legacyAccess, _, err := legacy.GeneratePair("user-id", "family-id", "user")
+	require.NoError(t, err)
+
+	inTransition := jwt.NewGenerator("secret", time.Minute, time.Hour, jwt.WithKeySet(keys),
+		jwt.WithLegacySecretUntil(time.Now().Add(time.Hour)))
+	withoutSecret := jwt.NewGenerator("", time.Minute, time.Hour, jwt.WithKeySet(keys),
+		jwt.WithLegacySecretUntil(time.Now().Add(time.Hour)))
+
+	// Act
+	_, transitionErr := inTransition.ParseAccess(legacyAccess)
+	_, strictErr := withoutSecret.ParseAccess(legacyAccess)
+
+	// Assert
+	assert.NoError(t, transitionErr)
+	assert.ErrorIs(t, strictErr, jwt.ErrTokenInvalid)
+}
+
+func TestGenerator_RejectsLegacyHMACTokensOutsideTransition(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+	writeRSAKey(t, dir, "rsa", time.Now().Add(-time.Minute))
+
+	keys, err := jwt.LoadKeySet(dir, time.Hour)
+	require.NoError(t, err)
+
+	// токен подделан тем, у кого остался общий секрет
+	forger := jwt.NewGenerator("secret", time.Minute, time.Hour)
+	forged, _, err := forger.GeneratePair("user-id", "family-id", "admin")
+	require.NoError(t, err)
+
+	noTransition := jwt.NewGenerator("secret", time.Minute, time.Hour, jwt.WithKeySet(keys))
+	transitionOver := jwt.NewGenerator("secret", time.Minute, time.Hour, jwt.WithKeySet(keys),
+		jwt.WithLegacySecretUntil(time.Now().Add(-time.Second)))
+
+	// Act
+	_, noTransitionErr := noTransition.ParseAccess(forged)
+	_, transitionOverErr := transitionOver.ParseAccess(forged)
+
+	// Assert
+	assert.ErrorIs(t, noTransitionErr, jwt.ErrTokenInvalid)
+	assert.ErrorIs(t, transitionOverErr, jwt.ErrTokenInvalid)
+}
+
+func writeRSAKey(t *testing.T, dir, kid string, activeAt time.Time) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	writePKCS8(t, dir, kid, key, activeAt)
+}
+
+func writeEd25519Key(t *testing.T, dir, kid string, activeAt time.Time) {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	writePKCS8(t, dir, kid, key, activeAt)
+}
+
+func writePKCS8(t *testing.T, dir, kid string, key any, activeAt time.Time) {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	path := filepath.Join(dir, kid+".pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, kid+".active"), []byte(activeAt.Format(time.RFC3339)), 0o600))
+}
+
+func tokenKid(t *testing.T, token string) string {
+	t.Helper()
+	header := strings.Split(token, ".")[0]
+	decoded, err := jwtSegment(header)
+	require.NoError(t, err)
+	return decoded["kid"].(string)
+}
+
+func jwtSegment(segment string) (map[string]any, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return nil, err
+	}
+
+	var decoded map[string]any
+	err = json.Unmarshal(raw, &decoded)
+	return decoded, err
+}
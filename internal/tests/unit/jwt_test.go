@@ -0,0 +1,109 @@
+package unit
+
+import (
+	"avito-shop/internal/lib/jwt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerator_ParseAccess_ReturnsClaims(t *testing.T) {
+	// Arrange
+	jwtGen := jwt.NewGenerator("secret", time.Minute, time.Hour)
+	access, _, err := jwtGen.GeneratePair("user-id", "family-id", "user")
+	require.NoError(t, err)
+
+	// Act
+	claims, err := jwtGen.ParseAccess(access)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, "user-id", claims.Subject)
+	assert.Equal(t, jwt.TokenTypeAccess, claims.Type)
+	assert.NotEmpty(t, claims.ID)
+}
+
+func TestGenerator_ParseAccess_RejectsRefreshToken(t *testing.T) {
+	// Arrange
+	jwtGen := jwt.NewGenerator("secret", time.Minute, time.Hour)
+	_, refresh, err := jwtGen.GeneratePair("user-id", "family-id", "user")
+	require.NoError(t, err)
+
+	// Act
+	_, err = jwtGen.ParseAccess(refresh)
+
+	// Assert
+	assert.ErrorIs(t, err, jwt.ErrWrongTokenType)
+}
+
+func TestGenerator_ParseRefresh_RejectsAccessToken(t *testing.T) {
+	// Arrange
+	jwtGen := jwt.NewGenerator("secret", time.Minute, time.Hour)
+	access, _, err := jwtGen.GeneratePair("user-id", "family-id", "user")
+	require.NoError(t, err)
+
+	// Act
+	_, err = jwtGen.ParseRefresh(access)
+
+	// Assert
+	assert.ErrorIs(t, err, jwt.ErrWrongTokenType)
+}
+
+func TestGenerator_ParseAccess_ReturnsExpiredError(t *testing.T) {
+	// Arrange
+	jwtGen := jwt.NewGenerator("secret", -time.Minute, time.Hour)
+	access, _, err := jwtGen.GeneratePair("user-id", "family-id", "user")
+	require.NoError(t, err)
+
+	// Act
+	_, err = jwtGen.ParseAccess(access)
+
+	// Assert
+	assert.ErrorIs(t, err, jwt.ErrTokenExpired)
+}
+
+func TestGenerator_ParseAccess_AcceptsExpiredTokenWithinLeeway(t *testing.T) {
+	// Arrange
+	jwtGen := jwt.NewGenerator("secret", -5*time.Second, time.Hour, jwt.WithLeeway(time.Minute))
+	access, _, err := jwtGen.GeneratePair("user-id", "family-id", "user")
+	require.NoError(t, err)
+
+	// Act
+	_, err = jwtGen.ParseAccess(access)
+
+	// Assert
+	assert.NoError(t, err)
+}
+
+func TestGenerator_ParseAccess_RejectsForeignAudienceAndIssuer(t *testing.T) {
+	// Arrange
+	issuer := jwt.NewGenerator("secret", time.Minute, time.Hour, jwt.WithAudience("billing"))
+	otherIssuer := jwt.NewGenerator("secret", time.Minute, time.Hour, jwt.WithIssuer("someone-else"))
+	verifier := jwt.NewGenerator("secret", time.Minute, time.Hour)
+
+	foreignAudience, _, err := issuer.GeneratePair("user-id", "family-id", "user")
+	require.NoError(t, err)
+	foreignIssuer, _, err := otherIssuer.GeneratePair("user-id", "family-id", "user")
+	require.NoError(t, err)
+
+	// Act
+	_, audErr := verifier.ParseAccess(foreignAudience)
+	_, issErr := verifier.ParseAccess(foreignIssuer)
+
+	// Assert
+	assert.ErrorIs(t, audErr, jwt.ErrTokenInvalid)
+	assert.ErrorIs(t, issErr, jwt.ErrTokenInvalid)
+}
+
+func TestGenerator_ParseAccess_ReturnsMalformedError(t *testing.T) {
+	// Arrange
+	jwtGen := jwt.NewGenerator("secret", time.Minute, time.Hour)
+
+	// Act
+	_, err := jwtGen.ParseAccess("not-a-jwt")
+
+	// Assert
+	assert.ErrorIs(t, err, jwt.ErrTokenMalformed)
+}
@@ -0,0 +1,215 @@
+package unit
+
+import (
+	"avito-shop/internal/repository"
+	"avito-shop/internal/services"
+	"avito-shop/internal/tests/mocks"
+	"context"
+	"testing"
+	"time"
+
+	"log/slog"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestLoginLimiter(store *mocks.LoginAttemptStoreMock) *services.LoginLimiter {
+	resolver := new(mocks.LoginIdentityResolverMock)
+	resolver.On("ResolveLoginIdentity", mock.Anything, "alice").Return("alice-id", nil).Maybe()
+	resolver.On("ResolveLoginIdentity", mock.Anything, "Alice").Return("alice-id", nil).Maybe()
+	resolver.On("ResolveLoginIdentity", mock.Anything, "alice@example.com").Return("alice-id", nil).Maybe()
+	resolver.On("ResolveLoginIdentity", mock.Anything, "Ghost").Return("", repository.ErrUserNotFound).Maybe()
+
+	return services.NewLoginLimiter(slog.Default(), store, resolver, services.LoginLimiterConfig{
+		MaxAttempts:      3,
+		MaxAttemptsPerIP: 10,
+		BaseLockout:      time.Second,
+		MaxLockout:       5 * time.Second,
+		Window:           time.Hour,
+	})
+}
+
+func identify(t *testing.T, limiter *services.LoginLimiter, login string) services.LoginIdentity {
+	t.Helper()
+	identity, err := limiter.Identify(context.Background(), login)
+	require.NoError(t, err)
+	return identity
+}
+
+func TestLoginLimiter_Fail_DoesNotLockBelowThreshold(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	store := new(mocks.LoginAttemptStoreMock)
+	limiter := newTestLoginLimiter(store)
+
+	store.On("RegisterLoginFailure", "user:alice-id", time.Hour).Return(int64(2), nil).Once()
+	store.On("RegisterLoginFailure", "ip:10.0.0.1", time.Hour).Return(int64(2), nil).Once()
+
+	// Act
+	err := limiter.Fail(ctx, identify(t, limiter, "Alice"), "10.0.0.1")
+
+	// Assert
+	require.NoError(t, err)
+	store.AssertNotCalled(t, "LockLogin", mock.Anything, mock.Anything)
+}
+
+func TestLoginLimiter_Fail_LocksWithExponentialBackoff(t *testing.T) {
+	cases := []struct {
+		failures int64
+		lockout  time.Duration
+	}{
+		{failures: 3, lockout: time.Second},
+		{failures: 4, lockout: 2 * time.Second},
+		{failures: 5, lockout: 4 * time.Second},
+		{failures: 6, lockout: 5 * time.Second},
+		{failures: 60, lockout: 5 * time.Second},
+	}
+
+	for _, tc := range cases {
+		// Arrange
+		ctx := context.Background()
+		store := new(mocks.LoginAttemptStoreMock)
+		limiter := newTestLoginLimiter(store)
+
+		store.On("RegisterLoginFailure", "user:alice-id", time.Hour).Return(tc.failures, nil).Once()
+		store.On("RegisterLoginFailure", "ip:10.0.0.1", time.Hour).Return(int64(1), nil).Once()
+		store.On("LockLogin", "user:alice-id", tc.lockout).Return(nil).Once()
+
+		// Act
+		err := limiter.Fail(ctx, identify(t, limiter, "alice"), "10.0.0.1")
+
+		// Assert
+		require.NoError(t, err)
+		store.AssertExpectations(t)
+	}
+}
+
+func TestLoginLimiter_Check_ReturnsLongestLockout(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	store := new(mocks.LoginAttemptStoreMock)
+	limiter := newTestLoginLimiter(store)
+
+	store.On("LoginLockTTL", "user:alice-id").Return(2*time.Second, nil).Once()
+	store.On("LoginLockTTL", "ip:10.0.0.1").Return(7*time.Second, nil).Once()
+
+	// Act
+	err := limiter.Check(ctx, identify(t, limiter, "alice"), "10.0.0.1")
+
+	// Assert
+	var lockout *services.LockoutError
+	require.ErrorAs(t, err, &lockout)
+	assert.ErrorIs(t, err, services.ErrTooManyLoginAttempts)
+	assert.Equal(t, 7*time.Second, lockout.RetryAfter)
+}
+
+func TestLoginLimiter_Succeed_ResetsOnlyUsernameCounter(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	store := new(mocks.LoginAttemptStoreMock)
+	limiter := newTestLoginLimiter(store)
+
+	store.On("ResetLoginFailures", "user:alice-id").Return(nil).Once()
+
+	// Act
+	err := limiter.Succeed(ctx, identify(t, limiter, "alice"))
+
+	// Assert
+	require.NoError(t, err)
+	store.AssertExpectations(t)
+}
+
+func TestLoginLimiter_Fail_SharesCounterBetweenUsernameAndEmail(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	store := new(mocks.LoginAttemptStoreMock)
+	limiter := newTestLoginLimiter(store)
+
+	store.On("RegisterLoginFailure", "user:alice-id", time.Hour).Return(int64(2), nil).Once()
+	store.On("RegisterLoginFailure", "user:alice-id", time.Hour).Return(int64(3), nil).Once()
+	store.On("RegisterLoginFailure", "ip:10.0.0.1", time.Hour).Return(int64(1), nil).Twice()
+	store.On("LockLogin", "user:alice-id", time.Second).Return(nil).Once()
+
+	// Act
+	usernameErr := limiter.Fail(ctx, identify(t, limiter, "alice"), "10.0.0.1")
+	emailErr := limiter.Fail(ctx, identify(t, limiter, "alice@example.com"), "10.0.0.1")
+
+	// Assert
+	require.NoError(t, usernameErr)
+	require.NoError(t, emailErr)
+	store.AssertExpectations(t)
+}
+
+func TestLoginLimiter_Fail_CountsUnknownLoginByNormalizedName(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	store := new(mocks.LoginAttemptStoreMock)
+	limiter := newTestLoginLimiter(store)
+
+	store.On("RegisterLoginFailure", "login:ghost", time.Hour).Return(int64(1), nil).Once()
+	store.On("RegisterLoginFailure", "ip:10.0.0.1", time.Hour).Return(int64(1), nil).Once()
+
+	// Act
+	err := limiter.Fail(ctx, identify(t, limiter, "Ghost"), "10.0.0.1")
+
+	// Assert
+	require.NoError(t, err)
+	store.AssertExpectations(t)
+}
+
+func TestLoginLimiter_ResolvesIdentityOncePerRequest(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	store := new(mocks.LoginAttemptStoreMock)
+	resolver := new(mocks.LoginIdentityResolverMock)
+	limiter := services.NewLoginLimiter(slog.Default(), store, resolver, services.LoginLimiterConfig{
+		MaxAttempts:      3,
+		MaxAttemptsPerIP: 10,
+		BaseLockout:      time.Second,
+		MaxLockout:       5 * time.Second,
+		Window:           time.Hour,
+	})
+
+	resolver.On("ResolveLoginIdentity", ctx, "alice").Return("alice-id", nil).Once()
+	store.On("LoginLockTTL", mock.Anything).Return(time.Duration(0), nil)
+	store.On("RegisterLoginFailure", mock.Anything, time.Hour).Return(int64(1), nil)
+	store.On("ResetLoginFailures", "user:alice-id").Return(nil).Once()
+
+	// Act
+	identity, err := limiter.Identify(ctx, "alice")
+	require.NoError(t, err)
+	checkErr := limiter.Check(ctx, identity, "10.0.0.1")
+	failErr := limiter.Fail(ctx, identity, "10.0.0.1")
+	succeedErr := limiter.Succeed(ctx, identity)
+
+	// Assert
+	require.NoError(t, checkErr)
+	require.NoError(t, failErr)
+	require.NoError(t, succeedErr)
+	resolver.AssertNumberOfCalls(t, "ResolveLoginIdentity", 1)
+	store.AssertExpectations(t)
+}
+
+func TestLoginLimiter_Fail_NeverLocksWithoutExpiry(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	store := new(mocks.LoginAttemptStoreMock)
+	resolver := new(mocks.LoginIdentityResolverMock)
+	limiter := services.NewLoginLimiter(slog.Default(), store, resolver, services.LoginLimiterConfig{
+		MaxAttempts:      1,
+		MaxAttemptsPerIP: 1,
+		Window:           time.Hour,
+	})
+
+	resolver.On("ResolveLoginIdentity", ctx, "alice").Return("alice-id", nil).Once()
+	store.On("RegisterLoginFailure", mock.Anything, time.Hour).Return(int64(5), nil)
+
+	// Act
+	err := limiter.Fail(ctx, identify(t, limiter, "alice"), "10.0.0.1")
+
+	// Assert
+	require.NoError(t, err)
+	store.AssertNotCalled(t, "LockLogin", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,98 @@
+package unit
+
+import (
+	"avito-shop/internal/domain/dto"
+	"avito-shop/internal/services"
+	"avito-shop/internal/tests/mocks"
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testCatalog = []dto.MerchDTO{
+	{Name: "cup", Price: 2000, Available: true, Description: "Кружка"},
+	{Name: "pen", Price: 1000, Available: false, Description: "Ручка"},
+}
+
+func TestMerchService_CachesCatalog(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	repo := new(mocks.MerchRepositoryMock)
+	repo.On("GetMerchItems", ctx).Return(testCatalog, nil).Once()
+
+	service := services.NewMerchService(slog.Default(), repo, time.Minute)
+
+	// Act
+	items, err := service.ListItems(ctx)
+	require.NoError(t, err)
+	item, err := service.GetItem(ctx, "pen")
+	require.NoError(t, err)
+
+	// Assert
+	assert.Equal(t, testCatalog, items)
+	assert.Equal(t, testCatalog[1], item)
+	repo.AssertExpectations(t)
+}
+
+func TestMerchService_InvalidateCacheReloadsCatalog(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	updated := []dto.MerchDTO{{Name: "cup", Price: 2500, Available: true}}
+
+	repo := new(mocks.MerchRepositoryMock)
+	repo.On("GetMerchItems", ctx).Return(testCatalog, nil).Once()
+	repo.On("GetMerchItems", ctx).Return(updated, nil).Once()
+
+	service := services.NewMerchService(slog.Default(), repo, time.Hour)
+	_, err := service.ListItems(ctx)
+	require.NoError(t, err)
+
+	// Act
+	service.InvalidateCache()
+	item, err := service.GetItem(ctx, "cup")
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, 2500, item.Price)
+	repo.AssertExpectations(t)
+}
+
+func TestMerchService_GetItem_ReturnsNotFound(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	repo := new(mocks.MerchRepositoryMock)
+	repo.On("GetMerchItems", ctx).Return(testCatalog, nil).Once()
+
+	service := services.NewMerchService(slog.Default(), repo, time.Minute)
+
+	// Act
+	_, err := service.GetItem(ctx, "spaceship")
+
+	// Assert
+	assert.ErrorIs(t, err, services.ErrMerchNotFound)
+}
+
+func TestMerchService_DoesNotCacheErrors(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	repo := new(mocks.MerchRepositoryMock)
+	repo.On("GetMerchItems", ctx).Return([]dto.MerchDTO(nil), errors.New("db down")).Once()
+	repo.On("GetMerchItems", ctx).Return(testCatalog, nil).Once()
+
+	service := services.NewMerchService(slog.Default(), repo, time.Minute)
+
+	// Act
+	_, firstErr := service.ListItems(ctx)
+	items, secondErr := service.ListItems(ctx)
+
+	// Assert
+	assert.ErrorContains(t, firstErr, "db down")
+	require.NoError(t, secondErr)
+	assert.Len(t, items, 2)
+	repo.AssertExpectations(t)
+}
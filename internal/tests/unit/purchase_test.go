@@ -0,0 +1,61 @@
+package unit
+
+import (
+	"avito-shop/internal/domain/dto"
+	"avito-shop/internal/repository"
+	"avito-shop/internal/services"
+	"avito-shop/internal/tests/mocks"
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPurchaseService_ReturnPurchase_UsesConfiguredWindow(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	userID, purchaseID := uuid.New(), uuid.New()
+	returnedAt := time.Now()
+	want := dto.PurchaseRecordDTO{ID: purchaseID, Merch: "cup", Price: 2000, ReturnedAt: &returnedAt}
+
+	repo := new(mocks.PurchaseRepositoryMock)
+	repo.On("ReturnPurchase", ctx, userID, purchaseID, 48*time.Hour).Return(want, nil).Once()
+	cache := new(mocks.CatalogCacheMock)
+	cache.On("InvalidateCache").Once()
+
+	service := services.NewPurchaseService(slog.Default(), repo, 48*time.Hour, cache)
+
+	// Act
+	purchase, err := service.ReturnPurchase(ctx, userID, purchaseID)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, want, purchase)
+	repo.AssertExpectations(t)
+	cache.AssertExpectations(t)
+}
+
+func TestPurchaseService_ReturnPurchase_PropagatesRepositoryError(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	userID, purchaseID := uuid.New(), uuid.New()
+
+	repo := new(mocks.PurchaseRepositoryMock)
+	repo.On("ReturnPurchase", ctx, userID, purchaseID, time.Hour).
+		Return(dto.PurchaseRecordDTO{}, repository.ErrReturnWindowExpired).Once()
+	cache := new(mocks.CatalogCacheMock)
+
+	service := services.NewPurchaseService(slog.Default(), repo, time.Hour, cache)
+
+	// Act
+	_, err := service.ReturnPurchase(ctx, userID, purchaseID)
+
+	// Assert
+	assert.ErrorIs(t, err, repository.ErrReturnWindowExpired)
+	repo.AssertExpectations(t)
+	cache.AssertNotCalled(t, "InvalidateCache")
+}
@@ -0,0 +1,113 @@
+package unit
+
+import (
+	"avito-shop/internal/domain/dto"
+	"avito-shop/internal/services"
+	"avito-shop/internal/tests/mocks"
+	"context"
+	"errors"
+	"testing"
+
+	"log/slog"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const startingGrant = 100000
+
+func TestReconcileService_Reconcile_ReportsHistoryAndLedgerDrifts(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+
+	repo := new(mocks.ReconcileRepositoryMock)
+	service := services.NewReconcileService(slog.Default(), repo, startingGrant)
+
+	historyDrift := dto.BalanceDriftDTO{UserID: uuid.New(), Username: "bob", Coins: 99000, Expected: 98000,
+		Ledger: 99000, Drift: 1000}
+	// история сходится с users.coins, но журнал - нет
+	ledgerDrift := dto.BalanceDriftDTO{UserID: uuid.New(), Username: "carol", Coins: 700, Expected: 700,
+		Ledger: 500, LedgerDrift: 200}
+	repo.On("GetBalanceChecks", ctx, startingGrant).Return([]dto.BalanceDriftDTO{
+		{UserID: uuid.New(), Username: "alice", Coins: 100000, Expected: 100000, Ledger: 100000},
+		historyDrift,
+		ledgerDrift,
+	}, nil).Once()
+
+	// Act
+	report, err := service.Reconcile(ctx, false, "")
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, 3, report.Users)
+	assert.Equal(t, []dto.BalanceDriftDTO{historyDrift, ledgerDrift}, report.Drifts)
+	repo.AssertNotCalled(t, "CorrectBalance", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestReconcileService_Reconcile_CorrectsDriftsWhenFixing(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+
+	repo := new(mocks.ReconcileRepositoryMock)
+	service := services.NewReconcileService(slog.Default(), repo, startingGrant)
+
+	bobID, carolID := uuid.New(), uuid.New()
+	bob := dto.BalanceDriftDTO{UserID: bobID, Username: "bob", Coins: 99000, Expected: 98000, Ledger: 98000,
+		Drift: 1000, LedgerDrift: 1000}
+	carol := dto.BalanceDriftDTO{UserID: carolID, Username: "carol", Coins: 500, Expected: 700, Ledger: 500,
+		Drift: -200}
+	repo.On("GetBalanceChecks", ctx, startingGrant).Return([]dto.BalanceDriftDTO{bob, carol}, nil).Once()
+	repo.On("CorrectBalance", ctx, bobID, startingGrant, "incident 42").Return(bob, nil).Once()
+	// расхождение carol исправили между проверкой и исправлением
+	repo.On("CorrectBalance", ctx, carolID, startingGrant, "incident 42").
+		Return(dto.BalanceDriftDTO{UserID: carolID, Username: "carol", Coins: 700, Expected: 700, Ledger: 700}, nil).
+		Once()
+
+	// Act
+	report, err := service.Reconcile(ctx, true, " incident 42 ")
+
+	// Assert
+	require.NoError(t, err)
+	require.Len(t, report.Drifts, 2)
+	assert.True(t, report.Drifts[0].Corrected)
+	assert.Equal(t, 98000, report.Drifts[0].Ledger)
+	assert.False(t, report.Drifts[1].Corrected)
+	repo.AssertExpectations(t)
+}
+
+func TestReconcileService_Reconcile_RequiresReasonToFix(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+
+	repo := new(mocks.ReconcileRepositoryMock)
+	service := services.NewReconcileService(slog.Default(), repo, startingGrant)
+
+	// Act
+	_, err := service.Reconcile(ctx, true, "  ")
+
+	// Assert
+	assert.ErrorIs(t, err, services.ErrReasonRequired)
+	repo.AssertNotCalled(t, "GetBalanceChecks", mock.Anything, mock.Anything)
+}
+
+func TestReconcileService_Reconcile_PropagatesRepositoryErrors(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	repoErr := errors.New("connection reset")
+
+	repo := new(mocks.ReconcileRepositoryMock)
+	service := services.NewReconcileService(slog.Default(), repo, startingGrant)
+
+	userID := uuid.New()
+	repo.On("GetBalanceChecks", ctx, startingGrant).
+		Return([]dto.BalanceDriftDTO{{UserID: userID, Username: "bob", Coins: 1, Drift: 1}}, nil).Once()
+	repo.On("CorrectBalance", ctx, userID, startingGrant, "fix").Return(dto.BalanceDriftDTO{}, repoErr).Once()
+
+	// Act
+	_, err := service.Reconcile(ctx, true, "fix")
+
+	// Assert
+	assert.ErrorIs(t, err, repoErr)
+}
@@ -0,0 +1,117 @@
+package unit
+
+import (
+	"avito-shop/internal/lib/retry"
+	"avito-shop/internal/repository/postgres"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	errConflict = errors.New("conflict")
+	errFatal    = errors.New("fatal")
+)
+
+func isConflict(err error) bool {
+	return errors.Is(err, errConflict)
+}
+
+var testPolicy = retry.Policy{Attempts: 3, BaseDelay: time.Millisecond, MaxDelay: 2 * time.Millisecond}
+
+func TestRetry_Do_RetriesUntilSuccess(t *testing.T) {
+	// Arrange
+	calls := 0
+	fn := func(ctx context.Context) error {
+		calls++
+		if calls < 3 {
+			return errConflict
+		}
+		return nil
+	}
+
+	// Act
+	err := retry.Do(context.Background(), testPolicy, isConflict, fn)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+}
+
+func TestRetry_Do_StopsAfterAttempts(t *testing.T) {
+	// Arrange
+	calls := 0
+	fn := func(ctx context.Context) error {
+		calls++
+		return errConflict
+	}
+
+	// Act
+	err := retry.Do(context.Background(), testPolicy, isConflict, fn)
+
+	// Assert
+	assert.ErrorIs(t, err, errConflict)
+	assert.Equal(t, 3, calls)
+}
+
+func TestRetry_Do_DoesNotRetryOtherErrors(t *testing.T) {
+	// Arrange
+	calls := 0
+	fn := func(ctx context.Context) error {
+		calls++
+		return errFatal
+	}
+
+	// Act
+	err := retry.Do(context.Background(), testPolicy, isConflict, fn)
+
+	// Assert
+	assert.ErrorIs(t, err, errFatal)
+	assert.Equal(t, 1, calls)
+}
+
+func TestRetry_Do_StopsWhenContextCanceled(t *testing.T) {
+	// Arrange
+	ctx, cancel := context.WithCancel(context.Background())
+	policy := retry.Policy{Attempts: 10, BaseDelay: time.Hour}
+	calls := 0
+	fn := func(ctx context.Context) error {
+		calls++
+		cancel()
+		return errConflict
+	}
+
+	// Act
+	err := retry.Do(ctx, policy, isConflict, fn)
+
+	// Assert
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, calls)
+}
+
+func TestIsTransactionConflict(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"deadlock", &pgconn.PgError{Code: "40P01"}, true},
+		{"serialization failure", &pgconn.PgError{Code: "40001"}, true},
+		{"wrapped deadlock", fmt.Errorf("transfer: %w", &pgconn.PgError{Code: "40P01"}), true},
+		{"unique violation", &pgconn.PgError{Code: "23505"}, false},
+		{"check violation", &pgconn.PgError{Code: "23514"}, false},
+		{"not a postgres error", errConflict, false},
+		{"nil", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, postgres.IsTransactionConflict(tt.err))
+		})
+	}
+}
@@ -6,12 +6,15 @@ import (
 	"avito-shop/internal/tests/mocks"
 	"context"
 	"errors"
+	"strings"
 	"testing"
+	"time"
 
 	"log/slog"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 )
 
@@ -26,9 +29,11 @@ func TestUserService_GetUserInfo_ReturnsAggregatedData(t *testing.T) {
 	repo.On("GetUserPurchases", ctx, userID).
 		Return([]dto.PurchaseDTO{{Merch: "pen", Amount: 2}}, nil).Once()
 	repo.On("GetCoinTransactions", ctx, userID).
-		Return(dto.TransactionDTO{Received: []dto.CoinTransactionDTO{{Username: "alice", TotalAmount: 100}}}, nil).Once()
+		Return(dto.TransactionDTO{Received: []dto.ReceivedCoinsDTO{{FromUser: "alice", Amount: 100}}}, nil).Once()
+	repo.On("GetGifts", ctx, userID).
+		Return(dto.GiftHistoryDTO{Sent: []dto.SentGiftDTO{{ToUser: "bob", Merch: "cup"}}}, nil).Once()
 
-	service := services.NewUserService(slog.Default(), repo)
+	service := services.NewUserService(slog.Default(), repo, newCatalogCache())
 
 	// Act
 	info, err := service.GetUserInfo(ctx, userID)
@@ -39,6 +44,7 @@ func TestUserService_GetUserInfo_ReturnsAggregatedData(t *testing.T) {
 	assert.Len(t, info.Inventory, 1)
 	assert.Equal(t, "pen", info.Inventory[0].Merch)
 	assert.NotEmpty(t, info.CoinHistory.Received)
+	assert.Equal(t, "bob", info.Gifts.Sent[0].ToUser)
 	repo.AssertExpectations(t)
 }
 
@@ -52,7 +58,7 @@ func TestUserService_GetUserInfo_ReturnsErrorWhenUserLookupFails(t *testing.T) {
 	repo.On("GetUserById", ctx, userID).
 		Return(dto.UserDTO{}, repoErr).Once()
 
-	service := services.NewUserService(slog.Default(), repo)
+	service := services.NewUserService(slog.Default(), repo, newCatalogCache())
 
 	// Act
 	info, err := service.GetUserInfo(ctx, userID)
@@ -74,7 +80,7 @@ func TestUserService_GetUserInfo_StopsWhenPurchasesLookupFails(t *testing.T) {
 	repo.On("GetUserPurchases", ctx, userID).
 		Return([]dto.PurchaseDTO(nil), errors.New("purchases error")).Once()
 
-	service := services.NewUserService(slog.Default(), repo)
+	service := services.NewUserService(slog.Default(), repo, newCatalogCache())
 
 	// Act
 	info, err := service.GetUserInfo(ctx, userID)
@@ -89,23 +95,66 @@ func TestUserService_TransferCoins_ReturnsErrorOnFailure(t *testing.T) {
 	// Arrange
 	ctx := context.Background()
 	fromID := uuid.New()
-	toID := uuid.New()
 	repoErr := errors.New("transfer failed")
 
 	repo := new(mocks.UserRepositoryMock)
-	repo.On("TransferCoins", ctx, fromID, toID, 100).
+	repo.On("TransferCoins", ctx, fromID, "bob", 100).
 		Return(repoErr).Once()
 
-	service := services.NewUserService(slog.Default(), repo)
+	service := services.NewUserService(slog.Default(), repo, newCatalogCache())
 
 	// Act
-	err := service.TransferCoins(ctx, fromID, toID, 100)
+	err := service.TransferCoins(ctx, fromID, "bob", 100)
 
 	// Assert
 	assert.ErrorContains(t, err, "transfer failed")
 	repo.AssertExpectations(t)
 }
 
+func TestUserService_TransferCoins_RejectsNonPositiveAmount(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	repo := new(mocks.UserRepositoryMock)
+	service := services.NewUserService(slog.Default(), repo, newCatalogCache())
+
+	// Act
+	zeroErr := service.TransferCoins(ctx, uuid.New(), "bob", 0)
+	negativeErr := service.TransferCoins(ctx, uuid.New(), "bob", -100)
+
+	// Assert
+	assert.ErrorIs(t, zeroErr, services.ErrInvalidAmount)
+	assert.ErrorIs(t, negativeErr, services.ErrInvalidAmount)
+	repo.AssertNotCalled(t, "TransferCoins", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+// newCatalogCache - кеш каталога для тестов, которые не проверяют его сброс.
+func newCatalogCache() *mocks.CatalogCacheMock {
+	cache := new(mocks.CatalogCacheMock)
+	cache.On("InvalidateCache").Maybe()
+	return cache
+}
+
+func TestUserService_BuyItem_InvalidatesCatalogCache(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	userID := uuid.New()
+
+	repo := new(mocks.UserRepositoryMock)
+	repo.On("BuyItem", ctx, userID, "t-shirt").
+		Return(nil).Once()
+	cache := new(mocks.CatalogCacheMock)
+	cache.On("InvalidateCache").Once()
+
+	service := services.NewUserService(slog.Default(), repo, cache)
+
+	// Act
+	err := service.BuyItem(ctx, userID, "t-shirt")
+
+	// Assert
+	require.NoError(t, err)
+	cache.AssertExpectations(t)
+}
+
 func TestUserService_BuyItem_PropagatesRepositoryError(t *testing.T) {
 	// Arrange
 	ctx := context.Background()
@@ -115,8 +164,9 @@ func TestUserService_BuyItem_PropagatesRepositoryError(t *testing.T) {
 	repo := new(mocks.UserRepositoryMock)
 	repo.On("BuyItem", ctx, userID, "t-shirt").
 		Return(repoErr).Once()
+	cache := new(mocks.CatalogCacheMock)
 
-	service := services.NewUserService(slog.Default(), repo)
+	service := services.NewUserService(slog.Default(), repo, cache)
 
 	// Act
 	err := service.BuyItem(ctx, userID, "t-shirt")
@@ -124,4 +174,172 @@ func TestUserService_BuyItem_PropagatesRepositoryError(t *testing.T) {
 	// Assert
 	assert.ErrorContains(t, err, "out of stock")
 	repo.AssertExpectations(t)
+	cache.AssertNotCalled(t, "InvalidateCache")
+}
+
+func TestUserService_GetTransactions_PaginatesWithCursor(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	userID := uuid.New()
+	now := time.Now().UTC()
+	page := []dto.TransferDTO{
+		{ID: uuid.New(), Direction: dto.DirectionSent, Counterparty: "bob", Amount: 3, CreatedAt: now},
+		{ID: uuid.New(), Direction: dto.DirectionReceived, Counterparty: "bob", Amount: 2, CreatedAt: now.Add(-time.Second)},
+		{ID: uuid.New(), Direction: dto.DirectionSent, Counterparty: "carol", Amount: 1, CreatedAt: now.Add(-2 * time.Second)},
+	}
+
+	repo := new(mocks.UserRepositoryMock)
+	repo.On("GetTransfers", ctx, userID, dto.TransferFilter{Limit: 3}).
+		Return(page, nil).Once()
+	repo.On("GetTransfers", ctx, userID, mock.MatchedBy(func(f dto.TransferFilter) bool {
+		return f.Limit == 3 && f.BeforeID == page[1].ID && f.BeforeCreatedAt.Equal(page[1].CreatedAt)
+	})).
+		Return(page[2:], nil).Once()
+
+	service := services.NewUserService(slog.Default(), repo, newCatalogCache())
+
+	// Act
+	first, err := service.GetTransactions(ctx, userID, dto.TransactionsRequest{Limit: 2})
+	require.NoError(t, err)
+	second, err := service.GetTransactions(ctx, userID, dto.TransactionsRequest{Limit: 2, Cursor: first.NextCursor})
+	require.NoError(t, err)
+
+	// Assert
+	assert.Equal(t, page[:2], first.Transactions)
+	assert.NotEmpty(t, first.NextCursor)
+	assert.Equal(t, page[2:], second.Transactions)
+	assert.Empty(t, second.NextCursor)
+	repo.AssertExpectations(t)
+}
+
+func TestUserService_GetTransactions_ParsesFilters(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	userID := uuid.New()
+	from := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+
+	repo := new(mocks.UserRepositoryMock)
+	repo.On("GetTransfers", ctx, userID, mock.MatchedBy(func(f dto.TransferFilter) bool {
+		return f.Direction == dto.DirectionReceived && f.From.Equal(from) && f.To.Equal(to) && f.Limit == 21
+	})).
+		Return([]dto.TransferDTO{}, nil).Once()
+
+	service := services.NewUserService(slog.Default(), repo, newCatalogCache())
+
+	// Act
+	resp, err := service.GetTransactions(ctx, userID, dto.TransactionsRequest{
+		Direction: dto.DirectionReceived,
+		From:      "2026-10-01",
+		To:        "2026-10-15T12:00:00Z",
+	})
+
+	// Assert
+	require.NoError(t, err)
+	assert.Empty(t, resp.Transactions)
+	assert.Empty(t, resp.NextCursor)
+	repo.AssertExpectations(t)
+}
+
+func TestUserService_GetTransactions_RejectsInvalidFilters(t *testing.T) {
+	cases := []struct {
+		name string
+		req  dto.TransactionsRequest
+		err  error
+	}{
+		{"direction", dto.TransactionsRequest{Direction: "both"}, services.ErrInvalidDirection},
+		{"date format", dto.TransactionsRequest{From: "yesterday"}, services.ErrInvalidDateRange},
+		{"reversed range", dto.TransactionsRequest{From: "2026-10-02", To: "2026-10-01"}, services.ErrInvalidDateRange},
+		{"cursor", dto.TransactionsRequest{Cursor: "not-a-cursor"}, services.ErrInvalidCursor},
+		{"limit", dto.TransactionsRequest{Limit: 101}, services.ErrInvalidLimit},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			repo := new(mocks.UserRepositoryMock)
+			service := services.NewUserService(slog.Default(), repo, newCatalogCache())
+
+			// Act
+			_, err := service.GetTransactions(context.Background(), uuid.New(), tc.req)
+
+			// Assert
+			assert.ErrorIs(t, err, tc.err)
+			repo.AssertNotCalled(t, "GetTransfers", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestUserService_Checkout_MergesItemsAndSumsTotal(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	userID := uuid.New()
+	merged := []dto.CheckoutItem{{Item: "pen", Quantity: 3}, {Item: "cup", Quantity: 1}}
+
+	repo := new(mocks.UserRepositoryMock)
+	repo.On("Checkout", ctx, userID, merged).
+		Return([]dto.CheckoutLineDTO{{Item: "pen", Quantity: 3, Price: 10}, {Item: "cup", Quantity: 1, Price: 20}}, nil).
+		Once()
+
+	service := services.NewUserService(slog.Default(), repo, newCatalogCache())
+
+	// Act
+	resp, err := service.Checkout(ctx, userID, dto.CheckoutRequest{Items: []dto.CheckoutItem{
+		{Item: "pen", Quantity: 1}, {Item: "cup", Quantity: 1}, {Item: "pen", Quantity: 2},
+	}})
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, 50, resp.Total)
+	assert.Len(t, resp.Items, 2)
+	repo.AssertExpectations(t)
+}
+
+func TestUserService_Checkout_RejectsInvalidCart(t *testing.T) {
+	cases := []struct {
+		name  string
+		items []dto.CheckoutItem
+		err   error
+	}{
+		{"empty", nil, services.ErrEmptyCart},
+		{"zero quantity", []dto.CheckoutItem{{Item: "pen", Quantity: 0}}, services.ErrInvalidQuantity},
+		{"too many units", []dto.CheckoutItem{{Item: "pen", Quantity: 60}, {Item: "cup", Quantity: 41}},
+			services.ErrInvalidQuantity},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			repo := new(mocks.UserRepositoryMock)
+			service := services.NewUserService(slog.Default(), repo, newCatalogCache())
+
+			// Act
+			_, err := service.Checkout(context.Background(), uuid.New(), dto.CheckoutRequest{Items: tc.items})
+
+			// Assert
+			assert.ErrorIs(t, err, tc.err)
+			repo.AssertNotCalled(t, "Checkout", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestUserService_GiftItem_TrimsAndLimitsMessage(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	fromID := uuid.New()
+
+	repo := new(mocks.UserRepositoryMock)
+	repo.On("GiftItem", ctx, fromID, "bob", "t-shirt", "С днем рождения!").Return(nil).Once()
+
+	service := services.NewUserService(slog.Default(), repo, newCatalogCache())
+
+	// Act
+	err := service.GiftItem(ctx, fromID, dto.GiftRequest{ToUser: "bob", Item: "t-shirt", Message: "  С днем рождения! "})
+	longErr := service.GiftItem(ctx, fromID, dto.GiftRequest{ToUser: "bob", Item: "t-shirt",
+		Message: strings.Repeat("я", 201)})
+
+	// Assert
+	require.NoError(t, err)
+	assert.ErrorIs(t, longErr, services.ErrGiftMessageTooLong)
+	repo.AssertExpectations(t)
 }
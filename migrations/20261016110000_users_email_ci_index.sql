@@ -0,0 +1,36 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users
+    DROP CONSTRAINT IF EXISTS users_email_key;
+
+-- адреса, различающиеся только регистром, не объединяются автоматически: это могут быть разные люди,
+-- поэтому миграция откатывается со списком конфликтов, которые нужно разобрать вручную
+DO $$
+DECLARE
+    duplicates TEXT;
+BEGIN
+    SELECT string_agg(format('%s: %s', lower_email, usernames), '; ' ORDER BY lower_email)
+    INTO duplicates
+    FROM (SELECT LOWER(email) AS lower_email, string_agg(username, ', ' ORDER BY username) AS usernames
+          FROM users
+          WHERE email IS NOT NULL
+          GROUP BY LOWER(email)
+          HAVING COUNT(*) > 1) d;
+
+    IF duplicates IS NOT NULL THEN
+        RAISE EXCEPTION 'emails that differ only by case must be resolved before migrating: %', duplicates;
+    END IF;
+END $$;
+
+UPDATE users SET email = LOWER(email) WHERE email IS NOT NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS users_email_lower_key ON users (LOWER(email));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS users_email_lower_key;
+
+ALTER TABLE users
+    ADD CONSTRAINT users_email_key UNIQUE (email);
+-- +goose StatementEnd
@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+-- argon2id хеши в PHC формате длиннее bcrypt и зависят от параметров, поэтому длина не ограничивается
+ALTER TABLE users
+    ALTER COLUMN password TYPE TEXT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users
+    ALTER COLUMN password TYPE VARCHAR(100);
+-- +goose StatementEnd
@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS idx_coin_trans_from_user_created_at ON coin_transactions (from_user_id, created_at, id);
+CREATE INDEX IF NOT EXISTS idx_coin_trans_to_user_created_at ON coin_transactions (to_user_id, created_at, id);
+
+-- составные индексы покрывают выборки только по пользователю
+DROP INDEX IF EXISTS idx_coin_trans_from_user;
+DROP INDEX IF EXISTS idx_coin_trans_to_user;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS idx_coin_trans_from_user ON coin_transactions (from_user_id);
+CREATE INDEX IF NOT EXISTS idx_coin_trans_to_user ON coin_transactions (to_user_id);
+
+DROP INDEX IF EXISTS idx_coin_trans_from_user_created_at;
+DROP INDEX IF EXISTS idx_coin_trans_to_user_created_at;
+-- +goose StatementEnd
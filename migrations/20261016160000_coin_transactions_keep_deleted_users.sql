@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+-- история переводов второй стороны не должна пропадать при удалении аккаунта
+ALTER TABLE coin_transactions
+    ALTER COLUMN from_user_id DROP NOT NULL,
+    ALTER COLUMN to_user_id DROP NOT NULL,
+    DROP CONSTRAINT IF EXISTS coin_trans_from_fk,
+    DROP CONSTRAINT IF EXISTS coin_trans_to_fk,
+    ADD CONSTRAINT coin_trans_from_fk
+        FOREIGN KEY (from_user_id) REFERENCES users (id) ON DELETE SET NULL,
+    ADD CONSTRAINT coin_trans_to_fk
+        FOREIGN KEY (to_user_id) REFERENCES users (id) ON DELETE SET NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM coin_transactions WHERE from_user_id IS NULL OR to_user_id IS NULL;
+
+ALTER TABLE coin_transactions
+    DROP CONSTRAINT IF EXISTS coin_trans_from_fk,
+    DROP CONSTRAINT IF EXISTS coin_trans_to_fk,
+    ALTER COLUMN from_user_id SET NOT NULL,
+    ALTER COLUMN to_user_id SET NOT NULL,
+    ADD CONSTRAINT coin_trans_from_fk
+        FOREIGN KEY (from_user_id) REFERENCES users (id) ON DELETE CASCADE,
+    ADD CONSTRAINT coin_trans_to_fk
+        FOREIGN KEY (to_user_id) REFERENCES users (id) ON DELETE CASCADE;
+-- +goose StatementEnd
@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE merch_items
+    ADD COLUMN IF NOT EXISTS description TEXT    NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS available   BOOLEAN NOT NULL DEFAULT TRUE;
+
+UPDATE merch_items
+SET description = d.description
+FROM (VALUES ('t-shirt', 'Футболка с логотипом Авито'),
+             ('cup', 'Кружка с логотипом Авито'),
+             ('book', 'Блокнот в фирменной обложке'),
+             ('pen', 'Ручка с логотипом Авито'),
+             ('powerbank', 'Внешний аккумулятор'),
+             ('hoody', 'Худи с логотипом Авито'),
+             ('umbrella', 'Зонт в фирменных цветах'),
+             ('socks', 'Носки с принтом'),
+             ('wallet', 'Кошелек с логотипом Авито'),
+             ('pink-hoody', 'Розовое худи, лимитированная серия')) AS d (name, description)
+WHERE merch_items.name = d.name
+  AND merch_items.description = '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE merch_items
+    DROP COLUMN IF EXISTS available,
+    DROP COLUMN IF EXISTS description;
+-- +goose StatementEnd
@@ -0,0 +1,46 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE merch_items
+    ADD COLUMN IF NOT EXISTS archived_at TIMESTAMPTZ;
+
+-- товары только архивируются, удаление не должно стирать историю покупок
+ALTER TABLE purchases
+    DROP CONSTRAINT IF EXISTS purchases_merch_fk,
+    ADD CONSTRAINT purchases_merch_fk
+        FOREIGN KEY (merch_id) REFERENCES merch_items (id) ON DELETE RESTRICT;
+
+CREATE TABLE IF NOT EXISTS merch_price_history
+(
+    id         UUID PRIMARY KEY     DEFAULT uuid_generate_v4(),
+    merch_id   UUID        NOT NULL,
+    old_price  INT,
+    new_price  INT         NOT NULL CHECK (new_price > 0),
+    changed_by UUID,
+    changed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT merch_price_history_merch_fk
+        FOREIGN KEY (merch_id) REFERENCES merch_items (id) ON DELETE CASCADE,
+    CONSTRAINT merch_price_history_changed_by_fk
+        FOREIGN KEY (changed_by) REFERENCES users (id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_merch_price_history_merch_id ON merch_price_history (merch_id, changed_at);
+
+-- начальные цены каталога, чтобы история каждой позиции начиналась с первой цены
+INSERT INTO merch_price_history (merch_id, old_price, new_price, changed_at)
+SELECT id, NULL, price, created_at
+FROM merch_items;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS merch_price_history;
+
+ALTER TABLE purchases
+    DROP CONSTRAINT IF EXISTS purchases_merch_fk,
+    ADD CONSTRAINT purchases_merch_fk
+        FOREIGN KEY (merch_id) REFERENCES merch_items (id) ON DELETE CASCADE;
+
+ALTER TABLE merch_items
+    DROP COLUMN IF EXISTS archived_at;
+-- +goose StatementEnd
@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+-- NULL во всех колонках означает отсутствие ограничения
+ALTER TABLE merch_items
+    ADD COLUMN IF NOT EXISTS stock          INT CHECK (stock >= 0),
+    ADD COLUMN IF NOT EXISTS per_user_limit INT CHECK (per_user_limit > 0),
+    ADD COLUMN IF NOT EXISTS sale_starts_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS sale_ends_at   TIMESTAMPTZ,
+    ADD CONSTRAINT merch_items_sale_window_check CHECK (sale_starts_at < sale_ends_at);
+
+CREATE INDEX IF NOT EXISTS idx_purchases_user_merch ON purchases (user_id, merch_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_purchases_user_merch;
+
+ALTER TABLE merch_items
+    DROP CONSTRAINT IF EXISTS merch_items_sale_window_check,
+    DROP COLUMN IF EXISTS sale_ends_at,
+    DROP COLUMN IF EXISTS sale_starts_at,
+    DROP COLUMN IF EXISTS per_user_limit,
+    DROP COLUMN IF EXISTS stock;
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+-- возвращенная покупка остается в истории, returned_at отмечает момент возврата
+ALTER TABLE purchases
+    ADD COLUMN IF NOT EXISTS returned_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_purchases_user_created ON purchases (user_id, created_at DESC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_purchases_user_created;
+
+ALTER TABLE purchases
+    DROP COLUMN IF EXISTS returned_at;
+-- +goose StatementEnd
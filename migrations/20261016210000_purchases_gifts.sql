@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+-- подарок принадлежит получателю (user_id), gifted_by - отправитель, который за него заплатил.
+-- is_gift не зависит от gifted_by, чтобы подарок оставался подарком после удаления аккаунта отправителя
+ALTER TABLE purchases
+    ADD COLUMN IF NOT EXISTS is_gift      BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS gifted_by    UUID,
+    ADD COLUMN IF NOT EXISTS gift_message TEXT,
+    ADD CONSTRAINT purchases_gifted_by_fk
+        FOREIGN KEY (gifted_by) REFERENCES users (id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_purchases_gifted_by ON purchases (gifted_by) WHERE gifted_by IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_purchases_gifted_by;
+
+ALTER TABLE purchases
+    DROP CONSTRAINT IF EXISTS purchases_gifted_by_fk,
+    DROP COLUMN IF EXISTS gift_message,
+    DROP COLUMN IF EXISTS gifted_by,
+    DROP COLUMN IF EXISTS is_gift;
+-- +goose StatementEnd
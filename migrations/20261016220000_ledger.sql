@@ -0,0 +1,100 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Двойная запись: каждая операция с монетами - проводка (ledger_entries) из строк (ledger_postings), сумма
+-- которых равна нулю. Баланс счета - сумма его строк, users.coins - кеш баланса счета пользователя.
+CREATE TABLE IF NOT EXISTS ledger_accounts
+(
+    id         UUID PRIMARY KEY     DEFAULT uuid_generate_v4(),
+    user_id    UUID UNIQUE,          -- счет пользователя
+    code       TEXT UNIQUE,          -- системный счет: issuance (выпуск монет), shop (выручка магазина)
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    -- после удаления пользователя счет остается без владельца, чтобы проводки сохранили баланс
+    CONSTRAINT ledger_accounts_user_fk
+        FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE SET NULL,
+    CONSTRAINT ledger_accounts_owner_check CHECK (user_id IS NULL OR code IS NULL)
+);
+
+CREATE TABLE IF NOT EXISTS ledger_entries
+(
+    id           UUID PRIMARY KEY     DEFAULT uuid_generate_v4(),
+    kind         TEXT        NOT NULL
+        CONSTRAINT ledger_entries_kind_check
+            CHECK (kind IN ('opening', 'grant', 'transfer', 'purchase', 'refund', 'adjustment')),
+    reference_id UUID,                -- coin_transactions, purchases или balance_adjustments
+    description  TEXT        NOT NULL DEFAULT '',
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS ledger_postings
+(
+    id         UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    entry_id   UUID   NOT NULL,
+    account_id UUID   NOT NULL,
+    amount     BIGINT NOT NULL CHECK (amount <> 0),
+
+    CONSTRAINT ledger_postings_entry_fk
+        FOREIGN KEY (entry_id) REFERENCES ledger_entries (id) ON DELETE RESTRICT,
+    CONSTRAINT ledger_postings_account_fk
+        FOREIGN KEY (account_id) REFERENCES ledger_accounts (id) ON DELETE RESTRICT
+);
+
+CREATE INDEX IF NOT EXISTS idx_ledger_postings_account ON ledger_postings (account_id);
+CREATE INDEX IF NOT EXISTS idx_ledger_postings_entry ON ledger_postings (entry_id);
+
+-- проверка выполняется при коммите, когда все строки проводки уже вставлены
+CREATE OR REPLACE FUNCTION ledger_check_entry_balanced() RETURNS TRIGGER AS
+$$
+BEGIN
+    IF (SELECT COALESCE(SUM(amount), 0) FROM ledger_postings WHERE entry_id = NEW.entry_id) <> 0 THEN
+        RAISE EXCEPTION 'ledger entry % is not balanced', NEW.entry_id USING ERRCODE = 'check_violation';
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE CONSTRAINT TRIGGER ledger_postings_balanced
+    AFTER INSERT OR UPDATE
+    ON ledger_postings
+    DEFERRABLE INITIALLY DEFERRED
+    FOR EACH ROW
+EXECUTE FUNCTION ledger_check_entry_balanced();
+
+INSERT INTO ledger_accounts (code)
+VALUES ('issuance'),
+       ('shop')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO ledger_accounts (user_id)
+SELECT id
+FROM users
+ON CONFLICT DO NOTHING;
+
+-- история до появления журнала не восстанавливается: текущие балансы переносятся одной открывающей проводкой
+WITH opening AS (
+    INSERT INTO ledger_entries (kind, description)
+        VALUES ('opening', 'перенос балансов users.coins')
+        RETURNING id)
+INSERT
+INTO ledger_postings (entry_id, account_id, amount)
+SELECT opening.id, a.id, u.coins
+FROM opening,
+     users u
+         JOIN ledger_accounts a ON a.user_id = u.id
+WHERE u.coins <> 0
+UNION ALL
+SELECT opening.id, (SELECT id FROM ledger_accounts WHERE code = 'issuance'), -SUM(u.coins)
+FROM opening,
+     users u
+GROUP BY opening.id
+HAVING SUM(u.coins) <> 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS ledger_postings_balanced ON ledger_postings;
+DROP FUNCTION IF EXISTS ledger_check_entry_balanced();
+DROP TABLE IF EXISTS ledger_postings;
+DROP TABLE IF EXISTS ledger_entries;
+DROP TABLE IF EXISTS ledger_accounts;
+-- +goose StatementEnd